@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/oxequa/interact"
 	"github.com/oxequa/realize/realize"
 	"gopkg.in/urfave/cli.v2"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -21,47 +24,59 @@ func main() {
 		Name:        strings.Title(realize.RPrefix),
 		Version:     realize.RVersion,
 		Description: "Realize is the #1 Golang Task Runner which enhance your workflow by automating the most common tasks and using the best performing Golang live reloading.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "config", Aliases: []string{"c"}, Value: "", Usage: "Config file, the nearest one of the working directory and its parents by default"},
+		},
+		ArgsUsage: "[-- command]",
+		Action: func(c *cli.Context) error {
+			if c.Args().Len() == 0 {
+				return cli.ShowAppHelp(c)
+			}
+			return bootstrap(c)
+		},
 		Commands: []*cli.Command{
 			{
 				Name:        "start",
 				Aliases:     []string{"s"},
 				Description: "Start " + strings.Title(realize.RPrefix) + " on a given path. If not exist a config file it creates a new one.",
-				Flags: []cli.Flag{
-					&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Value: ".", Usage: "Project base path"},
-					&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Value: "", Usage: "Run a project by its name"},
-					&cli.BoolFlag{Name: "fmt", Aliases: []string{"f"}, Value: false, Usage: "Enable go fmt"},
-					&cli.BoolFlag{Name: "vet", Aliases: []string{"v"}, Value: false, Usage: "Enable go vet"},
-					&cli.BoolFlag{Name: "test", Aliases: []string{"t"}, Value: false, Usage: "Enable go test"},
-					&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Value: false, Usage: "Enable go generate"},
-					&cli.BoolFlag{Name: "server", Aliases: []string{"srv"}, Value: false, Usage: "Start server"},
-					&cli.BoolFlag{Name: "open", Aliases: []string{"op"}, Value: false, Usage: "Open into the default browser"},
-					&cli.BoolFlag{Name: "install", Aliases: []string{"i"}, Value: false, Usage: "Enable go install"},
-					&cli.BoolFlag{Name: "build", Aliases: []string{"b"}, Value: false, Usage: "Enable go build"},
-					&cli.BoolFlag{Name: "run", Aliases: []string{"nr"}, Value: false, Usage: "Enable go run"},
-					&cli.BoolFlag{Name: "legacy", Aliases: []string{"l"}, Value: false, Usage: "Legacy watch by polling instead fsnotify"},
-					&cli.BoolFlag{Name: "no-config", Aliases: []string{"nc"}, Value: false, Usage: "Ignore existing config and doesn't create a new one"},
-				},
-				Action: func(c *cli.Context) error {
+				Flags:       startFlags(),
+				Action: configured(func(c *cli.Context) error {
 					return start(c)
-				},
+				}),
 			},
 			{
 				Name:        "add",
 				Category:    "Configuration",
 				Aliases:     []string{"a"},
-				Description: "Add a project to an existing config or to a new one.",
-				Flags: []cli.Flag{
-					&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Value: realize.Wdir(), Usage: "Project base path"},
-					&cli.BoolFlag{Name: "fmt", Aliases: []string{"f"}, Value: false, Usage: "Enable go fmt"},
-					&cli.BoolFlag{Name: "vet", Aliases: []string{"v"}, Value: false, Usage: "Enable go vet"},
-					&cli.BoolFlag{Name: "test", Aliases: []string{"t"}, Value: false, Usage: "Enable go test"},
-					&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Value: false, Usage: "Enable go generate"},
-					&cli.BoolFlag{Name: "install", Aliases: []string{"i"}, Value: false, Usage: "Enable go install"},
-					&cli.BoolFlag{Name: "build", Aliases: []string{"b"}, Value: false, Usage: "Enable go build"},
-					&cli.BoolFlag{Name: "run", Aliases: []string{"nr"}, Value: false, Usage: "Enable go run"},
-				},
-				Action: func(c *cli.Context) error {
-					return add(c)
+				Description: "Add a project to an existing config or to a new one, or a command to a project, the comments of the config are kept.",
+				Flags:       projectFlags(),
+				Action: configured(func(c *cli.Context) error {
+					return add(c, "")
+				}),
+				Subcommands: []*cli.Command{
+					{
+						Name:        "project",
+						ArgsUsage:   "[path] [args]",
+						Description: "Add a project of a path, the working directory by default, with the args of its run.",
+						Flags:       projectFlags(),
+						Action: configured(func(c *cli.Context) error {
+							return add(c, c.Args().First())
+						}),
+					},
+					{
+						Name:        "task",
+						Description: "Add a command to the watcher scripts of a project.",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Value: "", Usage: "Project name, required if the config has more projects"},
+							&cli.StringFlag{Name: "cmd", Value: "", Usage: "Command"},
+							&cli.StringFlag{Name: "stage", Aliases: []string{"s"}, Value: "after", Usage: "Run before or after the tools, on error or on success"},
+							&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Value: "", Usage: "Command path, relative to the project"},
+							&cli.BoolFlag{Name: "shell", Value: false, Usage: "Run through sh -c, for the pipes and the redirections"},
+						},
+						Action: configured(func(c *cli.Context) error {
+							return task(c)
+						}),
+					},
 				},
 			},
 			{
@@ -69,9 +84,9 @@ func main() {
 				Category:    "Configuration",
 				Aliases:     []string{"i"},
 				Description: "Make a new config file step by step.",
-				Action: func(c *cli.Context) error {
+				Action: configured(func(c *cli.Context) error {
 					return setup(c)
-				},
+				}),
 			},
 			{
 				Name:        "remove",
@@ -81,17 +96,71 @@ func main() {
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Value: ""},
 				},
-				Action: func(c *cli.Context) error {
+				Action: configured(func(c *cli.Context) error {
 					return remove(c)
-				},
+				}),
 			},
 			{
 				Name:        "clean",
 				Category:    "Configuration",
 				Aliases:     []string{"c"},
 				Description: "Remove " + strings.Title(realize.RPrefix) + " folder.",
-				Action: func(c *cli.Context) error {
+				Action: configured(func(c *cli.Context) error {
 					return clean()
+				}),
+			},
+			{
+				Name:        "schema",
+				Category:    "Configuration",
+				Description: "Print the JSON Schema of the config, for the completion and the validation of the editors.",
+				Action: func(c *cli.Context) error {
+					return schema()
+				},
+			},
+			{
+				Name:        "config",
+				Category:    "Configuration",
+				Description: "Print the config after the includes and the presets, and with --resolved as run by the start with the same flags.",
+				Flags: append(startFlags(),
+					&cli.BoolFlag{Name: "resolved", Aliases: []string{"r"}, Value: false, Usage: "Apply the env variables, the flags, the os overrides, the profile, the named commands and the defaults"},
+					&cli.StringFlag{Name: "format", Value: "yaml", Usage: "Print as yaml or json"},
+				),
+				Action: configured(func(c *cli.Context) error {
+					return config(c)
+				}),
+			},
+			{
+				Name:        "migrate",
+				Category:    "Configuration",
+				Description: "Convert a config of the first versions of " + strings.Title(realize.RPrefix) + ", as .realize/realize.yaml, to a new one.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Value: filepath.Join(".realize", "realize.yaml"), Usage: "Legacy config file"},
+				},
+				Action: configured(func(c *cli.Context) error {
+					return migrate(c)
+				}),
+			},
+			{
+				Name:        "check",
+				Category:    "Configuration",
+				Description: "Validate the config, the paths of the projects and their watched files.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "lint", Value: false, Usage: "Suggest the fixes of the watched and ignored paths, of the extensions and of the command dirs"},
+				},
+				Action: configured(func(c *cli.Context) error {
+					return check(c)
+				}),
+			},
+			{
+				Name:        "encrypt",
+				Category:    "Configuration",
+				ArgsUsage:   "[value]",
+				Description: "Encrypt a value of the secrets with the key of " + realize.SecretKey + ", the standard input without an argument.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "new-key", Value: false, Usage: "Print a random key for " + realize.SecretKey},
+				},
+				Action: func(c *cli.Context) error {
+					return encrypt(c)
 				},
 			},
 			{
@@ -105,12 +174,49 @@ func main() {
 			},
 		},
 	}
+	if name := subcommand(app, os.Args); name != "" {
+		log.Fatal("realize -- " + name + ": " + name + " is a command of realize, run it without --")
+	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 		os.Exit(1)
 	}
 }
 
+// Locate sets the config of the flag or the nearest one, none without a config
+func locate(c *cli.Context) error {
+	if c.Bool("no-config") {
+		return nil
+	}
+	name := c.String("config")
+	if name == "" {
+		if name = realize.Discover("."); name == "" {
+			// a new config in the working directory
+			return nil
+		}
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(filepath.Dir(abs)); err != nil {
+		return errors.New("config: " + err.Error())
+	}
+	// the working directory isn't changed, the paths of the projects are relative to the config
+	realize.RFile = abs
+	return nil
+}
+
+// Configured locates the config before an action
+func configured(action func(*cli.Context) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		if err := locate(c); err != nil {
+			return err
+		}
+		return action(c)
+	}
+}
+
 // Version print current version
 func version() {
 	log.Println(r.Prefix(realize.Green.Bold(realize.RVersion)))
@@ -118,38 +224,213 @@ func version() {
 
 // Clean remove realize file
 func clean() (err error) {
-	if err := r.Settings.Remove(realize.RFile); err != nil {
+	if err := r.Settings.Remove(realize.Config()); err != nil {
 		return err
 	}
 	log.Println(r.Prefix(realize.Green.Bold("folder successfully removed")))
 	return nil
 }
 
-// Add a project to an existing config or create a new one
-func add(c *cli.Context) (err error) {
-	// read a config if exist
-	err = r.Settings.Read(&r)
+// Schema prints the json schema of the config
+func schema() error {
+	s, err := realize.JSONSchema()
 	if err != nil {
 		return err
 	}
-	projects := len(r.Schema.Projects)
-	// create and add a new project
-	r.Schema.Add(r.Schema.New(c))
-	if len(r.Schema.Projects) > projects {
-		// update config
-		err = r.Settings.Write(r)
+	_, err = os.Stdout.Write(append(s, '\n'))
+	return err
+}
+
+// Config prints the config, resolved as by the start with the same flags
+func config(c *cli.Context) error {
+	if c.Bool("legacy") {
+		r.Settings.Legacy.Set(c.Bool("legacy"), 1)
+	}
+	if c.Bool("server") {
+		r.Server.Set(c.Bool("server"), c.Bool("open"), realize.Port, realize.Host)
+	}
+	r.Settings.Lax = c.Bool("no-strict")
+	if err := r.Settings.Read(&r); err != nil {
+		return err
+	}
+	if c.String("name") != "" {
+		r.Schema.Projects = r.Schema.Filter("Name", c.String("name"))
+	}
+	logged(c, &r.Settings)
+	if c.Bool("resolved") {
+		r.Schema.Interpolate()
+		if err := overrides(c, &r.Schema); err != nil {
+			return err
+		}
+		r.Resolve()
+	}
+	return r.Print(os.Stdout, c.String("format"))
+}
+
+// Migrate a legacy config to a new one, an existing config is not overwritten
+func migrate(c *cli.Context) error {
+	content, err := ioutil.ReadFile(c.String("file"))
+	if err != nil {
+		return err
+	}
+	name := realize.Config()
+	// converted in place, or to a new config
+	src, _ := filepath.Abs(c.String("file"))
+	dst, _ := filepath.Abs(name)
+	if _, err := os.Stat(name); err == nil && src != dst {
+		return errors.New(name + " already exists")
+	}
+	m, dropped, err := realize.Migrate(content)
+	if err != nil {
+		return errors.New(c.String("file") + ": " + err.Error())
+	}
+	for _, v := range dropped {
+		log.Println(m.Prefix(realize.Yellow.Bold("not migrated: ") + v))
+	}
+	if err = m.Settings.Write(m); err != nil {
+		return err
+	}
+	log.Println(m.Prefix(realize.Green.Bold("Config successfully migrated to ") + name))
+	return nil
+}
+
+// Check the config, an error for the invalid config or the missing paths, and the suggestions with lint
+func check(c *cli.Context) error {
+	if err := r.Settings.Read(&r); err != nil {
+		return err
+	}
+	r.Schema.Interpolate()
+	if err := r.Check(os.Stdout); err != nil {
+		return err
+	}
+	if c.Bool("lint") {
+		if hints := r.Lint(os.Stdout); hints > 0 {
+			log.Println(r.Prefix(realize.Yellow.Bold(strconv.Itoa(hints) + " suggestions for the config")))
+		}
+	}
+	log.Println(r.Prefix(realize.Green.Bold("Config successfully checked")))
+	return nil
+}
+
+// Flags of the start, the config command resolves the config with the same ones
+func startFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Value: ".", Usage: "Project base path"},
+		&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Value: "", Usage: "Run a project by its name"},
+		&cli.BoolFlag{Name: "fmt", Aliases: []string{"f"}, Value: false, Usage: "Enable go fmt"},
+		&cli.BoolFlag{Name: "vet", Aliases: []string{"v"}, Value: false, Usage: "Enable go vet"},
+		&cli.BoolFlag{Name: "test", Aliases: []string{"t"}, Value: false, Usage: "Enable go test"},
+		&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Value: false, Usage: "Enable go generate"},
+		&cli.BoolFlag{Name: "server", Aliases: []string{"srv"}, Value: false, Usage: "Start server"},
+		&cli.BoolFlag{Name: "open", Aliases: []string{"op"}, Value: false, Usage: "Open into the default browser"},
+		&cli.BoolFlag{Name: "install", Aliases: []string{"i"}, Value: false, Usage: "Enable go install"},
+		&cli.BoolFlag{Name: "build", Aliases: []string{"b"}, Value: false, Usage: "Enable go build"},
+		&cli.BoolFlag{Name: "run", Aliases: []string{"nr"}, Value: false, Usage: "Enable go run"},
+		&cli.BoolFlag{Name: "legacy", Aliases: []string{"l"}, Value: false, Usage: "Legacy watch by polling instead fsnotify"},
+		&cli.BoolFlag{Name: "no-config", Aliases: []string{"nc"}, Value: false, Usage: "Ignore existing config and doesn't create a new one"},
+		&cli.BoolFlag{Name: "no-strict", Value: false, Usage: "Ignore the unknown keys of the config with a warning"},
+		&cli.BoolFlag{Name: "dry-run", Aliases: []string{"dr"}, Value: false, Usage: "Print the watched files and the commands without running them"},
+		&cli.StringFlag{Name: "log-format", Value: "", Usage: "Format of the log, text or json as a json object by line"},
+		&cli.StringFlag{Name: "log-file", Value: "", Usage: "Write also the whole output to a file, rotated as set by the config"},
+		&cli.StringFlag{Name: "profile", Aliases: []string{"pr"}, Value: os.Getenv("REALIZE_PROFILE"), Usage: "Add the commands of a profile, REALIZE_PROFILE by default"},
+		&cli.StringSliceFlag{Name: "set", Usage: "Override a config value of the projects as key=value, or append to a list as key+=value"},
+		&cli.StringSliceFlag{Name: "watch", Aliases: []string{"w"}, Usage: "Add a watched path"},
+		&cli.StringSliceFlag{Name: "ext", Aliases: []string{"e"}, Usage: "Add a watched extension"},
+		&cli.StringSliceFlag{Name: "ignore", Aliases: []string{"ig"}, Usage: "Add an ignored path"},
+		&cli.StringSliceFlag{Name: "cmd", Usage: "Add a command run after the tools"},
+	}
+}
+
+// Flags of a new project
+func projectFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "path", Aliases: []string{"p"}, Value: realize.Wdir(), Usage: "Project base path"},
+		&cli.BoolFlag{Name: "fmt", Aliases: []string{"f"}, Value: false, Usage: "Enable go fmt"},
+		&cli.BoolFlag{Name: "vet", Aliases: []string{"v"}, Value: false, Usage: "Enable go vet"},
+		&cli.BoolFlag{Name: "test", Aliases: []string{"t"}, Value: false, Usage: "Enable go test"},
+		&cli.BoolFlag{Name: "generate", Aliases: []string{"g"}, Value: false, Usage: "Enable go generate"},
+		&cli.BoolFlag{Name: "install", Aliases: []string{"i"}, Value: false, Usage: "Enable go install"},
+		&cli.BoolFlag{Name: "build", Aliases: []string{"b"}, Value: false, Usage: "Enable go build"},
+		&cli.BoolFlag{Name: "run", Aliases: []string{"nr"}, Value: false, Usage: "Enable go run"},
+	}
+}
+
+// Encrypt prints an encrypted value of the secrets, as a value of the config
+func encrypt(c *cli.Context) error {
+	if c.Bool("new-key") {
+		key, err := realize.NewKey()
 		if err != nil {
 			return err
 		}
-		log.Println(r.Prefix(realize.Green.Bold("project successfully added")))
-	} else {
-		log.Println(r.Prefix(realize.Green.Bold("project can't be added")))
+		fmt.Println(key)
+		return nil
+	}
+	value := c.Args().First()
+	if c.Args().Len() == 0 {
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimRight(string(in), "\r\n")
+	}
+	out, err := realize.Encrypt(value, os.Getenv(realize.SecretKey))
+	if err != nil {
+		return err
+	}
+	fmt.Println("{encrypted: " + out + "}")
+	return nil
+}
+
+// Add a project to an existing config or create a new one, of the given path if any
+func add(c *cli.Context, path string) (err error) {
+	// read a config if exist
+	if err = r.Settings.Read(&r); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	p := r.Schema.New(c)
+	if path != "" {
+		// the other args are the ones of the project
+		p.Name, p.Path, p.Args = filepath.Base(path), path, p.Args[1:]
+		if len(p.Args) == 0 {
+			p.Args = nil
+		}
+	}
+	for _, v := range r.Schema.Projects {
+		if v.Name == p.Name {
+			log.Println(r.Prefix(realize.Green.Bold("project can't be added")))
+			return nil
+		}
+	}
+	if err = r.Settings.AddProject(p); err != nil {
+		return err
+	}
+	log.Println(r.Prefix(realize.Green.Bold("project successfully added")))
+	return nil
+}
+
+// Task adds a command to the watcher scripts of a project
+func task(c *cli.Context) error {
+	if c.String("cmd") == "" {
+		return errors.New("task: the command is required, with --cmd")
+	}
+	cmd := realize.Command{
+		Cmd:   c.String("cmd"),
+		Type:  strings.ToLower(c.String("stage")),
+		Path:  c.String("path"),
+		Shell: c.Bool("shell"),
 	}
+	if err := r.Settings.AddCommand(c.String("name"), cmd); err != nil {
+		return err
+	}
+	log.Println(r.Prefix(realize.Green.Bold("task successfully added")))
 	return nil
 }
 
 // Setup a new config step by step
 func setup(c *cli.Context) (err error) {
+	// the setup found in the working dir
+	layout := realize.Detect(".")
+	var vet, test bool
 	interact.Run(&interact.Interact{
 		Before: func(context interact.Context) error {
 			context.SetErr(realize.Red.Bold("INVALID INPUT"))
@@ -159,7 +440,7 @@ func setup(c *cli.Context) (err error) {
 		Questions: []*interact.Question{
 			{
 				Before: func(d interact.Context) error {
-					if _, err := os.Stat(realize.RFile); err != nil {
+					if _, err := os.Stat(realize.Config()); err != nil {
 						d.Skip()
 					}
 					d.SetDef(false, realize.Green.Regular("(n)"))
@@ -180,6 +461,99 @@ func setup(c *cli.Context) (err error) {
 					return nil
 				},
 			},
+			{
+				Before: func(d interact.Context) error {
+					d.SetDef(true, realize.Green.Regular("(y)"))
+					return nil
+				},
+				Quest: interact.Quest{
+					Options: realize.Yellow.Regular("[y/n]"),
+					Msg:     "Would you want to use the detected setup? (" + realize.Magenta.Regular(layout.String()) + ")",
+					Resolve: func(d interact.Context) bool {
+						val, _ := d.Ans().Bool()
+						return val
+					},
+				},
+				Subs: []*interact.Question{
+					{
+						Before: func(d interact.Context) error {
+							if len(layout.Web) == 0 {
+								d.Skip()
+							}
+							d.SetDef(true, realize.Green.Regular("(y)"))
+							return nil
+						},
+						Quest: interact.Quest{
+							Options: realize.Yellow.Regular("[y/n]"),
+							Msg:     "Reload on the changes of the web assets",
+						},
+						Action: func(d interact.Context) interface{} {
+							val, err := d.Ans().Bool()
+							if err != nil {
+								return d.Err()
+							} else if !val {
+								layout.Web = nil
+							}
+							return nil
+						},
+					},
+					{
+						Before: func(d interact.Context) error {
+							d.SetDef(true, realize.Green.Regular("(y)"))
+							return nil
+						},
+						Quest: interact.Quest{
+							Options: realize.Yellow.Regular("[y/n]"),
+							Msg:     "Enable go vet and go fmt",
+						},
+						Action: func(d interact.Context) interface{} {
+							val, err := d.Ans().Bool()
+							if err != nil {
+								return d.Err()
+							}
+							vet = val
+							return nil
+						},
+					},
+					{
+						Before: func(d interact.Context) error {
+							// the tests of a library, the run of the commands otherwise
+							if len(layout.Cmds) > 0 {
+								d.SetDef(false, realize.Green.Regular("(n)"))
+							} else {
+								d.SetDef(true, realize.Green.Regular("(y)"))
+							}
+							return nil
+						},
+						Quest: interact.Quest{
+							Options: realize.Yellow.Regular("[y/n]"),
+							Msg:     "Enable go test",
+						},
+						Action: func(d interact.Context) interface{} {
+							val, err := d.Ans().Bool()
+							if err != nil {
+								return d.Err()
+							}
+							test = val
+							return nil
+						},
+					},
+				},
+				Action: func(d interact.Context) interface{} {
+					val, err := d.Ans().Bool()
+					if err != nil {
+						return d.Err()
+					} else if val {
+						r.Schema.Projects = layout.Projects()
+						for k := range r.Schema.Projects {
+							r.Schema.Projects[k].Tools.Vet.Status = vet
+							r.Schema.Projects[k].Tools.Fmt.Status = vet
+							r.Schema.Projects[k].Tools.Test.Status = test
+						}
+					}
+					return nil
+				},
+			},
 			{
 				Before: func(d interact.Context) error {
 					d.SetDef(false, realize.Green.Regular("(n)"))
@@ -363,7 +737,12 @@ func setup(c *cli.Context) (err error) {
 			},
 			{
 				Before: func(d interact.Context) error {
-					d.SetDef(true, realize.Green.Regular("(y)"))
+					// another project after the detected ones
+					if len(r.Schema.Projects) > 0 {
+						d.SetDef(false, realize.Green.Regular("(n)"))
+					} else {
+						d.SetDef(true, realize.Green.Regular("(y)"))
+					}
 					d.SetEnd("!")
 					return nil
 				},
@@ -1098,7 +1477,7 @@ func setup(c *cli.Context) (err error) {
 		},
 		After: func(d interact.Context) error {
 			if val, _ := d.Qns().Get(0).Ans().Bool(); val {
-				err := r.Settings.Remove(realize.RFile)
+				err := r.Settings.Remove(realize.Config())
 				if err != nil {
 					return err
 				}
@@ -1126,9 +1505,12 @@ func start(c *cli.Context) (err error) {
 		r.Server.Set(c.Bool("server"), c.Bool("open"), realize.Port, realize.Host)
 	}
 	// check no-config and read
+	r.Settings.Lax = c.Bool("no-strict")
 	if !c.Bool("no-config") {
 		// read a config if exist
-		r.Settings.Read(&r)
+		if err = r.Settings.Read(&r); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 		if c.String("name") != "" {
 			// filter by name flag if exist
 			r.Schema.Projects = r.Schema.Filter("Name", c.String("name"))
@@ -1141,6 +1523,9 @@ func start(c *cli.Context) (err error) {
 		}
 
 	}
+	logged(c, &r.Settings)
+	// env variables of the config values
+	r.Schema.Interpolate()
 	// check project list length
 	if len(r.Schema.Projects) <= 0 {
 		println("len", r.Schema.Projects)
@@ -1149,13 +1534,39 @@ func start(c *cli.Context) (err error) {
 		// Add to projects list
 		r.Schema.Add(project)
 		// save config
-		if !c.Bool("no-config") {
+		if !c.Bool("no-config") && !c.Bool("dry-run") {
 			err = r.Settings.Write(r)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	// flags over the config
+	if err = overrides(c, &r.Schema); err != nil {
+		return err
+	}
+	// print the plan only
+	if c.Bool("dry-run") {
+		return r.Plan(os.Stdout)
+	}
+	// read the config again on its changes, with the same flags
+	if !c.Bool("no-config") {
+		r.Load = func(n *realize.Realize) error {
+			if c.Bool("legacy") {
+				n.Settings.Legacy.Set(c.Bool("legacy"), 1)
+			}
+			n.Settings.Lax = c.Bool("no-strict")
+			if err := n.Settings.Read(n); err != nil {
+				return err
+			}
+			logged(c, &n.Settings)
+			if c.String("name") != "" {
+				n.Schema.Projects = n.Schema.Filter("Name", c.String("name"))
+			}
+			n.Schema.Interpolate()
+			return overrides(c, &n.Schema)
+		}
+	}
 	// Start web server
 	if r.Server.Status {
 		r.Server.Parent = &r
@@ -1168,10 +1579,85 @@ func start(c *cli.Context) (err error) {
 			return err
 		}
 	}
+	// keyboard shortcuts
+	go r.Shortcuts(os.Stdin)
 	// start workflow
 	return r.Start()
 }
 
+// Subcommand returns the command of realize named by the first arg after --, the one run by realize -- name in place of a bootstrap
+func subcommand(app *cli.App, args []string) string {
+	named := func(name string) bool {
+		for _, c := range app.Commands {
+			if name == c.Name {
+				return true
+			}
+			for _, a := range c.Aliases {
+				if name == a {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for i, v := range args {
+		switch {
+		case i == 0:
+		case v == "--":
+			if i+1 < len(args) && named(args[i+1]) {
+				return args[i+1]
+			}
+			return ""
+		case named(v):
+			// the -- of a command of realize, as realize start -- name
+			return ""
+		}
+	}
+	return ""
+}
+
+// Bootstrap runs a command on the changes of the go files of the working directory, without reading or writing a config
+func bootstrap(c *cli.Context) error {
+	r.Schema.Projects = []realize.Project{realize.Bootstrap(".", c.Args().Slice())}
+	// keyboard shortcuts
+	go r.Shortcuts(os.Stdin)
+	return r.Start()
+}
+
+// Logged sets the format and the file of the log of the flags over the settings
+func logged(c *cli.Context, s *realize.Settings) {
+	if c.String("log-format") != "" {
+		s.LogFormat = c.String("log-format")
+	}
+	if c.String("log-file") != "" {
+		s.LogFile.Name = c.String("log-file")
+	}
+}
+
+// Overrides sets the profile and the values of the flags of the projects, not saved in the config
+func overrides(c *cli.Context, s *realize.Schema) error {
+	if c.String("profile") != "" {
+		for k := range s.Projects {
+			s.Projects[k].Profile = c.String("profile")
+		}
+	}
+	sets := c.StringSlice("set")
+	for flag, key := range map[string]string{"watch": "watcher.paths", "ext": "watcher.extensions", "ignore": "watcher.ignored_paths"} {
+		for _, v := range c.StringSlice(flag) {
+			sets = append(sets, key+"+="+strconv.Quote(v))
+		}
+	}
+	for _, v := range c.StringSlice("cmd") {
+		sets = append(sets, "watcher.scripts+={type: after, command: "+strconv.Quote(v)+"}")
+	}
+	for _, v := range sets {
+		if err := s.Set(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Remove a project from an existing config
 func remove(c *cli.Context) (err error) {
 	// read a config if exist
@@ -1,16 +1,23 @@
 package realize
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-siris/siris/core/errors"
 	"go/build"
+	"gopkg.in/yaml.v2"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -25,6 +32,12 @@ var (
 	RFile = "." + RPrefix + RExt
 	//RExtWin windows extension
 	RExtWin = ".exe"
+	// stdin of the running command that reads the keyboard input
+	stdin input
+	// question of a command waiting for a keyboard answer
+	confirm prompt
+	// projects and settings swapped by a reload of the config, read by the shortcuts and the server
+	swap sync.RWMutex
 )
 
 type (
@@ -42,19 +55,37 @@ type (
 		Before   Func        `yaml:"-"  json:"-"`
 		Change   Func        `yaml:"-"  json:"-"`
 		Reload   Func        `yaml:"-"  json:"-"`
+		Result   Func        `yaml:"-"  json:"-"`
+		// reads the config again, the projects are restarted on the changes of their config if set
+		Load func(*Realize) error `yaml:"-" json:"-"`
 	}
 
 	// Context is used as argument for func
 	Context struct {
-		Path    string
-		Project *Project
-		Stop    <-chan bool
-		Watcher FileWatcher
-		Event   fsnotify.Event
+		Path     string
+		Paths    []string
+		Project  *Project
+		Ctx      context.Context
+		Watcher  FileWatcher
+		Event    fsnotify.Event
+		Response Response
 	}
 
 	// Func is used instead realize func
 	Func func(Context)
+
+	// input forwards the keyboard input to a running command
+	input struct {
+		sync.Mutex
+		w io.Writer
+	}
+
+	// prompt waits for a yes or no keyboard answer, one question at a time
+	prompt struct {
+		sync.Mutex
+		wait sync.Mutex
+		c    chan bool
+	}
 )
 
 // init check
@@ -73,6 +104,8 @@ func init() {
 
 // Stop realize workflow
 func (r *Realize) Stop() error {
+	swap.RLock()
+	defer swap.RUnlock()
 	for k := range r.Schema.Projects {
 		if r.Schema.Projects[k].exit != nil {
 			close(r.Schema.Projects[k].exit)
@@ -81,24 +114,453 @@ func (r *Realize) Stop() error {
 	return nil
 }
 
-// Start realize workflow
+// Start realize workflow, until the end of all the projects
+// with the load func, a change of the config restarts the projects with a different config
 func (r *Realize) Start() error {
-	if len(r.Schema.Projects) > 0 {
-		var wg sync.WaitGroup
-		wg.Add(len(r.Schema.Projects))
-		for k := range r.Schema.Projects {
-			r.Schema.Projects[k].exit = make(chan os.Signal, 1)
-			signal.Notify(r.Schema.Projects[k].exit, os.Interrupt)
-			r.Schema.Projects[k].parent = r
-			go r.Schema.Projects[k].Watch(&wg)
-		}
-		wg.Wait()
-	} else {
+	if len(r.Schema.Projects) == 0 {
 		return errors.New("there are no projects")
 	}
+	if err := r.Settings.formats(); err != nil {
+		return err
+	}
+	r.Settings.colors(r.Schema.Projects)
+	// the output is also written to the log file, if any
+	out, closeLog, err := r.Settings.LogFile.tee(Output)
+	if err != nil {
+		return err
+	}
+	defer func(w io.Writer) {
+		Output = w
+		closeLog()
+	}(Output)
+	Output = out
+	ended := make(chan *Project)
+	running := 0
+	start := func(p *Project) {
+		p.exit = make(chan os.Signal, 1)
+		p.resume = make(chan bool, 1)
+		p.trigger = make(chan bool, 1)
+		signal.Notify(p.exit, os.Interrupt, syscall.SIGTERM)
+		p.parent = r
+		running++
+		go func() {
+			var wg sync.WaitGroup
+			wg.Add(1)
+			p.Watch(&wg)
+			signal.Stop(p.exit)
+			ended <- p
+		}()
+	}
+	// the configs before the start, the running projects change their fields
+	configs, shared := snapshot(r)
+	swap.Lock()
+	for k := range r.Schema.Projects {
+		start(&r.Schema.Projects[k])
+	}
+	swap.Unlock()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	changes, watch, closer := r.configChanges()
+	defer closer()
+	for running > 0 {
+		select {
+		case <-ended:
+			running--
+		case <-sig:
+			// the projects stop by their own signal
+			changes = nil
+		case <-changes:
+			n := Realize{}
+			if err := r.Load(&n); err != nil {
+				leveled("error", r.Prefix(Red.Bold("config not reloaded: ")+err.Error()))
+				continue
+			}
+			// the includes of the new config
+			watch(&n)
+			if len(n.Schema.Projects) == 0 {
+				leveled("error", r.Prefix(Red.Bold("config not reloaded: ")+"there are no projects"))
+				continue
+			}
+			nconfigs, nshared := snapshot(&n)
+			// the same projects in the same order are restarted in place, all of them otherwise
+			same := bytes.Equal(shared, nshared) && len(configs) == len(nconfigs)
+			for k := 0; same && k < len(n.Schema.Projects); k++ {
+				same = n.Schema.Projects[k].Name == r.Schema.Projects[k].Name
+			}
+			var changed []int
+			for k := range r.Schema.Projects {
+				if !same || !bytes.Equal(configs[k], nconfigs[k]) {
+					changed = append(changed, k)
+				}
+			}
+			if same && len(changed) == 0 {
+				continue
+			}
+			// stop and wait the changed projects
+			waiting := make(map[*Project]bool)
+			for _, k := range changed {
+				p := &r.Schema.Projects[k]
+				signal.Stop(p.exit)
+				select {
+				case p.exit <- os.Interrupt:
+				default:
+				}
+				waiting[p] = true
+			}
+			for len(waiting) > 0 {
+				delete(waiting, <-ended)
+				running--
+			}
+			select {
+			case <-sig:
+				// interrupted during the restart
+				changes = nil
+				continue
+			default:
+			}
+			var names []string
+			swap.Lock()
+			if same {
+				for _, k := range changed {
+					r.Schema.Projects[k] = n.Schema.Projects[k]
+					start(&r.Schema.Projects[k])
+					names = append(names, r.Schema.Projects[k].Name)
+				}
+			} else {
+				r.Settings, r.Schema = n.Settings, n.Schema
+				for k := range r.Schema.Projects {
+					start(&r.Schema.Projects[k])
+					names = append(names, r.Schema.Projects[k].Name)
+				}
+			}
+			r.Settings.tints(r.Schema.Projects)
+			swap.Unlock()
+			configs, shared = nconfigs, nshared
+			log.Println(r.Prefix(Green.Bold("config reloaded, restarted: ") + strings.Join(names, ", ")))
+		}
+	}
 	return nil
 }
 
+// Snapshot returns the config of each project and the config shared by all of them
+func snapshot(r *Realize) (configs [][]byte, shared []byte) {
+	for _, p := range r.Schema.Projects {
+		y, _ := yaml.Marshal(p)
+		configs = append(configs, y)
+	}
+	shared, _ = yaml.Marshal(struct {
+		Settings Settings
+		Scripts  map[string]Command
+		Ignore   []string
+	}{r.Settings, r.Schema.Scripts, r.Schema.Ignore})
+	return configs, shared
+}
+
+// ConfigChanges watches the config and its includes if there is a load func, the changes are debounced
+// the watched files are set again by the returned func, with the includes of a new config
+func (r *Realize) configChanges() (<-chan bool, func(*Realize), func()) {
+	if r.Load == nil {
+		return nil, func(*Realize) {}, func() {}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		leveled("error", r.Prefix(Red.Bold("config not watched: ")+err.Error()))
+		return nil, func(*Realize) {}, func() {}
+	}
+	var mu sync.Mutex
+	files, dirs := make(map[string]bool), make(map[string]bool)
+	watch := func(n *Realize) {
+		mu.Lock()
+		defer mu.Unlock()
+		files = make(map[string]bool)
+		watched := make(map[string]bool)
+		name := Config()
+		for _, v := range append([]string{name, UserConfig()}, n.Schema.Include...) {
+			if !filepath.IsAbs(v) && v != name {
+				v = filepath.Join(filepath.Dir(name), v)
+			}
+			if abs, err := filepath.Abs(v); err == nil {
+				files[abs] = true
+				// the dir, for the editors replacing the file
+				watched[filepath.Dir(abs)] = true
+				if !dirs[filepath.Dir(abs)] {
+					watcher.Add(filepath.Dir(abs))
+				}
+			}
+		}
+		for dir := range dirs {
+			if !watched[dir] {
+				watcher.Remove(dir)
+			}
+		}
+		dirs = watched
+	}
+	watch(r)
+	changes := make(chan bool, 1)
+	done := make(chan bool)
+	go func() {
+		var debounce <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				abs, err := filepath.Abs(event.Name)
+				mu.Lock()
+				config := err == nil && files[abs]
+				mu.Unlock()
+				if config && event.Op != fsnotify.Chmod {
+					debounce = time.After(Debounce)
+				}
+			case <-debounce:
+				select {
+				case changes <- true:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return changes, watch, func() {
+		close(done)
+		watcher.Close()
+	}
+}
+
+// Resolve applies the os overrides, the profile, the named commands and the defaults of all the projects, as at their start
+func (r *Realize) Resolve() {
+	for k := range r.Schema.Projects {
+		p := &r.Schema.Projects[k]
+		p.parent = r
+		p.prepare()
+		p.Watcher.Ignore = p.ignores()
+	}
+	// the named commands, the shared ignored paths, the includes and the templates are in the projects
+	r.Schema.Scripts, r.Schema.Ignore, r.Schema.Include, r.Schema.Templates = nil, nil, nil, nil
+	if r.Server.Host == "" {
+		r.Server.Host = Host
+	}
+	if r.Server.Port == 0 {
+		r.Server.Port = Port
+	}
+}
+
+// Print writes the config as yaml, or as json with the same keys
+func (r *Realize) Print(w io.Writer, format string) error {
+	y, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "yaml", "":
+	case "json":
+		var v interface{}
+		if err = yaml.Unmarshal(y, &v); err != nil {
+			return err
+		}
+		if y, err = json.MarshalIndent(plain(v), "", "  "); err != nil {
+			return err
+		}
+		y = append(y, '\n')
+	default:
+		return errors.New("format " + format + ": expected yaml or json")
+	}
+	_, err = w.Write(y)
+	return err
+}
+
+// Plan prints the watched files and the commands of all the projects, nothing is run
+func (r *Realize) Plan(w io.Writer) error {
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].parent = r
+		if err := r.Schema.Projects[k].Plan(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check prints the problems of all the projects, an error is returned if any
+func (r *Realize) Check(w io.Writer) error {
+	errs := 0
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].parent = r
+		errs += r.Schema.Projects[k].Check(w)
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d errors in the config", errs)
+	}
+	return nil
+}
+
+// Check validates the values of the settings and of the schema
+func (r *Realize) check() error {
+	err := r.Schema.check()
+	if v := r.Settings.Verbosity; v != "" && v != "quiet" && v != "verbose" {
+		msg := fmt.Sprintf("settings: verbosity: %q is not a valid verbosity, expected quiet or verbose", v)
+		if err != nil {
+			msg = err.Error() + "\n" + msg
+		}
+		err = errors.New(msg)
+	}
+	if e := r.Settings.format(); e != nil {
+		msg := e.Error()
+		if err != nil {
+			msg = err.Error() + "\n" + msg
+		}
+		err = errors.New(msg)
+	}
+	return err
+}
+
+// Lint prints the suggestions of the projects, they aren't errors
+func (r *Realize) Lint(w io.Writer) (hints int) {
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].parent = r
+		hints += r.Schema.Projects[k].Lint(w)
+	}
+	return hints
+}
+
+// Shortcuts reads the keyboard commands, "p" pauses or resumes all the projects, "r" reloads them
+// "y" or "n" answer a waiting confirmation, other lines are forwarded to the running command with the stdin option
+func (r *Realize) Shortcuts(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		switch strings.TrimSpace(scanner.Text()) {
+		case "p":
+			if r.Paused() {
+				r.Resume()
+				log.Println(r.Prefix(Green.Bold("resumed")))
+			} else {
+				r.Pause()
+				log.Println(r.Prefix(Yellow.Bold("paused, press p to resume")))
+			}
+		case "r":
+			r.Trigger()
+		default:
+			if !confirm.answer(scanner.Text()) {
+				stdin.write(scanner.Text() + "\n")
+			}
+		}
+	}
+}
+
+// Ask waits for an answer, the default one after the timeout, false if the context is done
+func (q *prompt) ask(ctx context.Context, timeout time.Duration, def bool) bool {
+	q.wait.Lock()
+	defer q.wait.Unlock()
+	c := make(chan bool, 1)
+	q.Lock()
+	q.c = c
+	q.Unlock()
+	defer func() {
+		q.Lock()
+		q.c = nil
+		q.Unlock()
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case v := <-c:
+		return v
+	case <-expired:
+		return def
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Answer sends a yes or no line to the waiting question, false if there isn't one or the line isn't an answer
+func (q *prompt) answer(line string) bool {
+	q.Lock()
+	defer q.Unlock()
+	if q.c == nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		q.c <- true
+	case "n", "no":
+		q.c <- false
+	default:
+		return false
+	}
+	q.c = nil
+	return true
+}
+
+// Set the writer of the keyboard input
+func (i *input) set(w io.Writer) {
+	i.Lock()
+	defer i.Unlock()
+	i.w = w
+}
+
+// Unset the writer of the keyboard input, if still the given one
+func (i *input) unset(w io.Writer) {
+	i.Lock()
+	defer i.Unlock()
+	if i.w == w {
+		i.w = nil
+	}
+}
+
+// Write a line to the running command, discarded if there isn't one
+func (i *input) write(line string) {
+	i.Lock()
+	defer i.Unlock()
+	if i.w != nil {
+		io.WriteString(i.w, line)
+	}
+}
+
+// Pause all the projects
+func (r *Realize) Pause() {
+	swap.RLock()
+	defer swap.RUnlock()
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].Pause()
+	}
+}
+
+// Resume all the projects
+func (r *Realize) Resume() {
+	swap.RLock()
+	defer swap.RUnlock()
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].Resume()
+	}
+}
+
+// Trigger a reload of all the projects
+func (r *Realize) Trigger() {
+	swap.RLock()
+	defer swap.RUnlock()
+	for k := range r.Schema.Projects {
+		r.Schema.Projects[k].Trigger()
+	}
+}
+
+// Paused checks if at least a project is paused
+func (r *Realize) Paused() bool {
+	swap.RLock()
+	defer swap.RUnlock()
+	for k := range r.Schema.Projects {
+		if r.Schema.Projects[k].Paused() {
+			return true
+		}
+	}
+	return false
+}
+
 // Prefix a given string with tool name
 func (r *Realize) Prefix(input string) string {
 	if len(input) > 0 {
@@ -109,8 +571,20 @@ func (r *Realize) Prefix(input string) string {
 
 // Rewrite the layout of the log timestamp
 func (w LogWriter) Write(bytes []byte) (int, error) {
+	if len(bytes) > 0 && logFormat == "json" {
+		parse(string(bytes)).emit(Output)
+		return len(bytes), nil
+	}
 	if len(bytes) > 0 {
-		return fmt.Fprint(Output, Yellow.Regular("["), time.Now().Format("15:04:05"), Yellow.Regular("]"), string(bytes))
+		msg := string(bytes)
+		if ts := clock(time.Now()); ts != "" {
+			msg = Yellow.Regular("[") + ts + Yellow.Regular("]") + msg
+		}
+		if uncolored(string(bytes)) {
+			msg = ansi.ReplaceAllString(msg, "")
+		}
+		_, err := fmt.Fprint(Output, msg)
+		return len(bytes), err
 	}
 	return 0, nil
 }
@@ -2,8 +2,11 @@ package realize
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -25,14 +28,19 @@ func TestRealize_Start(t *testing.T) {
 	if err == nil {
 		t.Error("Error expected")
 	}
-	r.Projects = append(r.Projects, Project{Name: "test", exit: make(chan os.Signal, 1)})
+	r.Projects = append(r.Projects, Project{Name: "test"})
 	go func() {
-		time.Sleep(100)
-		close(r.Projects[0].exit)
-		_, ok := <-r.Projects[0].exit
-		if ok != false {
-			t.Error("Unexpected error", "channel should be closed")
+		// stopped once started
+		for {
+			swap.RLock()
+			started := r.Projects[0].exit != nil
+			swap.RUnlock()
+			if started {
+				break
+			}
+			time.Sleep(time.Millisecond)
 		}
+		r.Stop()
 	}()
 	err = r.Start()
 	if err != nil {
@@ -40,6 +48,98 @@ func TestRealize_Start(t *testing.T) {
 	}
 }
 
+func TestRealize_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	loaded := make(chan bool, 1)
+	r := Realize{}
+	r.Projects = []Project{{Name: "one", Path: dir}, {Name: "two", Path: dir}}
+	r.Load = func(n *Realize) error {
+		n.Projects = []Project{{Name: "one", Path: dir}, {Name: "two", Path: dir, Args: []string{"--changed"}}}
+		// an include added by the new config
+		n.Schema.Include = []string{"shared.yaml"}
+		loaded <- true
+		return nil
+	}
+	done := make(chan error)
+	go func() {
+		done <- r.Start()
+	}()
+	// the shortcuts during the reloads
+	in, keys := io.Pipe()
+	defer keys.Close()
+	go r.Shortcuts(in)
+	time.Sleep(100 * time.Millisecond)
+	if err := ioutil.WriteFile(RFile, []byte("schema:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(keys, "p\np\n")
+	select {
+	case <-loaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Config not reloaded")
+	}
+	time.Sleep(200 * time.Millisecond)
+	// the include is watched after the reload
+	if err := ioutil.WriteFile(filepath.Join(dir, "shared.yaml"), []byte("settings:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Include not watched")
+	}
+	r.Stop()
+	if err := <-done; err != nil {
+		t.Error("Unexpected error", err)
+	}
+	// only the changed project is restarted
+	if !strings.Contains(buf.String(), "restarted: two\n") || len(r.Projects[1].Args) != 1 {
+		t.Error("Unexpected restart", buf.String())
+	}
+}
+
+func TestRealize_Resolve(t *testing.T) {
+	r := Realize{}
+	r.Schema.Scripts = map[string]Command{"test": {Cmd: "go test ./...", Type: "after"}}
+	r.Schema.Ignore = []string{"vendor"}
+	r.Projects = []Project{{Name: "app", Profile: "dev", Profiles: map[string][]Command{"dev": {{Type: "after", Cmd: "./app"}}}, Watcher: Watch{Ignore: []string{"tmp"}, Scripts: []Command{{Use: "test", StopSignal: "SIGTERM"}}, Triggers: []Trigger{{Scripts: []Command{{Cmd: "make", StopSignal: "SIGINT"}}}}}}}
+	r.Resolve()
+	p := r.Projects[0]
+	if len(p.Watcher.Scripts) != 2 || p.Watcher.Scripts[0].Cmd != "go test ./..." || p.Watcher.Scripts[0].StopTimeout != Grace || p.Watcher.Scripts[1].Cmd != "./app" {
+		t.Error("Unexpected scripts", p.Watcher.Scripts)
+	}
+	if p.Watcher.Triggers[0].Scripts[0].StopTimeout != Grace {
+		t.Error("Unexpected trigger scripts", p.Watcher.Triggers)
+	}
+	if p.Watcher.Debounce != Debounce || p.StopOnError == nil || !*p.StopOnError || p.Profile != "" || strings.Join(p.Watcher.Ignore, ",") != "vendor,tmp" {
+		t.Error("Unexpected project", p)
+	}
+	if r.Schema.Scripts != nil || r.Schema.Ignore != nil || r.Server.Port != Port {
+		t.Error("Unexpected config", r.Schema, r.Server)
+	}
+	var buf bytes.Buffer
+	if err := r.Print(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"command": "go test ./..."`) || !strings.Contains(buf.String(), `"debounce": "300ms"`) {
+		t.Error("Unexpected json", buf.String())
+	}
+	if err := r.Print(&buf, "xml"); err == nil {
+		t.Error("Expected an error for the format")
+	}
+}
+
 func TestRealize_Prefix(t *testing.T) {
 	r := Realize{}
 	input := "test"
@@ -59,3 +159,23 @@ func TestLogWriter_Write(t *testing.T) {
 		t.Error("Unexpected error", err, "string length should be 0 instead", val)
 	}
 }
+
+func TestRealize_Shortcuts(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{Name: "test"}, Project{Name: "testing"})
+	r.Shortcuts(strings.NewReader("p\n"))
+	if !r.Projects[0].Paused() || !r.Projects[1].Paused() {
+		t.Error("Expected paused projects")
+	}
+	r.Shortcuts(strings.NewReader("p\n"))
+	if r.Paused() {
+		t.Error("Expected resumed projects")
+	}
+	r.Projects[0].trigger = make(chan bool, 1)
+	r.Shortcuts(strings.NewReader("r\nr\n"))
+	if len(r.Projects[0].trigger) != 1 {
+		t.Error("Expected a reload of the project")
+	}
+}
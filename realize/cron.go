@@ -0,0 +1,143 @@
+package realize
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron schedule, a fixed interval or the minutes, hours, days of month, months and days of week of a cron expression
+type cron struct {
+	every  time.Duration
+	fields [5]uint64
+	// day of month or day of week restricted, a day matches if one of them matches
+	dom, dow bool
+}
+
+// min and max value of each field of a cron expression
+var bounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// cron descriptors
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses an interval as 5m or @every 5m, a descriptor as @hourly, or a cron expression as */5 * * * *
+func parseCron(s string) (c cron, err error) {
+	s = strings.TrimSpace(s)
+	if v, ok := descriptors[s]; ok {
+		s = v
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(s, "@every"))); err == nil {
+		if d <= 0 {
+			return c, errors.New("schedule " + s + ": the interval must be positive")
+		}
+		c.every = d
+		return c, nil
+	}
+	values := strings.Fields(s)
+	if len(values) != len(c.fields) {
+		return c, errors.New("schedule " + s + ": expected an interval or 5 fields")
+	}
+	for i, v := range values {
+		if c.fields[i], err = field(v, bounds[i][0], bounds[i][1]); err != nil {
+			return c, errors.New("schedule " + s + ": " + err.Error())
+		}
+	}
+	// sunday as 7
+	if c.fields[4]&(1<<7) != 0 {
+		c.fields[4] |= 1
+	}
+	c.dom = values[2] != "*"
+	c.dow = values[4] != "*"
+	return c, nil
+}
+
+// Field parses a field of a cron expression as a bitmask, a list of values, ranges and steps as 1,10-20/2 or */5
+func field(s string, min, max int) (mask uint64, err error) {
+	if min == 0 && max == 6 {
+		// sunday as 7
+		max = 7
+	}
+	for _, v := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(v, "/"); i >= 0 {
+			if step, err = strconv.Atoi(v[i+1:]); err != nil || step <= 0 {
+				return 0, errors.New("invalid step " + v)
+			}
+			v = v[:i]
+		}
+		start, end := min, max
+		if v != "*" {
+			r := strings.SplitN(v, "-", 2)
+			if start, err = strconv.Atoi(r[0]); err != nil {
+				return 0, errors.New("invalid value " + v)
+			}
+			end = start
+			if len(r) == 2 {
+				if end, err = strconv.Atoi(r[1]); err != nil {
+					return 0, errors.New("invalid value " + v)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, errors.New("out of range " + v)
+		}
+		for i := start; i <= end; i += step {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask, nil
+}
+
+// Next returns the first time of the schedule after the given one
+func (c cron) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// the days of the schedule are found within a few years, a 29 february of a leap year
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		if !c.match(3, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.day(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.match(1, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.match(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Match checks a value of a field
+func (c cron) match(i, v int) bool {
+	return c.fields[i]&(1<<uint(v)) != 0
+}
+
+// Day checks the day of month and the day of week, one of them if both are restricted
+func (c cron) day(t time.Time) bool {
+	dom, dow := c.match(2, t.Day()), c.match(4, int(t.Weekday()))
+	if c.dom && c.dow {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,45 @@
+package realize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, v := range []string{"* * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0", "-5m"} {
+		if _, err := parseCron(v); err == nil {
+			t.Error("Expected an error for", v)
+		}
+	}
+	c, err := parseCron("@every 5m")
+	if err != nil || c.every != 5*time.Minute {
+		t.Error("Unexpected interval", c.every, err)
+	}
+	if c, err = parseCron("1,10-20/5 * * * 7"); err != nil {
+		t.Fatal(err)
+	}
+	if c.fields[0] != 1<<1|1<<10|1<<15|1<<20 || !c.match(4, 0) {
+		t.Error("Unexpected fields", c.fields)
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	now := time.Date(2018, 2, 27, 10, 30, 15, 0, time.UTC)
+	data := map[string]time.Time{
+		"10m":          now.Add(10 * time.Minute),
+		"@hourly":      time.Date(2018, 2, 27, 11, 0, 0, 0, time.UTC),
+		"*/20 * * * *": time.Date(2018, 2, 27, 10, 40, 0, 0, time.UTC),
+		"0 9 * * 1-5":  time.Date(2018, 2, 28, 9, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":   time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 3":    time.Date(2018, 2, 28, 0, 0, 0, 0, time.UTC),
+	}
+	for s, expected := range data {
+		c, err := parseCron(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := c.next(now); !next.Equal(expected) {
+			t.Error("Unexpected next time of", s, next, "instead", expected)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package realize
+
+import (
+	"bufio"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layout of a dir, the go module, the main packages and the web assets found in it
+type Layout struct {
+	Dir    string
+	Module string
+	// dirs of the main packages, relative to the dir, as cmd/api
+	Cmds []string
+	// dirs of the web assets, relative to the dir
+	Web []string
+	// a package.json, the node modules are ignored
+	Node bool
+}
+
+// dirs usually holding the web assets
+var webDirs = []string{"web", "static", "public", "assets", "templates", "views"}
+
+// extensions of the web assets
+var webExts = []string{"html", "tmpl", "css", "js"}
+
+// Detect inspects a dir, its go.mod, the main packages in cmd or in the dir itself and the web assets
+func Detect(dir string) (l Layout) {
+	l.Dir = dir
+	if f, err := os.Open(filepath.Join(dir, "go.mod")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "module" {
+				l.Module = strings.Trim(fields[1], "\"")
+				break
+			}
+		}
+		f.Close()
+	}
+	if dirs, err := ioutil.ReadDir(filepath.Join(dir, "cmd")); err == nil {
+		for _, d := range dirs {
+			if d.IsDir() && isMain(filepath.Join(dir, "cmd", d.Name())) {
+				l.Cmds = append(l.Cmds, filepath.Join("cmd", d.Name()))
+			}
+		}
+	}
+	if len(l.Cmds) == 0 && isMain(dir) {
+		l.Cmds = append(l.Cmds, ".")
+	}
+	for _, v := range webDirs {
+		if fi, err := os.Stat(filepath.Join(dir, v)); err == nil && fi.IsDir() {
+			l.Web = append(l.Web, v)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+		l.Node = true
+	}
+	return l
+}
+
+// IsMain checks if a dir holds a main package
+func isMain(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".go" || strings.HasSuffix(f.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, f.Name()), nil, parser.PackageClauseOnly)
+		if err == nil && file.Name.Name == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// Projects returns a project installed and run for each main package, a single one vetted and tested without them
+// all of them watch the whole dir, with the web assets if any
+func (l Layout) Projects() []Project {
+	watch := Watch{
+		Ignore: append([]string{}, defaultIgnore...),
+		Exts:   []string{"go"},
+	}
+	if l.Node {
+		watch.Ignore = append(watch.Ignore, "node_modules")
+	}
+	if len(l.Web) > 0 {
+		watch.Exts = append(watch.Exts, webExts...)
+	}
+	name := filepath.Base(l.Module)
+	if l.Module == "" {
+		abs, _ := filepath.Abs(l.Dir)
+		name = filepath.Base(abs)
+	}
+	if len(l.Cmds) == 0 {
+		watch.Paths = []string{"/"}
+		return []Project{{
+			Name:    name,
+			Path:    l.Dir,
+			Tools:   Tools{Vet: Tool{Status: true}, Test: Tool{Status: true}},
+			Watcher: watch,
+		}}
+	}
+	var projects []Project
+	for _, v := range l.Cmds {
+		p := Project{
+			Name:    filepath.Base(v),
+			Path:    filepath.Join(l.Dir, v),
+			Tools:   Tools{Install: Tool{Status: true}, Run: Tool{Status: true}},
+			Watcher: watch,
+		}
+		if v == "." {
+			p.Name = name
+		}
+		// the paths are relative to the project, the ignored ones too
+		p.Watcher.Paths = []string{"/"}
+		p.Watcher.Ignore = append([]string{}, watch.Ignore...)
+		if root, _ := filepath.Rel(v, "."); root != "." {
+			p.Watcher.Paths = []string{filepath.ToSlash(root)}
+			for i, ignore := range p.Watcher.Ignore {
+				p.Watcher.Ignore[i] = filepath.ToSlash(filepath.Join(root, ignore))
+			}
+		}
+		p.Watcher.Exts = append([]string{}, watch.Exts...)
+		projects = append(projects, p)
+	}
+	return projects
+}
+
+// Bootstrap returns a project running a command on the changes of the go files of a dir, without a config
+// the command is run as a daemon with its output, restarted on each change, its args aren't split again
+func Bootstrap(dir string, args []string) Project {
+	abs, _ := filepath.Abs(dir)
+	return Project{
+		Name: filepath.Base(abs),
+		Path: dir,
+		Watcher: Watch{
+			Paths:  []string{"/"},
+			Exts:   []string{"go"},
+			Ignore: append(append([]string{}, defaultIgnore...), "node_modules"),
+			Scripts: []Command{{
+				Type:   "after",
+				Cmd:    strings.Join(args, " "),
+				Daemon: true,
+				Output: true,
+				args:   append([]string{}, args...),
+			}},
+		},
+	}
+}
+
+// String describes the layout, as module m, main packages cmd/api, web assets web
+func (l Layout) String() string {
+	var s []string
+	if l.Module != "" {
+		s = append(s, "module "+l.Module)
+	}
+	if len(l.Cmds) > 0 {
+		s = append(s, "main packages "+strings.Join(l.Cmds, ", "))
+	} else {
+		s = append(s, "no main packages")
+	}
+	if len(l.Web) > 0 {
+		s = append(s, "web assets "+strings.Join(l.Web, ", "))
+	}
+	return strings.Join(s, ", ")
+}
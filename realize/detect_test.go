@@ -0,0 +1,83 @@
+package realize
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.mod":              "module github.com/oxequa/app\n\nrequire gopkg.in/yaml.v2 v2.2.1\n",
+		"package.json":        "{}",
+		"app.go":              "package app\n",
+		"cmd/api/main.go":     "// api server\npackage main\n",
+		"cmd/api/api_test.go": "package main\n",
+		"cmd/lib/lib.go":      "package lib\n",
+		"cmd/tool/main.go":    "package main\n",
+		"static/index.html":   "<html></html>",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := Detect(dir)
+	expected := Layout{Dir: dir, Module: "github.com/oxequa/app", Cmds: []string{filepath.Join("cmd", "api"), filepath.Join("cmd", "tool")}, Web: []string{"static"}, Node: true}
+	if !reflect.DeepEqual(l, expected) {
+		t.Errorf("Unexpected layout %#v", l)
+	}
+	projects := l.Projects()
+	if len(projects) != 2 || projects[0].Name != "api" || projects[0].Path != filepath.Join(dir, "cmd", "api") {
+		t.Fatalf("Unexpected projects %#v", projects)
+	}
+	p := projects[1]
+	if !p.Tools.Install.Status || !p.Tools.Run.Status || !reflect.DeepEqual(p.Watcher.Paths, []string{"../.."}) {
+		t.Errorf("Unexpected project %#v", p)
+	}
+	if !reflect.DeepEqual(p.Watcher.Ignore, []string{"../../.git", "../../.realize", "../../vendor", "../../node_modules"}) || !reflect.DeepEqual(p.Watcher.Exts, []string{"go", "html", "tmpl", "css", "js"}) {
+		t.Errorf("Unexpected watcher %#v", p.Watcher)
+	}
+	// the ignored paths of the root
+	if p.Validate(filepath.Join(dir, "vendor", "x", "x.go"), false) || !p.Validate(filepath.Join(dir, "app.go"), false) {
+		t.Error("Expected the vendor dir of the root to be ignored")
+	}
+	// a library, without main packages
+	os.RemoveAll(filepath.Join(dir, "cmd"))
+	l = Detect(dir)
+	l.Web = nil
+	if projects = l.Projects(); len(projects) != 1 || projects[0].Name != "app" || projects[0].Tools.Run.Status || !projects[0].Tools.Test.Status || !reflect.DeepEqual(projects[0].Watcher.Paths, []string{"/"}) {
+		t.Errorf("Unexpected projects %#v", projects)
+	}
+	if s := l.String(); s != "module github.com/oxequa/app, no main packages" {
+		t.Error("Unexpected description", s)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	p := Bootstrap(".", []string{"go", "run", "./cmd/server", "-addr", ":8080 local", `it's`, `C:\`})
+	if p.Name != filepath.Base(Wdir()) || p.Path != "." || !reflect.DeepEqual(p.Watcher.Paths, []string{"/"}) || !reflect.DeepEqual(p.Watcher.Exts, []string{"go"}) {
+		t.Error("Unexpected project", p)
+	}
+	if len(p.Watcher.Scripts) != 1 || !p.Watcher.Scripts[0].Daemon || p.Watcher.Scripts[0].Type != "after" {
+		t.Fatal("Unexpected scripts", p.Watcher.Scripts)
+	}
+	if args := p.Watcher.Scripts[0].args; !reflect.DeepEqual(args, []string{"go", "run", "./cmd/server", "-addr", ":8080 local", `it's`, `C:\`}) {
+		t.Error("Unexpected command", args)
+	}
+	// the args are run as given, a trailing backslash included
+	c := Bootstrap(".", []string{"echo", `C:\`, "a  b"}).Watcher.Scripts[0]
+	c.Daemon, c.Output = false, false
+	if r := c.exec(context.Background(), Wdir()); r.Err != nil || r.Out != "C:\\ a  b\n" {
+		t.Errorf("Unexpected output %q %v", r.Out, r.Err)
+	}
+}
@@ -0,0 +1,354 @@
+package realize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// AddProject appends a project to the config, to its projects list if there isn't the schema one
+// a relative path of the project is one of the working directory, written relative to the config
+func (s *Settings) AddProject(p Project) error {
+	dir, _ := filepath.Abs(filepath.Dir(Config()))
+	p.Path = Schema{}.relative(dir, p.Path)
+	return s.edit(func(doc map[interface{}]interface{}) ([]interface{}, error) {
+		if doc["schema"] == nil && doc["projects"] != nil {
+			return []interface{}{"projects"}, nil
+		}
+		return []interface{}{"schema"}, nil
+	}, p)
+}
+
+// AddCommand appends a command to the watcher scripts of a project of the config by its name, the only project without a name
+func (s *Settings) AddCommand(name string, c Command) error {
+	return s.edit(func(doc map[interface{}]interface{}) ([]interface{}, error) {
+		var found [][]interface{}
+		for _, key := range []string{"schema", "projects"} {
+			list, _ := doc[key].([]interface{})
+			for i, v := range list {
+				m, _ := v.(map[interface{}]interface{})
+				if name == "" || m != nil && fmt.Sprint(m["name"]) == name {
+					found = append(found, []interface{}{key, i, "watcher", "scripts"})
+				}
+			}
+		}
+		switch {
+		case len(found) == 1:
+			return found[0], nil
+		case name == "" && len(found) > 1:
+			return nil, errors.New("more projects in " + Config() + ", set the project by its name")
+		case name == "":
+			return nil, errors.New("no projects in " + Config())
+		}
+		return nil, errors.New("project " + name + " not found in " + Config())
+	}, c)
+}
+
+// Edit appends an item to a list of the config, the lines of the config are added to keep its comments and its format
+// the config is written again as a whole only without comments if the new lines don't give the same values, and restored if it isn't valid
+func (s *Settings) edit(path func(map[interface{}]interface{}) ([]interface{}, error), item interface{}) error {
+	name := Config()
+	content, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	y, err := decode(name, content)
+	if err != nil {
+		return err
+	}
+	var doc map[interface{}]interface{}
+	if err = yaml.Unmarshal(y, &doc); err != nil {
+		return lines(name, err)
+	}
+	if doc == nil {
+		doc = map[interface{}]interface{}{}
+	}
+	keys, err := path(doc)
+	if err != nil {
+		return err
+	}
+	// the values of the item as decoded from the config
+	var v interface{}
+	if y, err = yaml.Marshal(item); err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(y, &v); err != nil {
+		return err
+	}
+	expected := appendAt(doc, keys, v)
+	var edited []byte
+	switch filepath.Ext(name) {
+	case ".json":
+	case ".toml":
+		// a project as a new table of the schema, at the end of the config
+		if len(keys) == 1 && keys[0] == "schema" {
+			if t, err := toTOML(map[interface{}]interface{}{"schema": []interface{}{v}}); err == nil {
+				edited = append(append(append([]byte{}, content...), '\n'), t...)
+			}
+		}
+	default:
+		edited, _ = insert(content, keys, v)
+	}
+	var out []byte
+	if edited != nil {
+		var got interface{}
+		if y, err := decode(name, edited); err == nil && yaml.Unmarshal(y, &got) == nil && reflect.DeepEqual(got, expected) {
+			out = edited
+		}
+	}
+	if out == nil {
+		if commented(name, content) {
+			return errors.New(name + ": the comments of the config can't be kept, add it by hand")
+		}
+		if out, err = encode(name, expected); err != nil {
+			return err
+		}
+	}
+	if err = ioutil.WriteFile(name, out, Permission); err != nil {
+		return err
+	}
+	if err = s.Read(&Realize{}); err != nil {
+		if content == nil {
+			os.Remove(name)
+		} else {
+			ioutil.WriteFile(name, content, Permission)
+		}
+		return err
+	}
+	return nil
+}
+
+// Commented checks if a config has comments, the ones of a yaml config by its nodes and a # of a toml one
+func commented(name string, content []byte) bool {
+	switch filepath.Ext(name) {
+	case ".json":
+		return false
+	case ".toml":
+		return bytes.Contains(content, []byte("#"))
+	}
+	var doc yaml3.Node
+	if yaml3.Unmarshal(content, &doc) != nil {
+		return true
+	}
+	var comments func(n *yaml3.Node) bool
+	comments = func(n *yaml3.Node) bool {
+		if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+			return true
+		}
+		for _, c := range n.Content {
+			if comments(c) {
+				return true
+			}
+		}
+		return false
+	}
+	return comments(&doc)
+}
+
+// AppendAt returns a value with an item appended to the list of the given keys and indexes, the missing maps and list are added
+func appendAt(doc interface{}, keys []interface{}, v interface{}) interface{} {
+	if len(keys) == 0 {
+		return append(asList(doc), v)
+	}
+	if i, ok := keys[0].(int); ok {
+		list := asList(doc)
+		list[i] = appendAt(list[i], keys[1:], v)
+		return list
+	}
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		m = map[interface{}]interface{}{}
+	}
+	m[keys[0]] = appendAt(m[keys[0]], keys[1:], v)
+	return m
+}
+
+// Insert adds the lines of an item at the end of a list of a block yaml, or the lines of the missing keys at the end of their map
+// the flow values and the keys that aren't plain aren't found
+func insert(content []byte, keys []interface{}, v interface{}) ([]byte, bool) {
+	text := strings.Split(string(content), "\n")
+	// the region of a map, as its first and last lines, and the column of its keys
+	from, last, col := 0, -1, 0
+	for i := range text {
+		if significant(text[i]) {
+			last = i
+		}
+	}
+	for n := 0; n < len(keys); n++ {
+		key, ok := keys[n].(string)
+		if !ok {
+			return nil, false
+		}
+		i := find(text, from, last, col, key)
+		if i < 0 {
+			node, ok := nest(keys[n+1:], v)
+			if !ok {
+				return nil, false
+			}
+			return splice(text, last+1, map[interface{}]interface{}{key: node}, col)
+		}
+		if inline(text[i]) != "" {
+			// an inline value, as a flow list
+			return nil, false
+		}
+		from, last = i+1, block(text, i)
+		// the first line of the value, the column of its keys or of the dashes of its items
+		child := -1
+		for j := from; j <= last; j++ {
+			if significant(text[j]) {
+				child = j
+				break
+			}
+		}
+		index, item := 0, n+1 < len(keys)
+		if item {
+			index, item = keys[n+1].(int)
+		}
+		switch {
+		case n == len(keys)-1 || item:
+			if child < 0 {
+				if item {
+					return nil, false
+				}
+				// a new list, at the column of its key as written by the config
+				return splice(text, last+1, []interface{}{v}, column(text[i]))
+			}
+			if !dash(text[child]) {
+				return nil, false
+			}
+			var items []int
+			for j := child; j <= last; j++ {
+				if significant(text[j]) && dash(text[j]) && indent(text[j]) == indent(text[child]) {
+					items = append(items, j)
+				}
+			}
+			if !item {
+				return splice(text, last+1, []interface{}{v}, indent(text[child]))
+			}
+			if index >= len(items) {
+				return nil, false
+			}
+			// the lines of the item
+			from = items[index]
+			if index+1 < len(items) {
+				for last = items[index+1] - 1; !significant(text[last]); last-- {
+				}
+			}
+			col = column(text[from])
+			n++
+		case child < 0:
+			col = column(text[i]) + 2
+		default:
+			col = column(text[child])
+		}
+	}
+	return nil, false
+}
+
+// Find returns the line of a key of a map, -1 if missing
+func find(text []string, from, last, col int, key string) int {
+	for i := from; i <= last; i++ {
+		if significant(text[i]) && column(text[i]) == col && keyOf(text[i]) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Block returns the last line of the value of a key, the items of a list can be at the column of the key
+func block(text []string, i int) int {
+	c, last := column(text[i]), i
+	for j := i + 1; j < len(text); j++ {
+		if !significant(text[j]) {
+			continue
+		}
+		if indent(text[j]) < c || indent(text[j]) == c && !dash(text[j]) {
+			break
+		}
+		last = j
+	}
+	return last
+}
+
+// Nest returns the value of the missing keys of a list, with the item
+func nest(keys []interface{}, v interface{}) (interface{}, bool) {
+	if len(keys) == 0 {
+		return []interface{}{v}, true
+	}
+	key, ok := keys[0].(string)
+	if !ok {
+		return nil, false
+	}
+	node, ok := nest(keys[1:], v)
+	return map[interface{}]interface{}{key: node}, ok
+}
+
+// Splice adds the lines of a value at a column, before the given line
+func splice(text []string, at int, v interface{}, col int) ([]byte, bool) {
+	y, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var added []string
+	for _, l := range strings.Split(strings.TrimRight(string(y), "\n"), "\n") {
+		if l != "" {
+			l = strings.Repeat(" ", col) + l
+		}
+		added = append(added, l)
+	}
+	out := append(append(append([]string{}, text[:at]...), added...), text[at:]...)
+	return []byte(strings.Join(out, "\n")), true
+}
+
+// Significant checks if a line isn't empty or a comment
+func significant(l string) bool {
+	t := strings.TrimSpace(l)
+	return t != "" && !strings.HasPrefix(t, "#")
+}
+
+// Dash checks if a line is an item of a list
+func dash(l string) bool {
+	t := strings.TrimSpace(l)
+	return t == "-" || strings.HasPrefix(t, "- ")
+}
+
+// Indent returns the number of the leading spaces of a line
+func indent(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+// Column returns the column of the key of a line, after the dashes of the items
+func column(l string) int {
+	i := indent(l)
+	for i < len(l) && l[i] == '-' && (i+1 == len(l) || l[i+1] == ' ') {
+		for i++; i < len(l) && l[i] == ' '; i++ {
+		}
+	}
+	return i
+}
+
+// KeyOf returns the plain key of a line, empty if not a key
+func keyOf(l string) string {
+	t := strings.TrimRight(l[column(l):], "\r")
+	n := strings.Index(t, ":")
+	if n <= 0 || n+1 < len(t) && t[n+1] != ' ' {
+		return ""
+	}
+	return t[:n]
+}
+
+// Inline returns the inline value of a key, without its comment
+func inline(l string) string {
+	t := strings.TrimSpace(l[column(l)+len(keyOf(l))+1:])
+	if strings.HasPrefix(t, "#") {
+		return ""
+	}
+	return t
+}
@@ -0,0 +1,150 @@
+package realize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSettings_AddCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	config := `# shared settings
+settings:
+  legacy:
+    force: false   # fsnotify
+schema:
+- name: app
+  watcher:
+    paths: ["/"]
+    scripts:
+    - type: before
+      command: go generate ./...
+
+    # the web assets
+    extensions: [go]
+- name: api
+  path: api
+`
+	ioutil.WriteFile(RFile, []byte(config), 0644)
+	s := Settings{}
+	if err := s.AddCommand("app", Command{Type: "after", Cmd: "go vet ./..."}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	expected := strings.Replace(config, "go generate ./...\n", "go generate ./...\n    - command: go vet ./...\n      type: after\n", 1)
+	if string(content) != expected {
+		t.Errorf("Unexpected config\n%s", content)
+	}
+	// a new watcher of the second project
+	if err := s.AddCommand("api", Command{Type: "before", Cmd: "go test"}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = ioutil.ReadFile(RFile)
+	if !strings.HasSuffix(string(content), "  path: api\n  watcher:\n    scripts:\n    - command: go test\n      type: before\n") || !strings.Contains(string(content), "# the web assets") {
+		t.Errorf("Unexpected config\n%s", content)
+	}
+	if err := s.AddCommand("", Command{Cmd: "go test"}); err == nil || !strings.Contains(err.Error(), "set the project by its name") {
+		t.Error("Expected the missing name", err)
+	}
+	if err := s.AddCommand("web", Command{Cmd: "go test"}); err == nil || err.Error() != "project web not found in "+RFile {
+		t.Error("Expected the missing project", err)
+	}
+	// an invalid command is not written
+	if err := s.AddCommand("api", Command{Type: "pararell", Cmd: "go test"}); err == nil {
+		t.Error("Expected the invalid type")
+	}
+	if after, _ := ioutil.ReadFile(RFile); string(after) != string(content) {
+		t.Errorf("Expected the config to be restored\n%s", after)
+	}
+}
+
+func TestSettings_AddProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	s := Settings{}
+	// a new config, the path of the working directory is written relative to the config
+	path, _ := filepath.Rel(Wdir(), filepath.Join(dir, "services", "auth"))
+	if err := s.AddProject(Project{Name: "auth", Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(RFile); !strings.Contains(string(content), "path: services/auth\n") {
+		t.Errorf("Unexpected path\n%s", content)
+	}
+	r := Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Path != filepath.Join(dir, "services", "auth") {
+		t.Fatal("Unexpected config", r.Schema.Projects, err)
+	}
+	// a flow list is written again without comments, refused with them
+	ioutil.WriteFile(RFile, []byte("projects: [{name: app}]\n"), 0644)
+	if err := s.AddProject(Project{Name: "auth"}); err != nil {
+		t.Fatal(err)
+	}
+	r = Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 2 || r.Schema.Projects[1].Name != "auth" {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+	config := "projects: [{name: app}] # the apps\n"
+	ioutil.WriteFile(RFile, []byte(config), 0644)
+	if err := s.AddProject(Project{Name: "auth"}); err == nil || !strings.Contains(err.Error(), "the comments of the config can't be kept") {
+		t.Error("Expected the comments", err)
+	}
+	if content, _ := ioutil.ReadFile(RFile); string(content) != config {
+		t.Errorf("Unexpected config\n%s", content)
+	}
+}
+
+func TestSettings_AddTOML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.toml")
+	config := "# the apps\n[[schema]]\nname = \"app\" # the main one\n"
+	ioutil.WriteFile(RFile, []byte(config), 0644)
+	s := Settings{}
+	// a project is appended, the comments are kept
+	if err := s.AddProject(Project{Name: "auth", Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	if !strings.HasPrefix(string(content), config+"\n[[schema]]\n") {
+		t.Errorf("Unexpected config\n%s", content)
+	}
+	r := Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 2 || r.Schema.Projects[1].Name != "auth" {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+	// a command is refused with the comments
+	if err := s.AddCommand("app", Command{Cmd: "go test"}); err == nil || !strings.Contains(err.Error(), "the comments of the config can't be kept") {
+		t.Error("Expected the comments", err)
+	}
+	if after, _ := ioutil.ReadFile(RFile); string(after) != string(content) {
+		t.Errorf("Unexpected config\n%s", after)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	config := "schema:\n  - name: app\n    watcher:\n      scripts: # hooks\n        - command: a\n  - name: api\n"
+	out, ok := insert([]byte(config), []interface{}{"schema", 0, "watcher", "scripts"}, map[interface{}]interface{}{"command": "b"})
+	if !ok || string(out) != "schema:\n  - name: app\n    watcher:\n      scripts: # hooks\n        - command: a\n        - command: b\n  - name: api\n" {
+		t.Errorf("Unexpected config\n%s", out)
+	}
+	if _, ok := insert([]byte("schema:\n- name: app\n"), []interface{}{"schema", 1, "watcher", "scripts"}, "b"); ok {
+		t.Error("Expected the missing item")
+	}
+}
@@ -0,0 +1,96 @@
+package realize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Inherit merges the template or the project extended by each project under its config, as a preset
+// the templates are the bases of the projects, not run, the name of a base is not inherited, the lists of a project replace the ones of its base
+func inherit(doc map[interface{}]interface{}) (inherited bool, err error) {
+	var errs []string
+	for _, key := range []string{"schema", "projects"} {
+		list, _ := doc[key].([]interface{})
+		for i, v := range list {
+			m, ok := v.(map[interface{}]interface{})
+			if !ok || m["extends"] == nil {
+				continue
+			}
+			if list[i], err = extend(doc, m, map[string]bool{}); err != nil {
+				errs = append(errs, fmt.Sprintf("%s[%d]: %s", key, i, err))
+				continue
+			}
+			inherited = true
+		}
+	}
+	if len(errs) > 0 {
+		return inherited, errors.New(strings.Join(errs, "\n"))
+	}
+	return inherited, nil
+}
+
+// Uninherit removes the values of the extended bases from the config of the projects, to write only their own values
+func uninherit(doc map[interface{}]interface{}) (changed bool) {
+	list, _ := doc["schema"].([]interface{})
+	// the bases before the removal of their values
+	bases := make([]interface{}, len(list))
+	for i, v := range list {
+		if m, ok := v.(map[interface{}]interface{}); ok && m["extends"] != nil {
+			bases[i] = base(doc, fmt.Sprint(m["extends"]))
+		}
+	}
+	for i, b := range bases {
+		if b != nil {
+			list[i] = own(list[i], b)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Extend returns a project merged over its bases
+func extend(doc, m map[interface{}]interface{}, seen map[string]bool) (map[interface{}]interface{}, error) {
+	if m["extends"] == nil {
+		return m, nil
+	}
+	name := fmt.Sprint(m["extends"])
+	if seen[name] {
+		return nil, fmt.Errorf("extends: %q extends itself", name)
+	}
+	seen[name] = true
+	b := base(doc, name)
+	if b == nil {
+		return nil, fmt.Errorf("extends: %q is not a template or a project", name)
+	}
+	b, err := extend(doc, b, seen)
+	if err != nil {
+		return nil, err
+	}
+	return rebase(b, m).(map[interface{}]interface{}), nil
+}
+
+// Base returns a copy of a template or a project by its name, without its name
+func base(doc map[interface{}]interface{}, name string) map[interface{}]interface{} {
+	var found map[interface{}]interface{}
+	if templates, ok := doc["templates"].(map[interface{}]interface{}); ok {
+		found, _ = templates[name].(map[interface{}]interface{})
+	}
+	for _, key := range []string{"schema", "projects"} {
+		list, _ := doc[key].([]interface{})
+		for _, v := range list {
+			if m, ok := v.(map[interface{}]interface{}); ok && found == nil && fmt.Sprint(m["name"]) == name {
+				found = m
+			}
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	b := make(map[interface{}]interface{}, len(found))
+	for k, v := range found {
+		b[k] = v
+	}
+	delete(b, "name")
+	return b
+}
@@ -0,0 +1,84 @@
+package realize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInherit(t *testing.T) {
+	doc := map[interface{}]interface{}{
+		"templates": map[interface{}]interface{}{
+			"base-service": map[interface{}]interface{}{"preset": "go-api", "args": []interface{}{"--debug"}, "env": map[interface{}]interface{}{"PORT": "8080"}},
+		},
+		"schema": []interface{}{
+			map[interface{}]interface{}{"name": "auth", "path": "services/auth", "extends": "base-service", "env": map[interface{}]interface{}{"PORT": "8081"}},
+			map[interface{}]interface{}{"name": "auth-debug", "extends": "auth"},
+			map[interface{}]interface{}{"name": "other"},
+			map[interface{}]interface{}{"name": "billing", "extends": "base-service", "args": []interface{}{"--port", "9001"}},
+		},
+	}
+	inherited, err := inherit(doc)
+	if err != nil || !inherited {
+		t.Fatal("Unexpected error", err)
+	}
+	list := doc["schema"].([]interface{})
+	auth := list[0].(map[interface{}]interface{})
+	if auth["name"] != "auth" || auth["preset"] != "go-api" || auth["env"].(map[interface{}]interface{})["PORT"] != "8081" || !reflect.DeepEqual(auth["args"], []interface{}{"--debug"}) {
+		t.Error("Unexpected project", auth)
+	}
+	if debug := list[1].(map[interface{}]interface{}); debug["name"] != "auth-debug" || debug["path"] != "services/auth" || debug["preset"] != "go-api" {
+		t.Error("Unexpected project", debug)
+	}
+	// the lists of a project replace the ones of its base
+	if billing := list[3].(map[interface{}]interface{}); !reflect.DeepEqual(billing["args"], []interface{}{"--port", "9001"}) {
+		t.Error("Unexpected args", billing["args"])
+	}
+	_, err = inherit(map[interface{}]interface{}{"schema": []interface{}{
+		map[interface{}]interface{}{"name": "a", "extends": "b"},
+		map[interface{}]interface{}{"name": "b", "extends": "a"},
+		map[interface{}]interface{}{"name": "c", "extends": "missing"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), `schema[0]: extends: "b" extends itself`) || !strings.Contains(err.Error(), `schema[2]: extends: "missing" is not a template or a project`) {
+		t.Error("Expected the wrong extends", err)
+	}
+}
+
+func TestSettings_Extends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	ioutil.WriteFile(RFile, []byte("templates:\n  service:\n    commands:\n      install:\n        status: true\n    watcher:\n      extensions: [go]\nschema:\n- name: auth\n  path: auth\n  extends: service\n- name: billing\n  path: billing\n  extends: service\n  args: [--port, \"9000\"]\n"), 0644)
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Schema.Projects) != 2 {
+		t.Fatal("Unexpected projects", r.Schema.Projects)
+	}
+	for _, p := range r.Schema.Projects {
+		if !p.Tools.Install.Status || !reflect.DeepEqual(p.Watcher.Exts, []string{"go"}) {
+			t.Error("Unexpected project", p)
+		}
+	}
+	// the values of the template are not written in the projects
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	if strings.Count(string(content), "extensions") != 1 || strings.Count(string(content), "extends: service") != 2 {
+		t.Error("Unexpected config", string(content))
+	}
+	r = Realize{}
+	if err := s.Read(&r); err != nil || r.Schema.Projects[1].Args[1] != "9000" || !r.Schema.Projects[1].Tools.Install.Status {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+}
@@ -0,0 +1,110 @@
+package realize
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitIgnore holds the rules read from the .gitignore files of a project
+type gitIgnore struct {
+	rules  []gitRule
+	loaded map[string]bool
+}
+
+// gitRule is a single .gitignore pattern
+type gitRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dir      bool
+	anchored bool
+}
+
+// Load reads the .gitignore file of a given dir, if exists
+func (g *gitIgnore) Load(dir string) error {
+	if g.loaded == nil {
+		g.loaded = make(map[string]bool)
+	}
+	if g.loaded[dir] {
+		return nil
+	}
+	g.loaded[dir] = true
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseGitRule(dir, scanner.Text()); ok {
+			g.rules = append(g.rules, r)
+		}
+	}
+	return scanner.Err()
+}
+
+// Ignored checks if a path, or one of its parent dirs, is excluded by the loaded rules
+func (g *gitIgnore) Ignored(name string, dir bool) bool {
+	for p := filepath.Dir(name); p != filepath.Dir(p); p = filepath.Dir(p) {
+		if g.match(p, true) {
+			return true
+		}
+	}
+	return g.match(name, dir)
+}
+
+// Match returns the result of the last rule matching a path
+func (g *gitIgnore) match(name string, dir bool) bool {
+	ignored := false
+	for _, r := range g.rules {
+		if r.dir && !dir {
+			continue
+		}
+		rel, err := filepath.Rel(r.base, name)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !r.anchored {
+			rel = path.Base(rel)
+		}
+		if glob(r.pattern, rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// Parse a .gitignore line
+func parseGitRule(base, line string) (r gitRule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	r.base = base
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dir = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	r.pattern = line
+	return r, true
+}
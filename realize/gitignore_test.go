@@ -0,0 +1,51 @@
+package realize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitIgnore_Ignored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitignore_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), Permission); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# comment\n*.log\n!keep.log\n/build/\nnode_modules/\n"), Permission); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", ".gitignore"), []byte("gen/**/*.go\n"), Permission); err != nil {
+		t.Fatal(err)
+	}
+	g := gitIgnore{}
+	if err := g.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Load(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]bool{
+		"main.go":                      false,
+		"out.log":                      true,
+		"sub/out.log":                  true,
+		"keep.log":                     false,
+		"build/main":                   true,
+		"sub/build/main":               false,
+		"sub/node_modules/a/b.js":      true,
+		"sub/gen/a/b.go":               true,
+		"sub/gen/b.go":                 true,
+		"gen/a/b.go":                   false,
+		"sub/generated/a/b.go":         false,
+		"sub/node_modules_backup/a.js": false,
+	}
+	for i, v := range data {
+		if result := g.Ignored(filepath.Join(dir, filepath.FromSlash(i)), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package realize
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchema returns the json schema of the config, its keys are the same in yaml, toml and json
+func JSONSchema() ([]byte, error) {
+	defs := make(map[string]interface{})
+	root := object(reflect.TypeOf(Realize{}), defs)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = RPrefix + " config"
+	root["definitions"] = defs
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// Object returns the schema of the yaml fields of a struct, the unknown keys are not allowed
+func object(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" {
+			for k, v := range object(f.Type, defs)["properties"].(map[string]interface{}) {
+				props[k] = v
+			}
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if s := schemaOf(f.Type, defs); s != nil {
+			props[name] = s
+		}
+	}
+	return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// SchemaOf returns the schema of a type, the structs are definitions, nil for the types not in the config
+func schemaOf(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": []string{"string", "integer"}, "description": "duration as 300ms or 1m, or nanoseconds"}
+	case reflect.TypeOf(Secret{}):
+		// a value, an encrypted one, or a reference to a file or a keychain entry
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			object(reflect.TypeOf(Secret{}), defs),
+		}}
+	case reflect.TypeOf(Triggers{}):
+		// a list of triggers, or a map of extensions and patterns to their commands
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": schemaOf(reflect.TypeOf(Trigger{}), defs)},
+			map[string]interface{}{"type": "object", "additionalProperties": schemaOf(reflect.TypeOf([]Command{}), defs)},
+		}}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), defs)
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			// set before the fields, for the recursive types
+			defs[t.Name()] = nil
+			defs[t.Name()] = object(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	}
+	return nil
+}
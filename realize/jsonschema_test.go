@@ -0,0 +1,50 @@
+package realize
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	s, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(s), "null") {
+		t.Error("Unexpected schema without a type", string(s))
+	}
+	var v struct {
+		Properties  map[string]interface{}
+		Definitions map[string]struct {
+			Properties           map[string]map[string]interface{}
+			AdditionalProperties bool
+		}
+	}
+	if err := json.Unmarshal(s, &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"settings", "server", "schema", "scripts"} {
+		if v.Properties[k] == nil {
+			t.Error("Expected the key", k)
+		}
+	}
+	for _, k := range []string{"Sync", "sync", "Err"} {
+		if v.Properties[k] != nil {
+			t.Error("Unexpected key", k)
+		}
+	}
+	c := v.Definitions["Command"]
+	if c.AdditionalProperties || c.Properties["command"]["type"] != "string" || c.Properties["pipe"]["items"].(map[string]interface{})["$ref"] != "#/definitions/Command" {
+		t.Error("Unexpected command", c)
+	}
+	if c.Properties["vars"] != nil || c.Properties["timeout"]["type"] == nil {
+		t.Error("Unexpected command fields", c.Properties)
+	}
+	if w := v.Definitions["Watch"]; w.Properties["triggers"]["anyOf"] == nil || w.Properties["extensions"]["type"] != "array" {
+		t.Error("Unexpected watch", w.Properties)
+	}
+	if r := v.Definitions["Recovery"]; r.Properties["index"] == nil {
+		t.Error("Expected the lowercase keys of the fields without tags", r.Properties)
+	}
+}
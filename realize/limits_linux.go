@@ -0,0 +1,95 @@
+// +build linux
+
+package realize
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// env variable of the limits of a command, realize runs itself as a wrapper applying them before the exec of the command
+const limitsEnv = "REALIZE_LIMITS"
+
+// the limits are applied by the wrapper before the exec of the command, all its children inherit them
+func init() {
+	if v := os.Getenv(limitsEnv); v != "" && len(os.Args) > 2 {
+		if err := limited(v); err != nil {
+			fmt.Fprintln(os.Stderr, "realize: limits:", err)
+			os.Exit(126)
+		}
+	}
+}
+
+// setLimits runs a command through the wrapper applying its priority and its resource limits
+// the limits are the rlimits of each process of the command, the cgroups aren't used: they need a
+// cgroup tree delegated to the user, as by systemd-run --user, not available to every dev environment
+func setLimits(ex *exec.Cmd, c *Command) error {
+	if c.Nice == 0 && c.MaxMemory <= 0 && c.MaxCPU <= 0 {
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	env := ex.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cpu := uint64(0)
+	if c.MaxCPU > 0 {
+		// rounded up, the rlimit is in seconds and zero is no limit
+		cpu = uint64(math.Ceil(c.MaxCPU.Seconds()))
+	}
+	ex.Env = append(env, fmt.Sprintf("%s=%d:%d:%d", limitsEnv, c.Nice, c.MaxMemory, cpu))
+	ex.Args = append([]string{self, ex.Path}, ex.Args...)
+	ex.Path = self
+	return nil
+}
+
+// Limited applies the limits of the env variable to the wrapper, then execs the command with its args
+func limited(v string) error {
+	values := strings.Split(v, ":")
+	if len(values) != 3 {
+		return fmt.Errorf("%q isn't nice:memory:cpu", v)
+	}
+	nice, err := strconv.Atoi(values[0])
+	if err != nil {
+		return err
+	}
+	memory, err := strconv.ParseUint(values[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	cpu, err := strconv.ParseUint(values[2], 10, 64)
+	if err != nil {
+		return err
+	}
+	if nice != 0 {
+		// the priority of the whole group, the one of a thread otherwise
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, 0, nice); err != nil {
+			return err
+		}
+	}
+	if memory > 0 {
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: memory, Max: memory}); err != nil {
+			return err
+		}
+	}
+	if cpu > 0 {
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: cpu, Max: cpu}); err != nil {
+			return err
+		}
+	}
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, limitsEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	return syscall.Exec(os.Args[1], os.Args[2:], env)
+}
@@ -0,0 +1,37 @@
+// +build linux
+
+package realize
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand_Limits(t *testing.T) {
+	// the limits of a child forked at once
+	c := Command{Cmd: "sh -c \"cut -d' ' -f19 /proc/\\$\\$/stat; grep 'Max address space' /proc/\\$\\$/limits\"", Shell: true, Nice: 5, MaxMemory: 1 << 30}
+	r := c.exec(context.Background(), Wdir())
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	lines := strings.Split(r.Out, "\n")
+	if lines[0] != "5" || !strings.Contains(lines[1], "1073741824") {
+		t.Error("Unexpected limits", r.Out)
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	// a cpu time under a second isn't dropped
+	for d, cpu := range map[time.Duration]string{100 * time.Millisecond: ":1", time.Second: ":1", 1500 * time.Millisecond: ":2"} {
+		ex := exec.Command("true")
+		if err := setLimits(ex, &Command{MaxCPU: d}); err != nil {
+			t.Fatal(err)
+		}
+		if env := ex.Env[len(ex.Env)-1]; env != limitsEnv+"=0:0"+cpu {
+			t.Error("Unexpected limits of", d, env)
+		}
+	}
+}
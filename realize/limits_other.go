@@ -0,0 +1,16 @@
+// +build !linux
+
+package realize
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// setLimits isn't supported out of linux
+func setLimits(ex *exec.Cmd, c *Command) error {
+	if c.Nice != 0 || c.MaxMemory > 0 || c.MaxCPU > 0 {
+		return errors.New("resource limits aren't supported on this platform")
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package realize
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// tools of the first versions, set on the project as fmt: true, bin is the install
+var legacyTools = map[string]string{"clean": "clean", "vet": "vet", "fmt": "fmt", "test": "test", "generate": "generate", "bin": "install", "install": "install", "build": "build", "run": "run"}
+
+// fields of a tool and of a command kept as they are
+var (
+	toolKeys    = []string{"status", "args", "method", "path", "dir", "output"}
+	commandKeys = []string{"type", "command", "path", "global", "output"}
+)
+
+// migration of a legacy config, the keys without an equivalent are dropped
+type migration struct {
+	dropped []string
+}
+
+// Migrate converts a config of the first versions of realize, as a .realize/realize.yaml with the projects list
+// the keys without an equivalent in this version are returned as dropped
+func Migrate(content []byte) (r Realize, dropped []string, err error) {
+	var doc map[interface{}]interface{}
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return r, nil, err
+	}
+	m := migration{}
+	out := map[interface{}]interface{}{}
+	for _, k := range sortedKeys(doc) {
+		v := doc[k]
+		switch k {
+		case "settings":
+			settings, server := m.settings(v)
+			out["settings"] = settings
+			if server != nil {
+				out["server"] = server
+			}
+		case "server":
+			out["server"] = v
+		case "projects", "schema":
+			list, _ := v.([]interface{})
+			var projects []interface{}
+			for i, p := range list {
+				projects = append(projects, m.project(fmt.Sprintf("%s[%d]", k, i), p))
+			}
+			out["schema"] = append(asList(out["schema"]), projects...)
+		default:
+			m.drop(k)
+		}
+	}
+	y, err := yaml.Marshal(out)
+	if err != nil {
+		return r, nil, err
+	}
+	if err = yaml.UnmarshalStrict(y, &r); err != nil {
+		return r, nil, err
+	}
+	return r, m.dropped, nil
+}
+
+// Settings converts the legacy settings, the server was one of them
+func (m *migration) settings(v interface{}) (settings map[interface{}]interface{}, server interface{}) {
+	settings = map[interface{}]interface{}{}
+	doc, _ := v.(map[interface{}]interface{})
+	for _, k := range sortedKeys(doc) {
+		switch v := doc[k]; k {
+		case "legacy":
+			legacy, _ := v.(map[interface{}]interface{})
+			out := map[interface{}]interface{}{}
+			for _, k := range sortedKeys(legacy) {
+				switch k {
+				case "status", "force":
+					out["force"] = legacy[k]
+				case "interval":
+					out["interval"] = legacy[k]
+				default:
+					m.drop("settings.legacy." + k)
+				}
+			}
+			settings["legacy"] = out
+		case "resources":
+			// the names of the files, enabled
+			resources, _ := v.(map[interface{}]interface{})
+			files := map[interface{}]interface{}{}
+			for _, k := range sortedKeys(resources) {
+				if k == "outputs" || k == "logs" || k == "errors" {
+					files[k] = map[interface{}]interface{}{"status": true, "name": resources[k]}
+				} else {
+					m.drop("settings.resources." + k)
+				}
+			}
+			settings["files"] = files
+		case "server":
+			server = v
+		case "flimit", "file_limit":
+			settings["flimit"] = v
+		case "files", "recovery", "watcher":
+			settings[k] = v
+		default:
+			m.drop("settings." + k)
+		}
+	}
+	return settings, server
+}
+
+// Project converts a legacy project, the tools were flags of the project and the scripts were the watcher commands
+func (m *migration) project(key string, v interface{}) interface{} {
+	doc, _ := v.(map[interface{}]interface{})
+	out := map[interface{}]interface{}{}
+	tools := map[interface{}]interface{}{}
+	for _, k := range sortedKeys(doc) {
+		switch v := doc[k]; k {
+		case "name", "path", "args", "env", "pattern", "env_file":
+			out[k] = v
+		case "params":
+			out["args"] = v
+		case "environment":
+			out["env"] = v
+		case "commands":
+			list, _ := v.(map[interface{}]interface{})
+			for _, k := range sortedKeys(list) {
+				if name, ok := legacyTools[k]; ok {
+					tools[name] = m.tool(key+".commands."+k, list[k])
+				} else {
+					m.drop(key + ".commands." + k)
+				}
+			}
+		case "watcher":
+			out["watcher"] = m.watcher(key+".watcher", v)
+		default:
+			if name, ok := legacyTools[k]; ok {
+				tools[name] = m.tool(key+"."+k, v)
+			} else {
+				m.drop(key + "." + k)
+			}
+		}
+	}
+	if len(tools) > 0 {
+		out["commands"] = tools
+	}
+	return out
+}
+
+// Tool converts a tool set as a flag or as a map
+func (m *migration) tool(key string, v interface{}) interface{} {
+	if b, ok := v.(bool); ok {
+		return map[interface{}]interface{}{"status": b}
+	}
+	return m.pick(key, v, toolKeys)
+}
+
+// Watcher converts the legacy watcher, the extensions were prefixed by a dot
+func (m *migration) watcher(key string, v interface{}) interface{} {
+	doc, _ := v.(map[interface{}]interface{})
+	out := map[interface{}]interface{}{}
+	for _, k := range sortedKeys(doc) {
+		switch v := doc[k]; k {
+		case "paths", "hidden", "debounce":
+			out[k] = v
+		case "exts", "extensions":
+			var exts []interface{}
+			for _, e := range asList(v) {
+				exts = append(exts, strings.TrimPrefix(fmt.Sprint(e), "."))
+			}
+			out["extensions"] = exts
+		case "ignore_paths", "ignored_paths":
+			out["ignored_paths"] = v
+		case "scripts", "commands":
+			var scripts []interface{}
+			for i, c := range asList(v) {
+				scripts = append(scripts, m.command(fmt.Sprintf("%s.%s[%d]", key, k, i), c))
+			}
+			out["scripts"] = scripts
+		default:
+			m.drop(key + "." + k)
+		}
+	}
+	return out
+}
+
+// Command converts a legacy command, the startup ones are the global ones
+func (m *migration) command(key string, v interface{}) interface{} {
+	doc, _ := v.(map[interface{}]interface{})
+	rest := map[interface{}]interface{}{}
+	for k, v := range doc {
+		rest[k] = v
+	}
+	startup, _ := rest["startup"].(bool)
+	// the commands run on each change
+	delete(rest, "changed")
+	delete(rest, "startup")
+	out := m.pick(key, rest, commandKeys).(map[interface{}]interface{})
+	if startup {
+		out["global"] = true
+	}
+	return out
+}
+
+// Pick returns the given keys of a map, the other ones are dropped
+func (m *migration) pick(key string, v interface{}, names []string) interface{} {
+	doc, _ := v.(map[interface{}]interface{})
+	out := map[interface{}]interface{}{}
+	for _, k := range sortedKeys(doc) {
+		found := false
+		for _, n := range names {
+			if k == n {
+				out[k], found = doc[k], true
+			}
+		}
+		if !found {
+			m.drop(key + "." + k)
+		}
+	}
+	return out
+}
+
+// Drop records a key without an equivalent
+func (m *migration) drop(key string) {
+	m.dropped = append(m.dropped, key)
+}
+
+// SortedKeys returns the keys of a map in order
+func sortedKeys(doc map[interface{}]interface{}) []string {
+	var list []string
+	for k := range doc {
+		list = append(list, fmt.Sprint(k))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// AsList returns a list, a single value as a list of one
+func asList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	if v == nil {
+		return nil
+	}
+	return []interface{}{v}
+}
@@ -0,0 +1,72 @@
+package realize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	legacy := `
+settings:
+  legacy:
+    status: true
+    interval: 10s
+  resources:
+    outputs: outputs.log
+  server:
+    status: true
+    port: 5002
+projects:
+- name: coin
+  path: coin
+  fmt: true
+  bin: true
+  run: true
+  params:
+  - --myarg
+  environment:
+    PORT: "8080"
+  commands:
+    vet:
+      status: true
+      method: go tool vet
+  watcher:
+    preview: false
+    paths:
+    - /
+    ignore_paths:
+    - vendor
+    exts:
+    - .go
+    - .html
+    scripts:
+    - type: before
+      command: go generate
+      changed: true
+      startup: true
+  streams:
+    cli_out: true
+`
+	r, dropped, err := Migrate([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Settings.Legacy.Force || r.Settings.Legacy.Interval != 10*time.Second || !r.Settings.Files.Outputs.Status || r.Settings.Files.Outputs.Name != "outputs.log" || r.Server.Port != 5002 {
+		t.Error("Unexpected settings", r.Settings, r.Server)
+	}
+	p := r.Schema.Projects[0]
+	if p.Name != "coin" || p.Args[0] != "--myarg" || p.Env["PORT"] != "8080" || !p.Tools.Fmt.Status || !p.Tools.Install.Status || !p.Tools.Run.Status || p.Tools.Vet.Method != "go tool vet" {
+		t.Error("Unexpected project", p)
+	}
+	if !reflect.DeepEqual(p.Watcher.Exts, []string{"go", "html"}) || p.Watcher.Ignore[0] != "vendor" || !p.Watcher.Scripts[0].Global || p.Watcher.Scripts[0].Cmd != "go generate" {
+		t.Error("Unexpected watcher", p.Watcher)
+	}
+	if strings.Join(dropped, ",") != "projects[0].streams,projects[0].watcher.preview" {
+		t.Error("Unexpected dropped keys", dropped)
+	}
+	if _, _, err := Migrate([]byte("projects: [{name: a, watcher: {paths: 1}}]")); err == nil {
+		t.Error("Expected an error")
+	}
+}
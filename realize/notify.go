@@ -9,8 +9,12 @@ import (
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -19,6 +23,9 @@ var (
 	errPollerClosed = errors.New("poller is closed")
 	// errNoSuchWatch is returned when trying to remove a watch that doesn't exist
 	errNoSuchWatch = errors.New("watch does not exist")
+	// watchers is the list of the registered file watchers
+	watchers   = make(map[string]func() FileWatcher)
+	watchersMu sync.Mutex
 )
 
 type (
@@ -32,8 +39,24 @@ type (
 		Events() <-chan fsnotify.Event
 	}
 	// fsNotifyWatcher wraps the fsnotify package to satisfy the FileNotifier interface
+	// paths exceeding the inotify watches limit are moved to a poller
 	fsNotifyWatcher struct {
 		*fsnotify.Watcher
+		// poller used for the paths that can't be watched by fsnotify
+		poller *filePoller
+		// polled is the list of paths moved to the poller
+		polled map[string]bool
+		// remote is the list of polled paths on a network filesystem
+		remote map[string]bool
+		// limited is set once the watches limit is reached
+		limited bool
+		// events and errors of both fsnotify and poller
+		events chan fsnotify.Event
+		errors chan error
+		// done is closed by close, the pending events aren't sent anymore
+		done   chan struct{}
+		closed sync.Once
+		mu     sync.Mutex
 	}
 	// filePoller is used to poll files for changes, especially in cases where fsnotify
 	// can't be run (e.g. when inotify handles are exhausted)
@@ -66,43 +89,188 @@ func PollingWatcher(interval time.Duration) FileWatcher {
 	}
 }
 
+// RegisterWatcher makes a file watcher available by name, used by the watcher setting
+func RegisterWatcher(name string, factory func() FileWatcher) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	watchers[name] = factory
+}
+
+// NewWatcher returns a new registered file watcher
+func NewWatcher(name string) (FileWatcher, error) {
+	watchersMu.Lock()
+	factory, ok := watchers[name]
+	watchersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("watcher %q is not registered", name)
+	}
+	return factory(), nil
+}
+
 // NewFileWatcher tries to use an fs-event watcher, and falls back to the poller if there is an error
 func NewFileWatcher(l Legacy) (FileWatcher, error) {
 	if !l.Force {
-		if w, err := EventWatcher(); err == nil {
+		if w, err := EventWatcher(l.Interval); err == nil {
 			return w, nil
 		}
 	}
 	return PollingWatcher(l.Interval), nil
 }
 
-// EventWatcher returns an fs-event based file watcher
-func EventWatcher() (FileWatcher, error) {
-	w, err := fsnotify.NewWatcher()
+// EventWatcher returns an fs-event based file watcher, interval is used to poll the paths over the watches limit
+func EventWatcher(interval time.Duration) (FileWatcher, error) {
+	fw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	return &fsNotifyWatcher{Watcher: w}, nil
+	w := &fsNotifyWatcher{
+		Watcher: fw,
+		poller:  PollingWatcher(interval).(*filePoller),
+		polled:  make(map[string]bool),
+		remote:  make(map[string]bool),
+		events:  make(chan fsnotify.Event),
+		errors:  make(chan error),
+		done:    make(chan struct{}),
+	}
+	go w.forward()
+	return w, nil
+}
+
+// Forward fsnotify and poller events until the watcher is closed, even if they aren't read anymore
+func (w *fsNotifyWatcher) forward() {
+	for {
+		var event *fsnotify.Event
+		var err error
+		select {
+		case <-w.done:
+			return
+		case e, ok := <-w.Watcher.Events:
+			if !ok {
+				return
+			}
+			event = &e
+		case e, ok := <-w.Watcher.Errors:
+			if !ok {
+				return
+			}
+			err = e
+		case e := <-w.poller.events:
+			event = &e
+		case e := <-w.poller.errors:
+			err = e
+		}
+		if event != nil {
+			select {
+			case w.events <- *event:
+			case <-w.done:
+				return
+			}
+		} else {
+			select {
+			case w.errors <- err:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// Close closes both fsnotify and the poller, and stops the forward of their events
+func (w *fsNotifyWatcher) Close() error {
+	w.closed.Do(func() { close(w.done) })
+	w.poller.Close()
+	return w.Watcher.Close()
 }
 
 // Errors returns the fsnotify error channel receiver
 func (w *fsNotifyWatcher) Errors() <-chan error {
-	return w.Watcher.Errors
+	return w.errors
 }
 
 // Events returns the fsnotify event channel receiver
 func (w *fsNotifyWatcher) Events() <-chan fsnotify.Event {
-	return w.Watcher.Events
+	return w.events
 }
 
-// Walk fsnotify
+// Remove a path from fsnotify or from the poller
+func (w *fsNotifyWatcher) Remove(name string) error {
+	w.mu.Lock()
+	polled := w.polled[name]
+	delete(w.polled, name)
+	delete(w.remote, name)
+	w.mu.Unlock()
+	if polled {
+		return w.poller.Remove(name)
+	}
+	return w.Watcher.Remove(name)
+}
+
+// Walk fsnotify, the path is polled if it is on a network filesystem or if the watches limit is reached
 func (w *fsNotifyWatcher) Walk(path string, init bool) string {
+	if isNetwork(path) {
+		return w.poll(path, true)
+	}
 	if err := w.Add(path); err != nil {
+		// the errno may be wrapped by fsnotify
+		if !errors.Is(err, syscall.ENOSPC) {
+			return ""
+		}
+		w.mu.Lock()
+		w.limited = true
+		w.mu.Unlock()
+		return w.poll(path, false)
+	}
+	return path
+}
+
+// Poll moves a path to the poller
+func (w *fsNotifyWatcher) poll(path string, remote bool) string {
+	if err := w.poller.Add(path); err != nil {
 		return ""
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.polled[path] = true
+	if remote {
+		w.remote[path] = true
+	}
 	return path
 }
 
+// Limited reports if the watches limit has been reached, even by the paths the poller failed to add
+func (w *fsNotifyWatcher) Limited() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.limited
+}
+
+// Polled returns the number of paths moved to the poller
+func (w *fsNotifyWatcher) Polled() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.polled)
+}
+
+// Remote returns the number of polled paths on a network filesystem
+func (w *fsNotifyWatcher) Remote() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.remote)
+}
+
+// WatchesLimit returns the max number of inotify watches, zero if unknown
+func WatchesLimit() int {
+	content, err := ioutil.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
 // Close closes the poller
 // All watches are stopped, removed, and the poller cannot be added to
 func (w *filePoller) Close() error {
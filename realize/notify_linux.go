@@ -0,0 +1,26 @@
+// +build linux
+
+package realize
+
+import "syscall"
+
+// network filesystems magic numbers
+var networkFS = map[int64]bool{
+	0x6969:     true, // nfs
+	0x517b:     true, // smb
+	0xff534d42: true, // cifs
+	0xfe534d42: true, // smb2
+	0x01021997: true, // 9p
+	0x6a656a63: true, // virtiofs
+	0x564c:     true, // ncp
+	0x73757245: true, // coda
+}
+
+// isNetwork checks if a path is on a network filesystem
+func isNetwork(path string) bool {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return false
+	}
+	return networkFS[int64(fs.Type)]
+}
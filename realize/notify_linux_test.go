@@ -0,0 +1,21 @@
+// +build linux
+
+package realize
+
+import "testing"
+
+func TestIsNetwork(t *testing.T) {
+	// the magic numbers of the statfs of the network filesystems
+	for name, magic := range map[string]int64{"nfs": 0x6969, "cifs": 0xff534d42, "9p": 0x01021997, "virtiofs": 0x6a656a63} {
+		if !networkFS[magic] {
+			t.Error("Expected a network filesystem", name)
+		}
+	}
+	// fuse is used by local filesystems too
+	if networkFS[0x65735546] {
+		t.Error("Unexpected fuse as a network filesystem")
+	}
+	if isNetwork("no-such-dir") {
+		t.Error("Unexpected network filesystem of a missing path")
+	}
+}
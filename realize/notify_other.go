@@ -0,0 +1,8 @@
+// +build !linux
+
+package realize
+
+// isNetwork checks if a path is on a network filesystem
+func isNetwork(path string) bool {
+	return false
+}
@@ -120,3 +120,72 @@ func assertEvent(w FileWatcher, eType fsnotify.Op) error {
 	}
 	return err
 }
+
+func TestEventWatcher_Close(t *testing.T) {
+	d, err := ioutil.TempDir("", "test-close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	before := runtime.NumGoroutine()
+	w, err := EventWatcher(interval)
+	if err != nil {
+		t.Skip("fs events not supported", err)
+	}
+	w.Walk(d, false)
+	// an event never read
+	ioutil.WriteFile(d+"/a.go", []byte("package a"), 0644)
+	time.Sleep(50 * time.Millisecond)
+	w.Close()
+	for start := time.Now(); runtime.NumGoroutine() > before; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("Expected the forward of the events to stop", runtime.NumGoroutine(), before)
+		}
+	}
+}
+
+func TestEventWatcher_Walk(t *testing.T) {
+	w, err := EventWatcher(interval)
+	if err != nil {
+		t.Skip("fs events not supported", err)
+	}
+	defer w.Close()
+	d, err := ioutil.TempDir("", "test-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	if w.Walk(d, false) != d {
+		t.Fatal("Expected a watched path")
+	}
+	if w.Walk("no-such-file", false) != "" {
+		t.Fatal("Unexpected watched path")
+	}
+	if n := w.(*fsNotifyWatcher).Polled(); n != 0 || w.(*fsNotifyWatcher).Limited() {
+		t.Error("Unexpected polled paths", n)
+	}
+	if err := w.Remove(d); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterWatcher(t *testing.T) {
+	if _, err := NewWatcher("test"); err == nil {
+		t.Fatal("Expected an error for a not registered watcher")
+	}
+	RegisterWatcher("test", func() FileWatcher {
+		return PollingWatcher(interval)
+	})
+	defer func() {
+		watchersMu.Lock()
+		delete(watchers, "test")
+		watchersMu.Unlock()
+	}()
+	w, err := NewWatcher("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.(*filePoller); !ok {
+		t.Error("Unexpected watcher", w)
+	}
+}
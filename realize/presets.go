@@ -0,0 +1,118 @@
+package realize
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// presets of the common setups, the config of a project merged under its own one as an include
+var presets = map[string]string{
+	// a server installed and run again on each change
+	"go-api": `
+commands:
+  fmt:
+    status: true
+  vet:
+    status: true
+  install:
+    status: true
+  run:
+    status: true
+watcher:
+  paths: ["/"]
+  extensions: [go]
+  ignored_paths: [.git, .realize, vendor]
+`,
+	// a server with its templates and web assets
+	"go-web": `
+commands:
+  fmt:
+    status: true
+  install:
+    status: true
+  run:
+    status: true
+watcher:
+  paths: ["/"]
+  extensions: [go, html, tmpl, css, js]
+  ignored_paths: [.git, .realize, vendor, node_modules, dist]
+`,
+	// a command line tool tested and installed, not run
+	"cli": `
+commands:
+  fmt:
+    status: true
+  vet:
+    status: true
+  test:
+    status: true
+  install:
+    status: true
+watcher:
+  paths: ["/"]
+  extensions: [go]
+  ignored_paths: [.git, .realize, vendor]
+`,
+}
+
+// Preset returns the config of a preset
+func preset(name string) (interface{}, error) {
+	p, ok := presets[name]
+	if !ok {
+		var names []string
+		for k := range presets {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("preset: %q is not a preset, expected %s", name, strings.Join(names, ", "))
+	}
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(p), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Expand merges the preset of each project under its config, the maps are merged, the lists and the other values of the project replace the ones of the preset
+func expand(doc map[interface{}]interface{}) (expanded bool, err error) {
+	var errs []string
+	for _, key := range []string{"schema", "projects"} {
+		list, _ := doc[key].([]interface{})
+		for i, v := range list {
+			m, ok := v.(map[interface{}]interface{})
+			if !ok || m["preset"] == nil {
+				continue
+			}
+			base, err := preset(fmt.Sprint(m["preset"]))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s[%d]: %s", key, i, err))
+				continue
+			}
+			list[i] = rebase(base, m)
+			expanded = true
+		}
+	}
+	if len(errs) > 0 {
+		return expanded, errors.New(strings.Join(errs, "\n"))
+	}
+	return expanded, nil
+}
+
+// Unexpand removes the values of the presets from the config of the projects, to write only their own values
+func unexpand(doc map[interface{}]interface{}) (changed bool) {
+	list, _ := doc["schema"].([]interface{})
+	for i, v := range list {
+		m, ok := v.(map[interface{}]interface{})
+		if !ok || m["preset"] == nil {
+			continue
+		}
+		if base, err := preset(fmt.Sprint(m["preset"])); err == nil {
+			list[i] = own(m, base)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,70 @@
+package realize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	doc := map[interface{}]interface{}{
+		"schema": []interface{}{
+			map[interface{}]interface{}{"name": "api", "preset": "go-api", "commands": map[interface{}]interface{}{"run": map[interface{}]interface{}{"status": false}}},
+			map[interface{}]interface{}{"name": "other"},
+		},
+		"projects": []interface{}{
+			map[interface{}]interface{}{"name": "web", "preset": "go-web", "watcher": map[interface{}]interface{}{"ignored_paths": []interface{}{"tmp"}}},
+		},
+	}
+	expanded, err := expand(doc)
+	if err != nil || !expanded {
+		t.Fatal("Unexpected error", err)
+	}
+	api := doc["schema"].([]interface{})[0].(map[interface{}]interface{})
+	if tools := api["commands"].(map[interface{}]interface{}); tools["run"].(map[interface{}]interface{})["status"] != false || tools["install"].(map[interface{}]interface{})["status"] != true {
+		t.Error("Unexpected tools", tools)
+	}
+	web := doc["projects"].([]interface{})[0].(map[interface{}]interface{})
+	// the lists of the project replace the ones of the preset
+	if watcher := web["watcher"].(map[interface{}]interface{}); !reflect.DeepEqual(watcher["ignored_paths"], []interface{}{"tmp"}) || watcher["extensions"] == nil {
+		t.Error("Unexpected watcher", watcher)
+	}
+	if !reflect.DeepEqual(doc["schema"].([]interface{})[1], map[interface{}]interface{}{"name": "other"}) {
+		t.Error("Unexpected project without preset", doc["schema"])
+	}
+	_, err = expand(map[interface{}]interface{}{"schema": []interface{}{map[interface{}]interface{}{"preset": "rails"}}})
+	if err == nil || err.Error() != `schema[0]: preset: "rails" is not a preset, expected cli, go-api, go-web` {
+		t.Error("Expected the unknown preset", err)
+	}
+}
+
+func TestSettings_Preset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	ioutil.WriteFile(RFile, []byte("schema:\n- name: app\n  preset: cli\n  args: [--debug]\n"), 0644)
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	p := r.Schema.Projects[0]
+	if !p.Tools.Test.Status || !p.Tools.Install.Status || p.Tools.Run.Status || !reflect.DeepEqual(p.Watcher.Exts, []string{"go"}) {
+		t.Error("Unexpected project", p)
+	}
+	// the values of the preset are not written
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	if strings.Contains(string(content), "install") || !strings.Contains(string(content), "preset: cli") {
+		t.Error("Unexpected values of the preset", string(content))
+	}
+}
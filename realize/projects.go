@@ -3,35 +3,65 @@ package realize
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/big"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-var (
-	msg string
-	out BufferOut
-)
-
 // Watch info
 type Watch struct {
-	Exts    []string  `yaml:"extensions" json:"extensions"`
-	Paths   []string  `yaml:"paths" json:"paths"`
-	Scripts []Command `yaml:"scripts,omitempty" json:"scripts,omitempty"`
-	Hidden  bool      `yaml:"hidden,omitempty" json:"hidden,omitempty"`
-	Ignore  []string  `yaml:"ignored_paths,omitempty" json:"ignored_paths,omitempty"`
+	Exts      []string      `yaml:"extensions" json:"extensions"`
+	Paths     []string      `yaml:"paths" json:"paths"`
+	Scripts   []Command     `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+	Hidden    bool          `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+	Ignore    []string      `yaml:"ignored_paths,omitempty" json:"ignored_paths,omitempty"`
+	Debounce  time.Duration `yaml:"debounce,omitempty" json:"debounce,omitempty"`
+	Batch     time.Duration `yaml:"batch,omitempty" json:"batch,omitempty"`
+	GitIgnore bool          `yaml:"gitignore,omitempty" json:"gitignore,omitempty"`
+	Regex     []string      `yaml:"regex,omitempty" json:"regex,omitempty"`
+	IgnRegex  []string      `yaml:"ignored_regex,omitempty" json:"ignored_regex,omitempty"`
+	Hash      bool          `yaml:"hash,omitempty" json:"hash,omitempty"`
+	Symlinks  bool          `yaml:"follow_symlinks,omitempty" json:"follow_symlinks,omitempty"`
+	Visible   []string      `yaml:"hidden_except,omitempty" json:"hidden_except,omitempty"`
+	NoCase    *bool         `yaml:"case_insensitive,omitempty" json:"case_insensitive,omitempty"`
+	Chmod     bool          `yaml:"chmod,omitempty" json:"chmod,omitempty"`
+	MaxSize   int64         `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+	Triggers  Triggers      `yaml:"triggers,omitempty" json:"triggers,omitempty"`
+	IgnFormat []string      `yaml:"ignored_format,omitempty" json:"ignored_format,omitempty"`
+	SkipFirst bool          `yaml:"skip_first_run,omitempty" json:"skip_first_run,omitempty"`
+	MaxDepth  int           `yaml:"max_depth,omitempty" json:"max_depth,omitempty"`
+	// queue the changes during a reload, a single reload of all of them follows its end
+	Queue bool `yaml:"queue,omitempty" json:"queue,omitempty"`
+	// all by default, affected to run again only the commands with a condition matching the changes
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	// max frequency of the reloads, the changes of a reload over the limit are collected until the next one
+	RateLimit RateLimit `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	regex     []*regexp.Regexp
+	ignRegex  []*regexp.Regexp
 }
 
 type Ignore struct {
@@ -41,32 +71,169 @@ type Ignore struct {
 
 // Command fields
 type Command struct {
-	Cmd    string `yaml:"command" json:"command"`
-	Type   string `yaml:"type" json:"type"`
-	Path   string `yaml:"path,omitempty" json:"path,omitempty"`
-	Global bool   `yaml:"global,omitempty" json:"global,omitempty"`
-	Output bool   `yaml:"output,omitempty" json:"output,omitempty"`
+	Cmd     string        `yaml:"command" json:"command"`
+	Type    string        `yaml:"type" json:"type"`
+	Path    string        `yaml:"path,omitempty" json:"path,omitempty"`
+	Global  bool          `yaml:"global,omitempty" json:"global,omitempty"`
+	Output  bool          `yaml:"output,omitempty" json:"output,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Shell   bool          `yaml:"shell,omitempty" json:"shell,omitempty"`
+	// signal sent on stop, the command is killed if it's still running after the stop timeout
+	StopSignal  string        `yaml:"stop_signal,omitempty" json:"stop_signal,omitempty"`
+	StopTimeout time.Duration `yaml:"stop_timeout,omitempty" json:"stop_timeout,omitempty"`
+	Retry       Retry         `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// output lines matching the error pattern are recorded as errors, and fail the command if error fail is set
+	ErrorPattern string `yaml:"error_pattern,omitempty" json:"error_pattern,omitempty"`
+	ErrorFail    bool   `yaml:"error_fail,omitempty" json:"error_fail,omitempty"`
+	// run the next commands of the sequence even if this one fails
+	IgnoreErrors bool `yaml:"ignore_errors,omitempty" json:"ignore_errors,omitempty"`
+	// file where the output is streamed, printed only in the log file unless output is set
+	Log    string `yaml:"log,omitempty" json:"log,omitempty"`
+	Append bool   `yaml:"append,omitempty" json:"append,omitempty"`
+	// forward the keyboard input to the command while it's running
+	Stdin bool `yaml:"stdin,omitempty" json:"stdin,omitempty"`
+	// wait for a tcp address, an http url or an output pattern, then run the next commands leaving this one running
+	WaitFor string `yaml:"wait_for,omitempty" json:"wait_for,omitempty"`
+	// long running command, left running until the next reload, its output is printed as it comes with the output option
+	Daemon bool `yaml:"daemon,omitempty" json:"daemon,omitempty"`
+	// run under a pseudo terminal, for the tools that disable colors without it, linux only
+	Pty bool `yaml:"pty,omitempty" json:"pty,omitempty"`
+	// priority and resource limits, linux only, the max cpu is the cpu time in seconds
+	Nice      int           `yaml:"nice,omitempty" json:"nice,omitempty"`
+	MaxMemory int64         `yaml:"max_memory,omitempty" json:"max_memory,omitempty"`
+	MaxCPU    time.Duration `yaml:"max_cpu,omitempty" json:"max_cpu,omitempty"`
+	// commands connected by their output and input, in place of the command
+	Pipe []Command `yaml:"pipe,omitempty" json:"pipe,omitempty"`
+	// hooks of the command, the after ones run even if the command is failed or stopped
+	Before []Command `yaml:"before,omitempty" json:"before,omitempty"`
+	After  []Command `yaml:"after,omitempty" json:"after,omitempty"`
+	// run inside a docker container
+	Container Container `yaml:"container,omitempty" json:"container,omitempty"`
+	// dotenv file merged into the environment of the command, relative to its path
+	EnvFile string `yaml:"env_file,omitempty" json:"env_file,omitempty"`
+	// name of a command defined in the scripts of the config
+	Use string `yaml:"use,omitempty" json:"use,omitempty"`
+	// parameters of a named command as {{.name}}, with their defaults, required if empty, and the arguments of a use
+	Params map[string]string `yaml:"params,omitempty" json:"params,omitempty"`
+	With   map[string]string `yaml:"with,omitempty" json:"with,omitempty"`
+	// overrides of an os, their non empty values replace the ones of the command
+	Windows *Command `yaml:"windows,omitempty" json:"windows,omitempty"`
+	Darwin  *Command `yaml:"darwin,omitempty" json:"darwin,omitempty"`
+	Linux   *Command `yaml:"linux,omitempty" json:"linux,omitempty"`
+	// run only if a changed path matches a glob, patterns without dirs match the file name, or a regex
+	When      []string `yaml:"when,omitempty" json:"when,omitempty"`
+	WhenRegex []string `yaml:"when_regex,omitempty" json:"when_regex,omitempty"`
+	// name of the command in its list, used by the needs of the other ones
+	Name  string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Needs []string `yaml:"needs,omitempty" json:"needs,omitempty"`
+	// commands of a graph with a higher priority are started first
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+	// name of a lock shared by the commands of all the projects, they never run at the same time
+	Lock string `yaml:"lock,omitempty" json:"lock,omitempty"`
+	// interval or cron expression, the command runs only on its schedule in place of the reloads
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+	// run only by the first reload
+	Once bool `yaml:"once,omitempty" json:"once,omitempty"`
+	// question answered from the keyboard before the command, a gate of the next ones without a command
+	Confirm Confirm `yaml:"confirm,omitempty" json:"confirm,omitempty"`
+	// env of the project of the command with the names of the project variables, and its extra env variables as key=value
+	base []string
+	keys []string
+	vars []string
+	ran  int32
+	// args of a command given as a list, run as they are in place of the split command
+	args []string
+	// project of the command and its name printed before the lines of the output of a daemon
+	project, prefix string
+}
+
+// Container of a command, a new one of the image or a running one by name
+type Container struct {
+	Image   string   `yaml:"image,omitempty" json:"image,omitempty"`
+	Name    string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Volumes []string `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Workdir string   `yaml:"workdir,omitempty" json:"workdir,omitempty"`
+}
+
+// Confirm question of a command, the default answer is used after the timeout
+type Confirm struct {
+	Message string        `yaml:"message,omitempty" json:"message,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Default bool          `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// Retry policy of a failing command, the delay is multiplied by the backoff after each attempt
+type Retry struct {
+	Count   int           `yaml:"count,omitempty" json:"count,omitempty"`
+	Delay   time.Duration `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Backoff float64       `yaml:"backoff,omitempty" json:"backoff,omitempty"`
 }
 
 // Project info
 type Project struct {
 	parent     *Realize
 	watcher    FileWatcher
-	stop       chan bool
+	ctx        context.Context
+	cancel     context.CancelFunc
 	exit       chan os.Signal
-	paths      []string
+	paths      map[string]bool
 	last       last
 	files      int64
 	folders    int64
 	init       bool
+	gitignore  *gitIgnore
+	hashes     map[string]string
+	visited    map[string]bool
+	singles    map[string]bool
+	paused     int32
+	resume     chan bool
+	trigger    chan bool
 	Name       string            `yaml:"name" json:"name"`
 	Path       string            `yaml:"path" json:"path"`
 	Env        map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Secrets    map[string]Secret `yaml:"secrets,omitempty" json:"secrets,omitempty"`
 	Args       []string          `yaml:"args,omitempty" json:"args,omitempty"`
 	Tools      Tools             `yaml:"commands" json:"commands"`
 	Watcher    Watch             `yaml:"watcher" json:"watcher"`
+	Polling    Polling           `yaml:"polling,omitempty" json:"polling,omitempty"`
+	Remote     Remote            `yaml:"remote,omitempty" json:"remote,omitempty"`
 	Buffer     Buffer            `yaml:"-" json:"buffer"`
 	ErrPattern string            `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	// time to wait between the stop of the previous run and the next reload, to free its port
+	RestartDelay time.Duration `yaml:"restart_delay,omitempty" json:"restart_delay,omitempty"`
+	// dotenv file of the project, loaded again on each reload
+	EnvFile string `yaml:"env_file,omitempty" json:"env_file,omitempty"`
+	// env of the commands of the project, the one of realize with the env variables and the env file, and the names of the project ones
+	env    []string
+	keys   []string
+	scoped *scope
+	// stop the commands and the reload at the first failure, true by default
+	StopOnError *bool `yaml:"stop_on_error,omitempty" json:"stop_on_error,omitempty"`
+	// stop the running commands of a graph at the first failure
+	FailFast bool `yaml:"fail_fast,omitempty" json:"fail_fast,omitempty"`
+	// named sets of commands, the ones of the selected profile are added to the scripts
+	Profiles map[string][]Command `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	Profile  string               `yaml:"profile,omitempty" json:"profile,omitempty"`
+	// preset of a common setup as go-api, go-web or cli, merged under the config of the project
+	Preset string `yaml:"preset,omitempty" json:"preset,omitempty"`
+	// template or project merged under this one, the name isn't inherited
+	Extends string `yaml:"extends,omitempty" json:"extends,omitempty"`
+	// options of the output of the project, over the ones of the settings
+	Options `yaml:",inline" json:",inline"`
+	// max commands of a graph running at the same time, unlimited by default
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// max time of a whole reload, the commands still running are stopped
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// overrides of an os, their non empty values replace the ones of the project
+	Windows *Project `yaml:"windows,omitempty" json:"windows,omitempty"`
+	Darwin  *Project `yaml:"darwin,omitempty" json:"darwin,omitempty"`
+	Linux   *Project `yaml:"linux,omitempty" json:"linux,omitempty"`
+}
+
+// Polling is used to force the polling watcher on a single project and tune its interval
+type Polling struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled"`
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
 }
 
 // Last is used to save info about last file changed
@@ -75,11 +242,138 @@ type last struct {
 	time time.Time
 }
 
+// RateLimit of the reloads, a burst of reloads then at most one every interval
+type RateLimit struct {
+	Every time.Duration `yaml:"every,omitempty" json:"every,omitempty"`
+	Burst int           `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// Limiter counts the reloads left by the rate limit
+type limiter struct {
+	RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// Batch collects the changes of a pending reload
+type batch struct {
+	file     string
+	files    []string
+	event    fsnotify.Event
+	deadline time.Time
+}
+
+// Scope cancels the running instance of a command restarted alone, by the affected strategy
+type scope struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// Restart cancels the previous instance of a command, the returned context is cancelled by the next one or with its parent
+func (s *scope) restart(ctx context.Context, key string) context.Context {
+	s.Lock()
+	defer s.Unlock()
+	if cancel, ok := s.cancels[key]; ok {
+		cancel()
+	}
+	ctx, s.cancels[key] = context.WithCancel(ctx)
+	return ctx
+}
+
+// Locks of the commands by name, shared by all the projects
+var locks = struct {
+	sync.Mutex
+	m map[string]chan bool
+}{m: make(map[string]chan bool)}
+
+// Lock waits a named lock, false if the context is done before, a command without a lock name isn't locked
+func lock(ctx context.Context, name string) bool {
+	if name == "" {
+		return true
+	}
+	locks.Lock()
+	l, ok := locks.m[name]
+	if !ok {
+		l = make(chan bool, 1)
+		locks.m[name] = l
+	}
+	locks.Unlock()
+	select {
+	case l <- true:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Unlock releases a named lock
+func unlock(name string) {
+	if name == "" {
+		return
+	}
+	locks.Lock()
+	l := locks.m[name]
+	locks.Unlock()
+	<-l
+}
+
+// Trigger binds some extensions to a custom debounce and to the commands run in place of the whole reload
+// files matching the trigger paths are watched regardless of the extensions, reload is used to run the whole reload after the commands
+// the ignored paths are left to the next triggers, so a trigger can be used as a watch group with its own commands
+type Trigger struct {
+	Exts     []string      `yaml:"extensions,omitempty" json:"extensions,omitempty"`
+	Paths    []string      `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Ignore   []string      `yaml:"ignored_paths,omitempty" json:"ignored_paths,omitempty"`
+	Debounce time.Duration `yaml:"debounce,omitempty" json:"debounce,omitempty"`
+	Scripts  []Command     `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+	Reload   bool          `yaml:"reload,omitempty" json:"reload,omitempty"`
+}
+
+// Triggers list, also defined as a map of extensions or glob patterns to their commands
+type Triggers []Trigger
+
+// UnmarshalYAML reads a list of triggers or a map of extensions and patterns to commands
+func (t *Triggers) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []Trigger
+	if err := unmarshal(&list); err == nil {
+		*t = list
+		return nil
+	}
+	var routes map[string][]Command
+	if err := unmarshal(&routes); err != nil {
+		return err
+	}
+	var keys []string
+	for k := range routes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	*t = nil
+	for _, k := range keys {
+		trigger := Trigger{Scripts: routes[k]}
+		if hasMeta(k) || strings.Contains(k, "/") {
+			trigger.Paths = []string{k}
+		} else {
+			trigger.Exts = []string{k}
+		}
+		*t = append(*t, trigger)
+	}
+	return nil
+}
+
 // Response exec
 type Response struct {
-	Name string
-	Out  string
-	Err  error
+	Name     string
+	Out      string
+	Err      error
+	Timeout  bool
+	Errors   []string
+	ExitCode int
+	Duration time.Duration
+	// closed at the exit of a command left running, as a daemon
+	exited <-chan struct{}
+	// failure of a command with ignore errors
+	ignored bool
 }
 
 // Buffer define an array buffer for each log files
@@ -105,289 +399,1600 @@ func (p *Project) After() {
 		p.parent.After(Context{Project: p})
 		return
 	}
-	p.cmd(nil, "after", true)
+	p.cmd(context.Background(), "after", true)
 }
 
 // Before start watcher
 func (p *Project) Before() {
+	var (
+		msg string
+		out BufferOut
+	)
 	if p.parent.Before != nil {
 		p.parent.Before(Context{Project: p})
 		return
 	}
+	// overrides of the os, named commands and defaults
+	p.prepare()
 	// setup go tools
 	p.Tools.Setup()
+	// env variables
+	p.environment()
 	// global commands before
-	p.cmd(p.stop, "before", true)
+	p.cmd(p.current(), "before", true)
+	// compile regex patterns
+	if err := p.Watcher.compile(); err != nil {
+		p.Err(err)
+	}
+	// read .gitignore rules
+	if p.Watcher.GitIgnore {
+		p.gitignore = &gitIgnore{}
+		base, _ := filepath.Abs(p.Path)
+		if err := p.gitignore.Load(base); err != nil {
+			p.Err(err)
+		}
+	}
 	// indexing files and dirs
 	for _, dir := range p.Watcher.Paths {
+		if strings.HasPrefix(dir, "!") {
+			continue
+		}
 		base, _ := filepath.Abs(p.Path)
-		base = filepath.Join(base, dir)
-		if _, err := os.Stat(base); err == nil {
+		base = filepath.Join(base, globBase(dir))
+		if fi, err := os.Stat(base); err == nil {
+			if !fi.IsDir() {
+				// single file
+				p.single(base)
+				continue
+			}
+			p.visit(base)
 			if err := filepath.Walk(base, p.walk); err != nil {
 				p.Err(err)
 			}
 		}
 	}
+	// reload on the changes of the env file
+	if p.EnvFile != "" && p.watcher != nil {
+		base, _ := filepath.Abs(p.Path)
+		if path := filepath.Join(base, p.EnvFile); !p.singles[path] {
+			p.single(path)
+		}
+	}
+	// paths over the inotify watches limit
+	if w, ok := p.watcher.(*fsNotifyWatcher); ok {
+		if p.recovery().Index {
+			leveled("debug", "Watches:", p.files+p.folders, "Limit:", WatchesLimit(), "Polled:", w.Polled(), "Remote:", w.Remote())
+		}
+		if remote := w.Remote(); remote > 0 {
+			text := fmt.Sprint(remote, " path/s on a network filesystem moved to polling, fs events are unreliable there")
+			msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Blue.Regular(text))
+			out = BufferOut{Time: time.Now(), Text: text}
+			p.stamp("log", out, msg, "")
+		}
+		if w.Limited() {
+			// the counts of the watches whatever the recovery settings, the limit is raised by the fs.inotify.max_user_watches sysctl
+			text := fmt.Sprint("inotify watches limit reached, ", p.files+p.folders, " watches needed, ", WatchesLimit(), " available, ", w.Polled()-w.Remote(), " path/s moved to polling")
+			msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(text))
+			out = BufferOut{Time: time.Now(), Text: text}
+			p.stamp("error", out, msg, "")
+		}
+	}
 	// start message
 	msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Blue.Bold("Watching"), Magenta.Bold(p.files), "file/s", Magenta.Bold(p.folders), "folder/s")
 	out = BufferOut{Time: time.Now(), Text: "Watching " + strconv.FormatInt(p.files, 10) + " files/s " + strconv.FormatInt(p.folders, 10) + " folder/s"}
 	p.stamp("log", out, msg, "")
 }
 
-// Err occurred
-func (p *Project) Err(err error) {
-	if p.parent.Err != nil {
-		p.parent.Err(Context{Project: p})
-		return
+// Plan prints the watched files and the commands of a reload in their order, nothing is run
+func (p *Project) Plan(w io.Writer) error {
+	p.prepare()
+	p.Tools.Setup()
+	if err := p.Watcher.compile(); err != nil {
+		return err
 	}
-	if err != nil {
-		msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(err.Error()))
-		out = BufferOut{Time: time.Now(), Text: err.Error()}
-		p.stamp("error", out, msg, "")
+	if p.Watcher.GitIgnore {
+		p.gitignore = &gitIgnore{}
+		base, _ := filepath.Abs(p.Path)
+		if err := p.gitignore.Load(base); err != nil {
+			return err
+		}
 	}
-}
-
-// Change event message
-func (p *Project) Change(event fsnotify.Event) {
-	if p.parent.Change != nil {
-		p.parent.Change(Context{Project: p, Event: event})
-		return
+	fmt.Fprintln(w, p.Name+":", p.Path)
+	fmt.Fprintln(w, "  watched:")
+	for _, dir := range p.Watcher.Paths {
+		if strings.HasPrefix(dir, "!") {
+			continue
+		}
+		base, _ := filepath.Abs(p.Path)
+		err := p.walkFiles(filepath.Join(base, globBase(dir)), func(path string) {
+			fmt.Fprintln(w, "    -", p.rel(path))
+		})
+		if err != nil {
+			return err
+		}
 	}
-	// file extension
-	ext := ext(event.Name)
-	if ext == "" {
-		ext = "DIR"
+	plan(w, "startup", p.scripts("before", true))
+	plan(w, "before", p.scripts("before", false))
+	var tools []Command
+	v := reflect.ValueOf(p.Tools)
+	for i := 0; i < v.NumField(); i++ {
+		if tool := v.Field(i).Interface().(Tool); tool.Status && tool.name != "" {
+			tools = append(tools, Command{Name: tool.name, Cmd: strings.Join(append(tool.cmd, tool.Args...), " ")})
+		}
 	}
-	// change message
-	msg = fmt.Sprintln(p.pname(p.Name, 4), ":", Magenta.Bold(strings.ToUpper(ext)), "changed", Magenta.Bold(event.Name))
-	out = BufferOut{Time: time.Now(), Text: ext + " changed " + event.Name}
-	p.stamp("log", out, msg, "")
-}
-
-// Reload launches the toolchain run, build, install
-func (p *Project) Reload(path string, stop <-chan bool) {
-	if p.parent.Reload != nil {
-		p.parent.Reload(Context{Project: p, Watcher: p.watcher, Path: path, Stop: stop})
-		return
+	if p.Tools.Run.Status {
+		tools = append(tools, Command{Name: "Run", Cmd: strings.Join(append([]string{"go", "run"}, p.Args...), " ")})
 	}
-	var done bool
-	var install, build Response
-	go func() {
-		for {
-			select {
-			case <-stop:
-				done = true
-				return
-			}
-		}
-	}()
-	if done {
-		return
+	plan(w, "tools", tools)
+	plan(w, "after", p.scripts("after", false))
+	plan(w, "success", p.scripts("success", false))
+	plan(w, "error", p.scripts("error", false))
+	plan(w, "exit", p.scripts("after", true))
+	for i, t := range p.Watcher.Triggers {
+		plan(w, "trigger "+strconv.Itoa(i+1)+" "+strings.Join(append(t.Exts, t.Paths...), ", "), t.Scripts)
 	}
-	// before command
-	p.cmd(stop, "before", false)
-	if done {
-		return
+	var scheduled []Command
+	for _, cmd := range p.Watcher.Scripts {
+		if cmd.Schedule != "" {
+			scheduled = append(scheduled, cmd)
+		}
 	}
-	// Go supported tools
-	if len(path) > 0 {
-		fi, err := os.Stat(path)
-		if filepath.Ext(path) == "" {
-			fi, err = os.Stat(path)
+	plan(w, "scheduled", scheduled)
+	return nil
+}
+
+// WalkFiles walks the watched files of a path, as the watcher at startup
+func (p *Project) walkFiles(base string, fn func(path string)) error {
+	return p.walkTree(base, func(path string, info os.FileInfo) {
+		if !info.IsDir() && (path == base || p.Validate(path, true)) {
+			fn(path)
 		}
+	})
+}
+
+// WalkTree walks the files and the dirs of a path, the ignored dirs are reached but not walked
+func (p *Project) walkTree(base string, fn func(path string, info os.FileInfo)) error {
+	return filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			p.Err(err)
+			return nil
+		}
+		fn(path, info)
+		if info.IsDir() && path != base {
+			if p.Watcher.MaxDepth > 0 && p.depth(path) > p.Watcher.MaxDepth || p.skipped(path) {
+				return filepath.SkipDir
+			}
+		}
+		if info.IsDir() && p.gitignore != nil {
+			p.gitignore.Load(path)
+		}
+		return nil
+	})
+}
+
+// Skipped checks if a dir is ignored with all its files, none of them is included again by a "!" ignored path
+func (p *Project) skipped(dir string) bool {
+	rel := p.rel(dir)
+	for _, v := range p.ignores() {
+		if n := strings.TrimPrefix(v, "!"); n != v && (within(n, rel) || within(rel, n)) {
+			return false
 		}
-		p.tools(stop, path, fi)
 	}
-	// Prevent fake events on polling startup
-	p.init = true
-	// prevent errors using realize without config with only run flag
-	if p.Tools.Run.Status && !p.Tools.Install.Status && !p.Tools.Build.Status {
-		p.Tools.Install.Status = true
+	return !p.Validate(dir, false)
+}
+
+// Check prints the problems of a project and returns the number of errors, the missing paths are errors
+// the watched paths without files and the ignored paths excluding a whole watched path are warnings
+func (p *Project) Check(w io.Writer) (errs int) {
+	p.prepare()
+	fmt.Fprintln(w, p.Name+":", p.Path)
+	report := func(level, msg string) {
+		if level == "error" {
+			errs++
+		}
+		fmt.Fprintln(w, "  "+level+":", msg)
 	}
-	if done {
-		return
+	base, _ := filepath.Abs(p.Path)
+	if _, err := os.Stat(base); err != nil {
+		report("error", "path: "+err.Error())
+		return errs
 	}
-	if p.Tools.Install.Status {
-		msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Green.Regular(p.Tools.Install.name), "started")
-		out = BufferOut{Time: time.Now(), Text: p.Tools.Install.name + " started"}
-		p.stamp("log", out, msg, "")
-		start := time.Now()
-		install = p.Tools.Install.Compile(p.Path, stop)
-		install.print(start, p)
+	p.Tools.Setup()
+	if err := p.Watcher.compile(); err != nil {
+		report("error", err.Error())
 	}
-	if done {
-		return
+	if p.Watcher.GitIgnore {
+		p.gitignore = &gitIgnore{}
+		if err := p.gitignore.Load(base); err != nil {
+			report("error", "gitignore: "+err.Error())
+		}
 	}
-	if p.Tools.Build.Status {
-		msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Green.Regular(p.Tools.Build.name), "started")
-		out = BufferOut{Time: time.Now(), Text: p.Tools.Build.name + " started"}
-		p.stamp("log", out, msg, "")
-		start := time.Now()
-		build = p.Tools.Build.Compile(p.Path, stop)
-		build.print(start, p)
+	if p.EnvFile != "" {
+		if _, err := os.Stat(filepath.Join(base, p.EnvFile)); err != nil {
+			report("error", "env_file: "+err.Error())
+		}
 	}
-	if done {
-		return
+	cmds := append([]Command{}, p.Watcher.Scripts...)
+	for _, t := range p.Watcher.Triggers {
+		cmds = append(cmds, t.Scripts...)
 	}
-	if install.Err == nil && build.Err == nil && p.Tools.Run.Status {
-		result := make(chan Response)
-		go func() {
-			for {
-				select {
-				case <-stop:
-					return
-				case r := <-result:
-					if r.Err != nil {
-						msg := fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(r.Err))
-						out := BufferOut{Time: time.Now(), Text: r.Err.Error(), Type: "Go Run"}
-						p.stamp("error", out, msg, "")
-					}
-					if r.Out != "" {
-						msg := fmt.Sprintln(p.pname(p.Name, 3), ":", Blue.Regular(r.Out))
-						out := BufferOut{Time: time.Now(), Text: r.Out, Type: "Go Run"}
-						p.stamp("out", out, msg, "")
-					}
-				}
+	for _, c := range cmds {
+		if c.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(c.dir(base)); err != nil {
+			report("error", "command "+c.Cmd+": path: "+err.Error())
+		}
+	}
+	for _, dir := range p.Watcher.Paths {
+		if strings.HasPrefix(dir, "!") {
+			continue
+		}
+		root := filepath.Join(base, globBase(dir))
+		if _, err := os.Stat(root); err != nil {
+			if hasMeta(dir) {
+				report("warning", "watcher.paths: "+dir+" matches nothing")
+			} else {
+				report("error", "watcher.paths: "+err.Error())
 			}
-		}()
-		go func() {
-			log.Println(p.pname(p.Name, 1), ":", "Running..")
-			err := p.run(p.Path, result, stop)
-			if err != nil {
-				msg := fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(err))
-				out := BufferOut{Time: time.Now(), Text: err.Error(), Type: "Go Run"}
-				p.stamp("error", out, msg, "")
+			continue
+		}
+		for _, v := range p.ignores() {
+			if !strings.HasPrefix(v, "!") && within(v, p.rel(root)) {
+				report("warning", "watcher.ignored_paths: "+v+" excludes the whole watched path "+dir)
 			}
-		}()
-	}
-	if done {
-		return
+		}
+		n := 0
+		p.walkFiles(root, func(string) { n++ })
+		if n == 0 {
+			report("warning", "watcher.paths: "+dir+" has no watched file")
+		}
 	}
-	p.cmd(stop, "after", false)
+	return errs
 }
 
-// Watch a project
-func (p *Project) Watch(wg *sync.WaitGroup) {
-	var err error
-	// change channel
-	p.stop = make(chan bool)
-	// init a new watcher
-	p.watcher, err = NewFileWatcher(p.parent.Settings.Legacy)
-	if err != nil {
-		log.Fatal(err)
+// Lint prints the suggestions of a project and returns their number: the paths watched twice, the ignored paths
+// and the extensions matching no file of the watched paths, and the missing dirs of the commands and of the tools
+func (p *Project) Lint(w io.Writer) (hints int) {
+	p.prepare()
+	fmt.Fprintln(w, p.Name+":", p.Path)
+	suggest := func(msg, fix string) {
+		hints++
+		fmt.Fprintln(w, "  lint:", msg)
+		fmt.Fprintln(w, "    suggestion:", fix)
 	}
-	defer func() {
-		close(p.stop)
-		p.watcher.Close()
-	}()
-	// before start checks
-	p.Before()
-	// start watcher
-	go p.Reload("", p.stop)
-L:
-	for {
-		select {
-		case event := <-p.watcher.Events():
-			if p.parent.Settings.Recovery.Events {
-				log.Println("File:", event.Name, "LastFile:", p.last.file, "Time:", time.Now(), "LastTime:", p.last.time)
-			}
-			if time.Now().Truncate(time.Second).After(p.last.time) {
-				// switch event type
-				switch event.Op {
-				case fsnotify.Chmod:
-				case fsnotify.Remove:
-					p.watcher.Remove(event.Name)
-					if p.Validate(event.Name, false) && ext(event.Name) != "" {
-						// stop and restart
-						close(p.stop)
-						p.stop = make(chan bool)
-						p.Change(event)
-						go p.Reload("", p.stop)
-					}
-				default:
-					if p.Validate(event.Name, true) {
-						fi, err := os.Stat(event.Name)
-						if err != nil {
-							continue
-						}
-						if fi.IsDir() {
-							filepath.Walk(event.Name, p.walk)
-						} else {
-							// stop and restart
-							close(p.stop)
-							p.stop = make(chan bool)
-							p.Change(event)
-							go p.Reload(event.Name, p.stop)
-							p.last.time = time.Now().Truncate(time.Second)
-							p.last.file = event.Name
-						}
-					}
-				}
+	base, _ := filepath.Abs(p.Path)
+	if _, err := os.Stat(base); err != nil {
+		// an error of the check
+		return hints
+	}
+	// the errors of the patterns and of the gitignore are the ones of the check
+	p.Watcher.compile()
+	if p.Watcher.GitIgnore {
+		p.gitignore = &gitIgnore{}
+		p.gitignore.Load(base)
+	}
+	// the roots of the watched paths, relative to the project
+	var dirs, roots []string
+	for _, dir := range p.Watcher.Paths {
+		root := filepath.Join(base, globBase(dir))
+		if _, err := os.Stat(root); err == nil && !strings.HasPrefix(dir, "!") {
+			dirs, roots = append(dirs, dir), append(roots, p.rel(root))
+		}
+	}
+	for i := range roots {
+		for j := range roots {
+			if i != j && within(roots[j], roots[i]) && (roots[i] != roots[j] || i > j) && !hasMeta(dirs[i]) && !hasMeta(dirs[j]) {
+				suggest("watcher.paths: "+dirs[i]+" is inside "+dirs[j]+", its files are watched twice", "remove "+dirs[i])
+				break
 			}
-		case err := <-p.watcher.Errors():
-			p.Err(err)
-		case <-p.exit:
-			p.After()
-			break L
 		}
 	}
-	wg.Done()
-}
-
-// Validate a file path
-func (p *Project) Validate(path string, fcheck bool) bool {
-	if len(path) <= 0 {
-		return false
+	// the files and the dirs of the watched paths with their extensions, the ignored dirs without their files
+	var files []string
+	exts := make(map[string]int)
+	for _, root := range roots {
+		p.walkTree(filepath.Join(base, root), func(path string, info os.FileInfo) {
+			files = append(files, p.rel(path))
+			if !info.IsDir() && ext(path) != "" {
+				exts[ext(path)]++
+			}
+		})
 	}
-	// check if skip hidden
-	if p.Watcher.Hidden && isHidden(path) {
-		return false
+	defaults := make(map[string]bool)
+	for _, v := range defaultIgnore {
+		defaults[v] = true
 	}
-	// check for a valid ext or path
-	if e := ext(path); e != "" {
-		if len(p.Watcher.Exts) == 0 {
-			return false
+	for _, v := range p.Watcher.Ignore {
+		if strings.HasPrefix(v, "!") || defaults[v] {
+			// the defaults of the new projects aren't suggested
+			continue
 		}
-		// check ignored
-		for _, v := range p.Watcher.Ignore {
-			if v == e {
-				return false
+		found := false
+		for _, f := range files {
+			if found = within(v, f); found {
+				break
 			}
 		}
-		// supported extensions
-		for index, v := range p.Watcher.Exts {
-			if e == v {
+		if !found {
+			suggest("watcher.ignored_paths: "+v+" matches no file of the watched paths", "remove it, the ignored paths are relative to the project path "+base)
+		}
+	}
+	for _, e := range p.Watcher.Exts {
+		found := false
+		for v := range exts {
+			if found = p.Watcher.sameExt(strings.TrimPrefix(e, "."), v); found {
 				break
 			}
-			if index == len(p.Watcher.Exts)-1 {
-				return false
-			}
+		}
+		if !found {
+			suggest("watcher.extensions: "+e+" matches no file of the watched paths", "remove it, the files have the extensions "+common(exts))
 		}
 	}
-	separator := string(os.PathSeparator)
-	// supported paths
-	for _, v := range p.Watcher.Ignore {
-		s := append([]string{p.Path}, strings.Split(v, separator)...)
-		abs, _ := filepath.Abs(filepath.Join(s...))
-		if path == abs || strings.HasPrefix(path, abs+separator) {
-			return false
+	var cmds []Command
+	var add func(list []Command)
+	add = func(list []Command) {
+		for _, c := range list {
+			cmds = append(cmds, c)
+			add(c.Pipe)
+			add(c.Before)
+			add(c.After)
 		}
 	}
-	// file check
-	if fcheck {
-		fi, err := os.Stat(path)
-		if err != nil || fi.Mode()&os.ModeSymlink != 0 || !fi.IsDir() && ext(path) == "" || fi.Size() <= 0 {
-			return false
+	add(p.Watcher.Scripts)
+	for _, t := range p.Watcher.Triggers {
+		add(t.Scripts)
+	}
+	for _, c := range cmds {
+		if _, err := os.Stat(c.dir(base)); c.Path != "" && err != nil {
+			suggest("command "+c.Cmd+": path: "+c.Path+" doesn't exist", nearest(base, filepath.Base(c.Path), "the command path is relative to the project path "+base))
 		}
 	}
-	return true
-
+	tools := map[string]Tool{"clean": p.Tools.Clean, "vet": p.Tools.Vet, "fmt": p.Tools.Fmt, "test": p.Tools.Test, "generate": p.Tools.Generate, "install": p.Tools.Install, "build": p.Tools.Build, "run": p.Tools.Run}
+	for _, name := range []string{"clean", "vet", "fmt", "test", "generate", "install", "build", "run"} {
+		t := tools[name]
+		if _, err := os.Stat(t.Dir); t.Status && t.Dir != "" && err != nil {
+			abs, _ := filepath.Abs(t.Dir)
+			suggest("commands."+name+".dir: "+t.Dir+" doesn't exist", nearest(base, filepath.Base(t.Dir), "the dir is relative to the working directory, as "+abs))
+		}
+	}
+	return hints
+}
+
+// Common returns the extensions of the files by their number, the five most used
+func common(exts map[string]int) string {
+	var list []string
+	for e := range exts {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return exts[list[i]] > exts[list[j]] || exts[list[i]] == exts[list[j]] && list[i] < list[j]
+	})
+	if len(list) > 5 {
+		list = list[:5]
+	}
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
+
+// Nearest suggests a dir of the project with the given name, the fallback if there isn't one
+func nearest(base, name, fallback string) string {
+	found := ""
+	filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if found != "" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() && path != base && info.Name() == name {
+			found = path
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if found == "" {
+		return fallback
+	}
+	rel, _ := filepath.Rel(base, found)
+	return "did you mean " + filepath.ToSlash(rel) + "? " + fallback
+}
+
+// Scripts returns the commands of a type, without the scheduled ones
+func (p *Project) scripts(flag string, global bool) (cmds []Command) {
+	for _, cmd := range p.Watcher.Scripts {
+		if strings.ToLower(cmd.Type) == flag && cmd.Global == global && cmd.Schedule == "" {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// Plan prints a list of commands, run as a sequence or as a graph
+func plan(w io.Writer, title string, cmds []Command) {
+	if len(cmds) == 0 {
+		return
+	}
+	mode := "sequence"
+	if graph(cmds) {
+		mode = "graph"
+	}
+	fmt.Fprintln(w, "  "+title+" ("+mode+"):")
+	for _, c := range cmds {
+		line := c.Cmd
+		if len(c.Pipe) > 0 {
+			var names []string
+			for _, s := range c.Pipe {
+				names = append(names, s.Cmd)
+			}
+			line = strings.Join(names, " | ")
+		}
+		if c.Name != "" {
+			line = c.Name + ": " + line
+		}
+		if len(c.Needs) > 0 {
+			line += " [needs " + strings.Join(c.Needs, ", ") + "]"
+		}
+		if c.conditional() {
+			line += " [when " + strings.Join(append(c.When, c.WhenRegex...), ", ") + "]"
+		}
+		if c.Schedule != "" {
+			line += " [schedule " + c.Schedule + "]"
+		}
+		if c.Once {
+			line += " [once]"
+		}
+		if c.Confirm.Message != "" {
+			line += " [confirm " + c.Confirm.Message + "]"
+		}
+		for i, hooks := range [][]Command{c.Before, c.After} {
+			if len(hooks) > 0 {
+				var names []string
+				for _, h := range hooks {
+					names = append(names, h.Cmd)
+				}
+				line += " [" + []string{"before", "after"}[i] + " " + strings.Join(names, ", ") + "]"
+			}
+		}
+		fmt.Fprintln(w, "    -", line)
+	}
+}
+
+// Err occurred
+func (p *Project) Err(err error) {
+	var (
+		msg string
+		out BufferOut
+	)
+	if p.parent.Err != nil {
+		p.parent.Err(Context{Project: p})
+		return
+	}
+	if err != nil {
+		msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(err.Error()))
+		out = BufferOut{Time: time.Now(), Text: err.Error()}
+		p.stamp("error", out, msg, "")
+	}
+}
+
+// Resolve adds the commands of the selected profile, then replaces the commands using a named command with their definition
+func (p *Project) resolve() {
+	if p.Profile != "" {
+		if cmds, ok := p.Profiles[p.Profile]; ok {
+			p.Watcher.Scripts = append(p.Watcher.Scripts, cmds...)
+		} else {
+			p.Err(errors.New("profile " + p.Profile + " not found"))
+		}
+	}
+	list := func(cmds []Command) []Command {
+		var resolved []Command
+		for _, cmd := range cmds {
+			c, err := p.parent.Schema.resolve(cmd)
+			if err != nil {
+				p.Err(err)
+			}
+			resolved = append(resolved, c)
+		}
+		return resolved
+	}
+	p.Watcher.Scripts = list(p.Watcher.Scripts)
+	triggers := make([]Trigger, len(p.Watcher.Triggers))
+	for i, t := range p.Watcher.Triggers {
+		t.Scripts = list(t.Scripts)
+		triggers[i] = t
+	}
+	p.Watcher.Triggers = triggers
+}
+
+// Platform applies the overrides of the current os
+func (p *Project) platform() {
+	o := map[string]*Project{"windows": p.Windows, "darwin": p.Darwin, "linux": p.Linux}[runtime.GOOS]
+	p.Windows, p.Darwin, p.Linux = nil, nil, nil
+	if o != nil {
+		overlay(reflect.ValueOf(p).Elem(), reflect.ValueOf(*o))
+	}
+}
+
+// Platform returns a command with the overrides of the current os
+func (c Command) platform() Command {
+	o := map[string]*Command{"windows": c.Windows, "darwin": c.Darwin, "linux": c.Linux}[runtime.GOOS]
+	c.Windows, c.Darwin, c.Linux = nil, nil, nil
+	if o != nil {
+		overlay(reflect.ValueOf(&c).Elem(), reflect.ValueOf(*o))
+	}
+	return c
+}
+
+// Overlay sets the non empty fields of a struct over the ones of another, the nested structs are merged field by field
+func overlay(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		if src.Type().Field(i).PkgPath != "" {
+			// unexported
+			continue
+		}
+		f := src.Field(i)
+		if reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+			continue
+		}
+		if f.Kind() == reflect.Struct {
+			overlay(dst.Field(i), f)
+			continue
+		}
+		dst.Field(i).Set(f)
+	}
+}
+
+// Options returns the options of the settings overridden by the ones of the project
+func (p *Project) options() Options {
+	var o Options
+	if p.parent != nil {
+		o = p.parent.Settings.Options
+	}
+	overlay(reflect.ValueOf(&o).Elem(), reflect.ValueOf(p.Options))
+	return o
+}
+
+// Recovery returns the details printed of the watcher and of the commands, all of them if verbose
+func (p *Project) recovery() Recovery {
+	o := p.options()
+	if o.Verbosity == "verbose" {
+		return Recovery{Index: true, Events: true, Tools: true}
+	}
+	return o.Recovery
+}
+
+// Prepare applies the os overrides, the profile, the named commands and the defaults of a project
+// as by its start, the same ones printed by the resolved config
+func (p *Project) prepare() {
+	p.platform()
+	p.resolve()
+	p.defaults()
+}
+
+// Defaults sets the default values of a project, the ones used if a value is empty
+// the profile is already in the scripts
+func (p *Project) defaults() {
+	if p.Watcher.Debounce == 0 {
+		p.Watcher.Debounce = Debounce
+	}
+	if p.StopOnError == nil {
+		stop := true
+		p.StopOnError = &stop
+	}
+	stops := func(cmds []Command) {
+		for i, c := range cmds {
+			if c.StopSignal != "" && c.StopTimeout == 0 {
+				cmds[i].StopTimeout = Grace
+			}
+		}
+	}
+	stops(p.Watcher.Scripts)
+	for _, t := range p.Watcher.Triggers {
+		stops(t.Scripts)
+	}
+	p.Profile, p.Profiles = "", nil
+}
+
+// Environment sets the env of the commands of a project, the variables of the env file don't override the env ones
+// the env of realize isn't changed, the secrets and the env file are read again on each call
+func (p *Project) environment() {
+	vars := make(map[string]string)
+	// the secrets not resolved, unset instead of a previous value
+	unset := make(map[string]bool)
+	if p.EnvFile != "" {
+		base, _ := filepath.Abs(p.Path)
+		envs, err := dotenv(filepath.Join(base, p.EnvFile))
+		if err != nil {
+			p.Err(err)
+		}
+		for k, v := range envs {
+			vars[k] = v
+		}
+	}
+	for k, v := range p.Env {
+		vars[k] = v
+	}
+	for k, v := range p.Secrets {
+		value, err := v.resolve()
+		if err != nil {
+			p.Err(errors.New("secrets " + k + ": " + err.Error()))
+			delete(vars, k)
+			unset[k] = true
+			continue
+		}
+		vars[k] = value
+	}
+	var env []string
+	for _, e := range inherited() {
+		k := strings.SplitN(e, "=", 2)[0]
+		if _, ok := vars[k]; !ok && !unset[k] {
+			env = append(env, e)
+		}
+	}
+	p.keys = sortedMap(vars)
+	for _, k := range p.keys {
+		env = append(env, k+"="+vars[k])
+	}
+	p.env = env
+}
+
+// Environ returns the env of the commands of a project, the one of realize before the first reload
+func (p *Project) environ() []string {
+	if p == nil || p.env == nil {
+		return inherited()
+	}
+	return p.env
+}
+
+// Result sends the response of a tool or a command to the result func, if any
+func (p *Project) result(r Response) {
+	if p.parent.Result != nil {
+		p.parent.Result(Context{Project: p, Response: r})
+	}
+}
+
+// Change event message
+func (p *Project) Change(event fsnotify.Event) {
+	var (
+		msg string
+		out BufferOut
+	)
+	if p.parent.Change != nil {
+		p.parent.Change(Context{Project: p, Event: event})
+		return
+	}
+	// file extension
+	ext := ext(event.Name)
+	if ext == "" {
+		ext = "DIR"
+	}
+	// change message
+	msg = fmt.Sprintln(p.pname(p.Name, 4), ":", Magenta.Bold(strings.ToUpper(ext)), "changed", Magenta.Bold(event.Name))
+	out = BufferOut{Time: time.Now(), Text: ext + " changed " + event.Name}
+	p.stamp("log", out, msg, "")
+}
+
+// Reload launches the toolchain run, build, install, paths are all the files changed in a batch
+func (p *Project) Reload(ctx context.Context, path string, paths ...string) {
+	var (
+		msg string
+		out BufferOut
+	)
+	if p.parent.Reload != nil {
+		p.parent.Reload(Context{Project: p, Watcher: p.watcher, Path: path, Paths: paths, Ctx: ctx})
+		return
+	}
+	// wait the previous run to release its resources
+	if p.init && p.RestartDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.RestartDelay):
+		}
+	}
+	if p.EnvFile != "" || len(p.Env) > 0 || len(p.Secrets) > 0 {
+		p.environment()
+	}
+	ctx = context.WithValue(ctx, failures{}, new(int32))
+	if p.Timeout > 0 {
+		// the commands left running after the reload, as the run, are stopped only by the next one
+		reload := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithValue(ctx, lifetime{}, reload), p.Timeout)
+		defer func() {
+			if ctx.Err() == context.DeadlineExceeded {
+				r := Response{Name: "reload", Err: errors.New("reload killed after a timeout of " + p.Timeout.String()), Timeout: true}
+				msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Bold("Reload"), Red.Regular("killed after a timeout of"), Magenta.Bold(p.Timeout))
+				out = BufferOut{Time: time.Now(), Text: r.Err.Error()}
+				p.stamp("error", out, msg, "")
+				p.result(r)
+				p.failed(reload, r)
+			}
+			cancel()
+		}()
+	}
+	var install, build Response
+	if ctx.Err() != nil {
+		return
+	}
+	if len(paths) == 0 && len(path) > 0 {
+		paths = []string{path}
+	}
+	// before command
+	p.cmd(ctx, "before", false, paths...)
+	if ctx.Err() != nil {
+		return
+	}
+	// Go supported tools
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			p.Err(err)
+			continue
+		}
+		p.tools(ctx, path, fi)
+	}
+	// Prevent fake events on polling startup
+	p.init = true
+	// prevent errors using realize without config with only run flag
+	if p.Tools.Run.Status && !p.Tools.Install.Status && !p.Tools.Build.Status {
+		p.Tools.Install.Status = true
+	}
+	if ctx.Err() != nil || p.broken(ctx) {
+		return
+	}
+	if p.Tools.Install.Status {
+		msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Green.Regular(p.Tools.Install.name), "started")
+		out = BufferOut{Time: time.Now(), Text: p.Tools.Install.name + " started"}
+		p.stamp("log", out, msg, "")
+		start := time.Now()
+		install = p.Tools.Install.Compile(ctx, p.Path)
+		install.print(start, p)
+		if install.Err != nil {
+			p.failed(ctx, install)
+		}
+	}
+	if ctx.Err() != nil || p.broken(ctx) {
+		return
+	}
+	if p.Tools.Build.Status {
+		msg = fmt.Sprintln(p.pname(p.Name, 1), ":", Green.Regular(p.Tools.Build.name), "started")
+		out = BufferOut{Time: time.Now(), Text: p.Tools.Build.name + " started"}
+		p.stamp("log", out, msg, "")
+		start := time.Now()
+		build = p.Tools.Build.Compile(ctx, p.Path)
+		build.print(start, p)
+		if build.Err != nil {
+			p.failed(ctx, build)
+		}
+	}
+	if ctx.Err() != nil || p.broken(ctx) {
+		return
+	}
+	if install.Err == nil && build.Err == nil && p.Tools.Run.Status {
+		ctx := alive(ctx)
+		result := make(chan Response)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case r := <-result:
+					if r.Err != nil {
+						msg := fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(r.Err))
+						out := BufferOut{Time: time.Now(), Text: r.Err.Error(), Type: "Go Run"}
+						p.stamp("error", out, msg, "")
+					}
+					if r.Out != "" {
+						msg := fmt.Sprintln(p.pname(p.Name, 3), ":", Blue.Regular(r.Out))
+						out := BufferOut{Time: time.Now(), Text: r.Out, Type: "Go Run"}
+						p.stamp("out", out, msg, "")
+					}
+				}
+			}
+		}()
+		go func() {
+			log.Println(p.pname(p.Name, 1), ":", "Running..")
+			err := p.run(ctx, p.Path, result)
+			if err != nil {
+				msg := fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Regular(err))
+				out := BufferOut{Time: time.Now(), Text: err.Error(), Type: "Go Run"}
+				p.stamp("error", out, msg, "")
+			}
+		}()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	p.cmd(ctx, "after", false, paths...)
+	if ctx.Err() != nil {
+		return
+	}
+	// only if nothing failed
+	if reloadFailures(ctx) == 0 {
+		p.cmd(ctx, "success", false, paths...)
+	}
+}
+
+// failures is the key of the context of a reload, its value counts the failures of the reload only, not the ones of the triggers and the schedules
+type failures struct{}
+
+// ReloadFailures returns the number of failures of the reload of a context
+func reloadFailures(ctx context.Context) int32 {
+	if n, ok := ctx.Value(failures{}).(*int32); ok {
+		return atomic.LoadInt32(n)
+	}
+	return 0
+}
+
+// lifetime is the key of the context of the commands left running after a reload with a timeout, as the run and the daemons
+type lifetime struct{}
+
+// Alive returns the context of the commands left running after the reload, stopped only by the next one
+func alive(ctx context.Context) context.Context {
+	if v, ok := ctx.Value(lifetime{}).(context.Context); ok {
+		return v
+	}
+	return ctx
+}
+
+// StopOnError checks if a project stops at the first failure
+func (p *Project) stopOnError() bool {
+	return p.StopOnError == nil || *p.StopOnError
+}
+
+// Broken checks if a reload has to stop after a failure, the next commands are skipped
+func (p *Project) broken(ctx context.Context) bool {
+	var (
+		msg string
+		out BufferOut
+	)
+	if !p.stopOnError() || reloadFailures(ctx) == 0 {
+		return false
+	}
+	msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Bold("Reload"), Red.Regular("stopped after a failure"))
+	out = BufferOut{Time: time.Now(), Text: "reload stopped after a failure"}
+	p.stamp("error", out, msg, "")
+	return true
+}
+
+// Pause stops the reloads of a project, the changes are collected until resume
+func (p *Project) Pause() {
+	atomic.StoreInt32(&p.paused, 1)
+}
+
+// Resume restarts the reloads of a project, with a single reload for the changes collected during the pause
+func (p *Project) Resume() {
+	if atomic.CompareAndSwapInt32(&p.paused, 1, 0) {
+		select {
+		case p.resume <- true:
+		default:
+		}
+	}
+}
+
+// Trigger forces a reload of a project, without a file change
+func (p *Project) Trigger() {
+	select {
+	case p.trigger <- true:
+	default:
+	}
+}
+
+// Current returns the context of the running reload, not cancelled if the project isn't running
+func (p *Project) current() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// Paused checks if a project is paused
+func (p *Project) Paused() bool {
+	return atomic.LoadInt32(&p.paused) == 1
+}
+
+// Watch a project until the exit signal
+func (p *Project) Watch(wg *sync.WaitGroup) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-p.exit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	defer wg.Done()
+	defer cancel()
+	p.Run(ctx)
+}
+
+// Run watches a project until the context is done, each reload runs with a child context cancelled by the next one
+func (p *Project) Run(ctx context.Context) {
+	var (
+		msg string
+		out BufferOut
+	)
+	var err error
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	// init a new watcher
+	p.watcher, err = p.newWatcher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.watcher.Close()
+	// recover a panic of the project or of a reload, the after commands stop what the before ones started
+	cancels := make(map[int]context.CancelFunc)
+	defer func() {
+		if r := recover(); r != nil {
+			p.Err(fmt.Errorf("%v", r))
+			for _, cancel := range cancels {
+				cancel()
+			}
+			p.cancel()
+			p.After()
+		}
+	}()
+	// before start checks
+	p.Before()
+	// running reloads, the changes are queued until their end with the queue option
+	reloaded := make(chan bool)
+	panics := make(chan interface{})
+	var running int
+	launch := func(f func()) {
+		running++
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					select {
+					case panics <- r:
+					case <-ctx.Done():
+					}
+				}
+			}()
+			f()
+			select {
+			case reloaded <- true:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	// start watcher, wait the first change if skip first run
+	if p.Watcher.SkipFirst {
+		p.init = true
+	} else {
+		ctx := p.ctx
+		launch(func() { p.Reload(ctx, "") })
+	}
+	if p.resume == nil {
+		p.resume = make(chan bool, 1)
+	}
+	if p.trigger == nil {
+		p.trigger = make(chan bool, 1)
+	}
+	if p.Watcher.Strategy == "affected" {
+		p.scoped = &scope{cancels: make(map[string]context.CancelFunc)}
+	}
+	// scheduled commands, until the exit
+	p.scheduled(ctx)
+	// pending reloads by trigger, -1 is the whole project
+	batches := make(map[int]*batch)
+	limit := limiter{RateLimit: p.Watcher.RateLimit}
+	var reload <-chan time.Time
+	schedule := func(event fsnotify.Event, file string) {
+		i := p.Watcher.trigger(event.Name, p.rel(event.Name))
+		b, ok := batches[i]
+		if !ok {
+			b = &batch{}
+			batches[i] = b
+		}
+		if !ok || p.Watcher.Batch <= 0 {
+			b.deadline = time.Now().Add(p.Watcher.delay(i))
+		}
+		b.file = file
+		b.event = event
+		if file != "" {
+			b.add(file)
+		}
+		reload = time.After(next(batches))
+	}
+L:
+	for {
+		select {
+		case event := <-p.watcher.Events():
+			if p.recovery().Events {
+				leveled("debug", "File:", event.Name, "LastFile:", p.last.file, "Time:", time.Now(), "LastTime:", p.last.time)
+			}
+			// chmod as a change
+			op := event.Op
+			if op == fsnotify.Chmod && p.Watcher.Chmod {
+				op = fsnotify.Write
+			}
+			// switch event type
+			switch op {
+			case fsnotify.Chmod:
+			case fsnotify.Remove, fsnotify.Rename:
+				// prune the stale paths, a renamed dir is indexed by the create event of its new path
+				files := p.prune(event.Name)
+				if p.Validate(event.Name, false) && (ext(event.Name) != "" || p.singles[event.Name] || files > 0) {
+					schedule(event, "")
+				}
+			default:
+				if p.Validate(event.Name, true) {
+					fi, err := os.Stat(event.Name)
+					if err != nil {
+						continue
+					}
+					if fi.IsDir() {
+						filepath.Walk(event.Name, p.walk)
+					} else if p.modified(event) {
+						schedule(event, event.Name)
+					}
+				}
+			}
+		case <-p.resume:
+			// reload once with the changes collected during the pause
+			for _, b := range batches {
+				b.deadline = time.Now()
+			}
+			if len(batches) > 0 {
+				reload = time.After(0)
+			}
+		case <-p.trigger:
+			// manual reload of the whole project
+			msg = fmt.Sprintln(p.pname(p.Name, 4), ":", Magenta.Bold("Reload"), "forced")
+			out = BufferOut{Time: time.Now(), Text: "reload forced"}
+			p.stamp("log", out, msg, "")
+			p.last.time = time.Now()
+			p.cancel()
+			p.ctx, p.cancel = context.WithCancel(ctx)
+			ctx := p.ctx
+			launch(func() { p.Reload(ctx, "") })
+		case r := <-panics:
+			// cleanup by the recover of the project
+			panic(r)
+		case <-reloaded:
+			running--
+			if running == 0 && len(batches) > 0 {
+				// the changes queued during the reload
+				reload = time.After(0)
+			}
+		case <-reload:
+			reload = nil
+			if p.Paused() {
+				continue
+			}
+			for i, b := range batches {
+				if time.Now().Before(b.deadline) {
+					continue
+				}
+				if p.Watcher.Queue && running > 0 && (i < 0 || len(p.Watcher.Triggers[i].Scripts) == 0 || p.Watcher.Triggers[i].Reload) {
+					// wait the end of the running reload
+					continue
+				}
+				if i < 0 || len(p.Watcher.Triggers[i].Scripts) == 0 || p.Watcher.Triggers[i].Reload {
+					if wait := limit.take(time.Now()); wait > 0 {
+						// over the rate limit, the next changes are collected until then
+						b.deadline = time.Now().Add(wait)
+						msg = fmt.Sprintln(p.pname(p.Name, 4), ":", Magenta.Bold("Reload"), "delayed of", wait.Round(time.Millisecond), "by the rate limit")
+						out = BufferOut{Time: time.Now(), Text: "reload delayed by the rate limit"}
+						p.stamp("log", out, msg, "")
+						continue
+					}
+				}
+				delete(batches, i)
+				b := b
+				p.last.file = b.file
+				p.last.time = time.Now()
+				p.Change(b.event)
+				if i >= 0 && len(p.Watcher.Triggers[i].Scripts) > 0 && !p.Watcher.Triggers[i].Reload {
+					// trigger commands, in place of the whole reload
+					if cancels[i] != nil {
+						cancels[i]()
+					}
+					var trigger context.Context
+					trigger, cancels[i] = context.WithCancel(ctx)
+					go p.commands(trigger, p.Watcher.Triggers[i].Scripts, "trigger", b.files...)
+					continue
+				}
+				if i < 0 && p.Watcher.Strategy == "affected" {
+					// only the commands of the changes, the others are left running
+					if cmds := p.affected(b.files); len(cmds) > 0 {
+						go p.commands(p.ctx, cmds, "restart", b.files...)
+						continue
+					}
+				}
+				// stop and restart
+				p.cancel()
+				p.ctx, p.cancel = context.WithCancel(ctx)
+				if i >= 0 && len(p.Watcher.Triggers[i].Scripts) > 0 {
+					// trigger commands before the whole reload
+					ctx, cmds := p.ctx, p.Watcher.Triggers[i].Scripts
+					launch(func() {
+						p.commands(ctx, cmds, "trigger", b.files...)
+						select {
+						case <-ctx.Done():
+						default:
+							p.Reload(ctx, b.file, b.files...)
+						}
+					})
+					continue
+				}
+				ctx := p.ctx
+				launch(func() { p.Reload(ctx, b.file, b.files...) })
+			}
+			if len(batches) > 0 && (!p.Watcher.Queue || running == 0) {
+				reload = time.After(next(batches))
+			}
+		case err := <-p.watcher.Errors():
+			p.Err(err)
+		case <-ctx.Done():
+			// stop the running commands, they don't get the interrupt in their own process group
+			for _, cancel := range cancels {
+				cancel()
+			}
+			p.cancel()
+			p.After()
+			break L
+		}
+	}
+}
+
+// Ignores returns the ignored paths of all the projects, then the ones of the project
+func (p *Project) ignores() []string {
+	if p.parent == nil || len(p.parent.Schema.Ignore) == 0 {
+		return p.Watcher.Ignore
+	}
+	return append(p.parent.Schema.Ignore[:len(p.parent.Schema.Ignore):len(p.parent.Schema.Ignore)], p.Watcher.Ignore...)
+}
+
+// Validate a file path
+func (p *Project) Validate(path string, fcheck bool) bool {
+	if len(path) <= 0 {
+		return false
+	}
+	// files explicitly watched
+	if p.singles[path] {
+		return true
+	}
+	rel := p.rel(path)
+	// check if skip hidden
+	if p.Watcher.Hidden && isHidden(path) && !p.Watcher.visible(rel) {
+		return false
+	}
+	// check .gitignore rules
+	if p.gitignore != nil {
+		fi, err := os.Stat(path)
+		if p.gitignore.Ignored(path, err == nil && fi.IsDir()) {
+			return false
+		}
+	}
+	// check ignored regex
+	for _, r := range p.Watcher.ignRegex {
+		if r.MatchString(rel) {
+			return false
+		}
+	}
+	// ignored paths, applied in order, the "!" prefixed ones include again the paths ignored so far
+	ignored := false
+	for _, v := range p.ignores() {
+		if n := strings.TrimPrefix(v, "!"); n != v {
+			if ignored && within(n, rel) {
+				ignored = false
+			}
+		} else if within(v, rel) {
+			ignored = true
+		}
+	}
+	if ignored {
+		return false
+	}
+	// files explicitly triggered, out of the ignored ones
+	for _, t := range p.Watcher.Triggers {
+		if t.match(rel) && !t.ignored(rel) {
+			return true
+		}
+	}
+	// supported regex
+	if len(p.Watcher.regex) > 0 && ext(path) != "" {
+		matched := false
+		for _, r := range p.Watcher.regex {
+			if r.MatchString(rel) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	// check for a valid ext or path
+	if e := ext(path); e != "" {
+		if len(p.Watcher.Exts) == 0 {
+			return false
+		}
+		// check ignored
+		for _, v := range p.ignores() {
+			if p.Watcher.sameExt(v, e) {
+				return false
+			}
+		}
+		// supported extensions
+		for index, v := range p.Watcher.Exts {
+			if p.Watcher.sameExt(v, e) {
+				break
+			}
+			if index == len(p.Watcher.Exts)-1 {
+				return false
+			}
+		}
+	}
+	// supported glob paths, dirs are checked only against the negated ones
+	if !p.Watcher.match(rel, ext(path) == "") {
+		return false
+	}
+	// file check
+	if fcheck {
+		fi, err := os.Stat(path)
+		if err != nil || fi.Mode()&os.ModeSymlink != 0 || !fi.IsDir() && ext(path) == "" || fi.Size() <= 0 {
+			return false
+		}
+		// check max size
+		if p.Watcher.MaxSize > 0 && !fi.IsDir() && fi.Size() > p.Watcher.MaxSize {
+			return false
+		}
+	}
+	return true
+
+}
+
+// Add a file to the changes of a batch
+func (b *batch) add(path string) {
+	for _, v := range b.files {
+		if v == path {
+			return
+		}
+	}
+	b.files = append(b.files, path)
+}
+
+// Take a reload, returns the time left to the next one allowed by the rate limit
+func (l *limiter) take(now time.Time) time.Duration {
+	if l.Every <= 0 {
+		return 0
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else if l.tokens += float64(now.Sub(l.last)) / float64(l.Every); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) * float64(l.Every))
+}
+
+// Next returns the time left to the first pending reload
+func next(batches map[int]*batch) time.Duration {
+	var first time.Time
+	for _, b := range batches {
+		if first.IsZero() || b.deadline.Before(first) {
+			first = b.deadline
+		}
+	}
+	return time.Until(first)
+}
+
+// Trigger returns the index of the trigger of a path, -1 if none
+func (w *Watch) trigger(path, rel string) int {
+	e := ext(path)
+	for i, t := range w.Triggers {
+		if t.ignored(rel) {
+			continue
+		}
+		if t.match(rel) {
+			return i
+		}
+		for _, v := range t.Exts {
+			if w.sameExt(v, e) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Match checks if a relative path matches the trigger paths, patterns without dirs match the file name
+func (t *Trigger) match(rel string) bool {
+	for _, v := range t.Paths {
+		v = cleanGlob(v)
+		if glob(v, rel) || !strings.Contains(v, "/") && glob(v, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored checks if a relative path or one of its parent dirs is ignored by the trigger
+func (t *Trigger) ignored(rel string) bool {
+	for _, v := range t.Ignore {
+		if within(strings.TrimPrefix(v, "/"), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the time to wait before a reload of a trigger
+func (w *Watch) delay(trigger int) time.Duration {
+	if w.Batch > 0 {
+		return w.Batch
+	}
+	if trigger >= 0 && w.Triggers[trigger].Debounce > 0 {
+		return w.Triggers[trigger].Debounce
+	}
+	return w.debounce()
+}
+
+// Debounce returns the time to wait for other events before a reload
+func (w *Watch) debounce() time.Duration {
+	if w.Debounce > 0 {
+		return w.Debounce
+	}
+	return Debounce
+}
+
+// Compile the watched and ignored regex patterns
+func (w *Watch) compile() error {
+	w.regex, w.ignRegex = nil, nil
+	for _, v := range w.Regex {
+		r, err := regexp.Compile(v)
+		if err != nil {
+			return err
+		}
+		w.regex = append(w.regex, r)
+	}
+	for _, v := range w.IgnRegex {
+		r, err := regexp.Compile(v)
+		if err != nil {
+			return err
+		}
+		w.ignRegex = append(w.ignRegex, r)
+	}
+	return nil
+}
+
+// Match checks if a relative path matches the watched paths, plain paths match everything below them
+// patterns are applied in order and the "!" prefixed ones exclude the paths matched so far, dirs are checked only against them
+func (w *Watch) match(rel string, dir bool) bool {
+	matched := true
+	for _, v := range w.Paths {
+		if !strings.HasPrefix(v, "!") && !dir {
+			matched = false
+			break
+		}
+	}
+	for _, v := range w.Paths {
+		if n := strings.TrimPrefix(v, "!"); n != v {
+			if matched && within(n, rel) {
+				matched = false
+			}
+		} else if !dir && (!hasMeta(v) || glob(cleanGlob(v), rel)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// SameExt compares two extensions, case insensitive by default on windows and macOS
+func (w *Watch) sameExt(a, b string) bool {
+	nocase := runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+	if w.NoCase != nil {
+		nocase = *w.NoCase
+	}
+	if nocase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// Visible checks if a hidden path is excluded from the hidden rule, parents of an exception are visible too
+func (w *Watch) visible(rel string) bool {
+	for _, v := range w.Visible {
+		v = cleanGlob(v)
+		if hasMeta(v) {
+			if globTree(v, rel) {
+				return true
+			}
+		} else if rel == v || strings.HasPrefix(rel, v+"/") || strings.HasPrefix(v, rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Within checks if a relative path or one of its parent dirs matches a pattern
+func within(pattern, rel string) bool {
+	pattern = cleanGlob(pattern)
+	if hasMeta(pattern) {
+		return globTree(pattern, rel)
+	}
+	return pattern == "." || rel == pattern || strings.HasPrefix(rel, pattern+"/")
+}
+
+// Expand the template variables of a command, {{.File}}, {{.Dir}} and {{.Ext}} of the changed path
+func (p *Project) expand(cmd string, path string) (string, error) {
+	if !strings.Contains(cmd, "{{") {
+		return cmd, nil
+	}
+	t, err := template.New("command").Parse(cmd)
+	if err != nil {
+		return cmd, err
+	}
+	vars := struct{ File, Dir, Ext string }{Dir: "."}
+	if path != "" {
+		rel := p.rel(path)
+		vars.File, vars.Dir, vars.Ext = local(rel), local(filepath.ToSlash(filepath.Dir(rel))), ext(path)
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			vars.Dir = local(rel)
+		}
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return cmd, err
+	}
+	return b.String(), nil
+}
+
+// Fill returns a copy of a command with the template variables expanded, pipe stages included
+func (p *Project) fill(cmd Command, path string) (Command, error) {
+	var err error
+	cmd.project, cmd.prefix, cmd.base, cmd.keys = p.Name, p.prefix(), p.environ(), p.keys
+	if cmd.Cmd, err = p.expand(cmd.Cmd, path); err != nil {
+		return cmd, err
+	}
+	list := func(cmds []Command) ([]Command, error) {
+		filled := make([]Command, len(cmds))
+		for i, s := range cmds {
+			if filled[i], err = p.fill(s, path); err != nil {
+				return nil, err
+			}
+		}
+		return filled, nil
+	}
+	if cmd.Pipe, err = list(cmd.Pipe); err != nil {
+		return cmd, err
+	}
+	if cmd.Before, err = list(cmd.Before); err != nil {
+		return cmd, err
+	}
+	cmd.After, err = list(cmd.After)
+	return cmd, err
+}
+
+// Prefix returns the name of the project before the lines of its outputs, in its color and padded as the longest one, empty if it's the only project
+func (p *Project) prefix() string {
+	if p.parent == nil || len(p.parent.Schema.Projects) < 2 {
+		return ""
+	}
+	width, index := 0, -1
+	for i, v := range p.parent.Schema.Projects {
+		if len(v.Name) > width {
+			width = len(v.Name)
+		}
+		if v.Name == p.Name && index < 0 {
+			index = i
+		}
+	}
+	if p.options().uncolored() {
+		return fmt.Sprintf("%-*s | ", width, p.Name)
+	}
+	return tint(index, p.Name).Regular(fmt.Sprintf("%-*s |", width, p.Name)) + " "
+}
+
+// Local prefixes a relative path with "./", as required by the go tools for the packages
+func local(rel string) string {
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "./") || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+		return rel
+	}
+	return "./" + rel
+}
+
+// Depth returns the number of dirs between the project path and a path
+func (p *Project) depth(path string) int {
+	rel := p.rel(path)
+	if rel == "." {
+		return 0
+	}
+	return len(strings.Split(rel, "/"))
+}
+
+// Rel returns a slash separated path relative to the project path
+func (p *Project) rel(path string) string {
+	base, _ := filepath.Abs(p.Path)
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// Changed checks if the content of a file is changed since the last check, always true if the hash check is disabled
+// Modified reports if the event of a file is a change, a chmod one as a change is reloaded without comparing the hash of an unchanged content
+func (p *Project) modified(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return p.Watcher.Chmod
+	}
+	return p.changed(event.Name)
+}
+
+// whitespace and comments are skipped for the extensions in the ignored format list
+func (p *Project) changed(path string) bool {
+	format := false
+	for _, v := range p.Watcher.IgnFormat {
+		if p.Watcher.sameExt(v, ext(path)) {
+			format = true
+			break
+		}
+	}
+	if !p.Watcher.Hash && !format {
+		return true
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	if format {
+		content = normalize(ext(path), content)
+	}
+	h := sha1.Sum(content)
+	sum := hex.EncodeToString(h[:])
+	if p.hashes == nil {
+		p.hashes = make(map[string]string)
+	}
+	if p.hashes[path] == sum {
+		return false
+	}
+	p.hashes[path] = sum
+	return true
+}
+
+// NewWatcher returns the ssh watcher of a remote project or the registered watcher set in the settings, the default one otherwise
+func (p *Project) newWatcher() (FileWatcher, error) {
+	if p.Remote.Host != "" {
+		local, _ := filepath.Abs(p.Path)
+		return SSHWatcher(p.Remote, local)
+	}
+	if name := p.parent.Settings.Watcher; name != "" && !p.Polling.Enabled {
+		return NewWatcher(name)
+	}
+	return NewFileWatcher(p.legacy())
+}
+
+// Legacy returns the legacy options of the project overridden by its polling settings
+func (p *Project) legacy() Legacy {
+	l := p.options().Legacy
+	if p.Polling.Enabled {
+		l.Force = true
+	}
+	if p.Polling.Interval != 0 {
+		l.Interval = p.Polling.Interval
+	}
+	return l
 }
 
 // Defines the colors scheme for the project name
 func (p *Project) pname(name string, color int) string {
+	if logFormat == "json" {
+		// the name as set, the activity of the records
+		return "[" + name + "]"
+	}
 	switch color {
 	case 1:
 		name = Yellow.Regular("[") + strings.ToUpper(name) + Yellow.Regular("]")
@@ -408,8 +2013,9 @@ func (p *Project) pname(name string, color int) string {
 	return name
 }
 
-//  Tool logs the result of a go command
-func (p *Project) tools(stop <-chan bool, path string, fi os.FileInfo) {
+// Tool logs the result of a go command
+func (p *Project) tools(ctx context.Context, path string, fi os.FileInfo) {
+	var msg string
 	done := make(chan bool)
 	result := make(chan Response)
 	v := reflect.ValueOf(p.Tools)
@@ -418,12 +2024,11 @@ func (p *Project) tools(stop <-chan bool, path string, fi os.FileInfo) {
 			tool := v.Field(i).Interface().(Tool)
 			tool.parent = p
 			if tool.Status && tool.isTool {
-				if fi.IsDir() {
-					if tool.dir {
-						result <- tool.Exec(path, stop)
-					}
-				} else if !tool.dir {
-					result <- tool.Exec(path, stop)
+				if fi.IsDir() && tool.dir || !fi.IsDir() && !tool.dir {
+					start := time.Now()
+					r := tool.Exec(ctx, path)
+					r.Duration = time.Since(start)
+					result <- r
 				}
 			}
 		}
@@ -433,9 +2038,12 @@ func (p *Project) tools(stop <-chan bool, path string, fi os.FileInfo) {
 		select {
 		case <-done:
 			return
-		case <-stop:
+		case <-ctx.Done():
 			return
 		case r := <-result:
+			if r.Name != "" {
+				p.result(r)
+			}
 			if r.Err != nil {
 				if fi.IsDir() {
 					path, _ = filepath.Abs(fi.Name())
@@ -443,39 +2051,341 @@ func (p *Project) tools(stop <-chan bool, path string, fi os.FileInfo) {
 				msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Bold(r.Name), Red.Regular("there are some errors in"), ":", Magenta.Bold(path))
 				buff := BufferOut{Time: time.Now(), Text: "there are some errors in", Path: path, Type: r.Name, Stream: r.Err.Error()}
 				p.stamp("error", buff, msg, r.Err.Error())
+				p.failed(ctx, r)
 			} else if r.Out != "" {
 				msg = fmt.Sprintln(p.pname(p.Name, 3), ":", Red.Bold(r.Name), Red.Regular("outputs"), ":", Blue.Bold(path))
 				buff := BufferOut{Time: time.Now(), Text: "outputs", Path: path, Type: r.Name, Stream: r.Out}
-				p.stamp("out", buff, msg, r.Out)
+				p.stamp("warn", buff, msg, r.Out)
+			}
+		}
+	}
+}
+
+// Graph checks if a list of commands has dependencies, run as a graph in place of a sequence
+func graph(cmds []Command) bool {
+	for _, c := range cmds {
+		if len(c.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Graph runs the commands as soon as their needs are completed, the commands without needs run in parallel
+// up to the concurrency of the project, the ready ones with the highest priority first
+// the dependents of a failed command are skipped, the running ones are stopped too with fail fast
+// nested sequences and parallels are composed by the needs, once stopped no command is started and the graph
+// returns only after the running ones
+func (p *Project) graph(ctx context.Context, cmds []Command, run func(context.Context, Command) (Response, bool), result chan<- Response) {
+	index := make(map[string]int)
+	for i, c := range cmds {
+		if c.Name != "" {
+			index[c.Name] = i
+		}
+	}
+	// check the needs and the cycles
+	state := make([]int, len(cmds))
+	var visit func(i int) error
+	visit = func(i int) error {
+		if state[i] == 1 {
+			return errors.New("cycle in the needs of " + cmds[i].Name)
+		}
+		if state[i] == 2 {
+			return nil
+		}
+		state[i] = 1
+		for _, n := range cmds[i].Needs {
+			j, ok := index[n]
+			if !ok {
+				return errors.New("command " + n + " needed by " + cmds[i].Name + " not found")
+			}
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = 2
+		return nil
+	}
+	for i := range cmds {
+		if err := visit(i); err != nil {
+			select {
+			case result <- Response{Name: cmds[i].Name, Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+	}
+	// cancelled at the first failure with fail fast
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 0 waiting, 1 running, 2 finished
+	status := make([]int, len(cmds))
+	failed := make([]bool, len(cmds))
+	// ready returns the waiting commands with their needs finished, the dependents of a failed one are skipped
+	ready := func() (ready []int) {
+		for skipped := true; skipped; {
+			skipped, ready = false, nil
+			for i, c := range cmds {
+				if status[i] != 0 {
+					continue
+				}
+				wait, skip := false, ctx.Err() != nil
+				for _, n := range c.Needs {
+					wait = wait || status[index[n]] != 2
+					skip = skip || failed[index[n]]
+				}
+				if skip {
+					status[i], failed[i], skipped = 2, true, true
+				} else if !wait {
+					ready = append(ready, i)
+				}
+			}
+		}
+		return ready
+	}
+	type end struct {
+		i      int
+		failed bool
+	}
+	ends := make(chan end)
+	var running int
+	for {
+		// the highest priority first, within the concurrency
+		next := ready()
+		sort.SliceStable(next, func(a, b int) bool { return cmds[next[a]].Priority > cmds[next[b]].Priority })
+		for _, i := range next {
+			if p.Concurrency > 0 && running >= p.Concurrency {
+				break
 			}
+			status[i] = 1
+			running++
+			go func(i int, c Command) {
+				r, ok := run(ctx, c)
+				if ok {
+					select {
+					case result <- r:
+					case <-ctx.Done():
+					}
+				}
+				ends <- end{i, ok && r.Err != nil && !c.IgnoreErrors}
+			}(i, cmds[i])
+		}
+		if running == 0 {
+			return
+		}
+		e := <-ends
+		running--
+		status[e.i], failed[e.i] = 2, e.failed
+		if e.failed && p.FailFast {
+			cancel()
 		}
 	}
 }
 
+// Confirm prints the question of a command and waits for the keyboard answer
+func (p *Project) confirm(ctx context.Context, c Confirm) bool {
+	answer := "[y/N]"
+	if c.Default {
+		answer = "[Y/n]"
+	}
+	if c.Timeout > 0 {
+		answer += " " + c.Timeout.String()
+	}
+	log.Print(fmt.Sprintln(p.pname(p.Name, 5), ":", Yellow.Bold("Confirm"), c.Message, answer))
+	return confirm.ask(ctx, c.Timeout, c.Default)
+}
+
 // Cmd after/before
-func (p *Project) cmd(stop <-chan bool, flag string, global bool) {
+func (p *Project) cmd(ctx context.Context, flag string, global bool, paths ...string) {
+	var cmds []Command
+	for i, cmd := range p.Watcher.Scripts {
+		if strings.ToLower(cmd.Type) == flag && cmd.Global == global && cmd.Schedule == "" {
+			if cmd.Once && !atomic.CompareAndSwapInt32(&p.Watcher.Scripts[i].ran, 0, 1) {
+				// already run by a previous reload
+				continue
+			}
+			cmds = append(cmds, cmd)
+		}
+	}
+	p.commands(ctx, cmds, flag, paths...)
+}
+
+// Failed runs the error commands, the name and the output of the failed command are passed as env variables
+// the failure is counted by the reload unless the errors of the command are ignored
+func (p *Project) failed(ctx context.Context, r Response) {
+	if n, ok := ctx.Value(failures{}).(*int32); ok && !r.ignored {
+		atomic.AddInt32(n, 1)
+	}
+	var cmds []Command
+	for _, cmd := range p.Watcher.Scripts {
+		if strings.ToLower(cmd.Type) == "error" {
+			cmd.vars = append(cmd.vars, "REALIZE_FAILED_COMMAND="+r.Name, "REALIZE_FAILED_OUTPUT="+truncated(r.Err.Error()))
+			cmds = append(cmds, cmd)
+		}
+	}
+	if len(cmds) > 0 {
+		p.commands(ctx, cmds, "error")
+	}
+}
+
+// Affected returns the commands with a condition matching the changed files, run again alone by the affected strategy
+// nil if a file isn't matched by any of them, the whole project is reloaded
+func (p *Project) affected(files []string) (cmds []Command) {
+	matched := make(map[string]bool)
+	for _, cmd := range p.Watcher.Scripts {
+		if t := strings.ToLower(cmd.Type); t != "before" && t != "after" || cmd.Global || cmd.Once || cmd.Schedule != "" || !cmd.conditional() {
+			continue
+		}
+		var found bool
+		for _, v := range files {
+			if ok, err := cmd.when([]string{p.rel(v)}); err == nil && ok {
+				matched[v] = true
+				found = true
+			}
+		}
+		if found {
+			cmds = append(cmds, cmd)
+		}
+	}
+	if len(files) == 0 || len(matched) < len(files) {
+		return nil
+	}
+	return cmds
+}
+
+// Scheduled runs the commands with a schedule until the context is done, a run still in progress delays the next one
+func (p *Project) scheduled(ctx context.Context) {
+	for _, cmd := range p.Watcher.Scripts {
+		if cmd.Schedule == "" {
+			continue
+		}
+		c, err := parseCron(cmd.Schedule)
+		if err != nil {
+			p.Err(err)
+			continue
+		}
+		go func(cmd Command, c cron) {
+			for {
+				next := c.next(time.Now())
+				if next.IsZero() {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Until(next)):
+					p.commands(ctx, []Command{cmd}, "schedule")
+				}
+			}
+		}(cmd, c)
+	}
+}
+
+// Commands runs a sequence of commands and prints their results, the templates are filled with the first changed path
+// a stop kills the running command and the next ones aren't started, it returns once the running ones have ended
+// the commands with a when condition are skipped unless a changed path matches it
+func (p *Project) commands(ctx context.Context, cmds []Command, flag string, paths ...string) {
+	var (
+		msg string
+		out BufferOut
+	)
 	done := make(chan bool)
 	result := make(chan Response)
-	// commands sequence
+	var path string
+	var rels []string
+	for _, v := range paths {
+		rels = append(rels, p.rel(v))
+	}
+	if len(paths) > 0 {
+		path = paths[0]
+	}
+	// run a command, false if skipped by its condition
+	run := func(ctx context.Context, cmd Command) (Response, bool) {
+		var r Response
+		ok, err := cmd.when(rels)
+		if err == nil && !ok {
+			return r, false
+		}
+		if p.scoped != nil && cmd.conditional() {
+			// stop the previous instance of the command
+			ctx = p.scoped.restart(ctx, cmd.Type+":"+cmd.Name+":"+cmd.Cmd)
+		}
+		if err != nil {
+			r = Response{Name: cmd.Cmd, Err: err}
+		} else if c, err := p.fill(cmd, path); err != nil {
+			r = Response{Name: cmd.Cmd, Err: err}
+		} else if c.Confirm.Message != "" && !p.confirm(ctx, c.Confirm) {
+			r = Response{Name: c.Confirm.Message, Err: errors.New("not confirmed")}
+		} else if c.Cmd == "" && len(c.Pipe) == 0 {
+			// confirmation only
+			r = Response{Name: c.Confirm.Message}
+		} else if !lock(ctx, c.Lock) {
+			r = Response{Name: cmd.Cmd, Err: ctx.Err()}
+		} else {
+			r = c.exec(ctx, p.Path)
+			unlock(c.Lock)
+		}
+		if cmd.Log != "" && !cmd.Output {
+			// output only in the log file
+			r.Out = ""
+		}
+		r.ignored = cmd.IgnoreErrors
+		return r, true
+	}
 	go func() {
-		for _, cmd := range p.Watcher.Scripts {
-			if strings.ToLower(cmd.Type) == flag && cmd.Global == global {
-				result <- cmd.exec(p.Path, stop)
+		defer close(done)
+		if graph(cmds) {
+			p.graph(ctx, cmds, run, result)
+			return
+		}
+		// commands sequence, the output and the exit code of a command are passed to the next one
+		var prev []string
+		for _, cmd := range cmds {
+			if ctx.Err() != nil {
+				// stopped, the next commands aren't started
+				break
+			}
+			cmd.vars = append(cmd.vars[:len(cmd.vars):len(cmd.vars)], prev...)
+			r, ok := run(ctx, cmd)
+			if !ok {
+				continue
+			}
+			code := r.ExitCode
+			if r.Err != nil && code == 0 {
+				code = 1
+			}
+			prev = []string{"REALIZE_PREV_OUT=" + truncated(strings.TrimRight(r.Out, "\n")), "REALIZE_PREV_CODE=" + strconv.Itoa(code)}
+			result <- r
+			// stop at the first failure
+			if r.Err != nil && !cmd.IgnoreErrors && p.stopOnError() {
+				break
 			}
 		}
-		close(done)
 	}()
+	// the sequence or the graph ends after its running commands, even once stopped, nested ones included
 	for {
 		select {
-		case <-stop:
-			return
 		case <-done:
 			return
 		case r := <-result:
+			if ctx.Err() != nil {
+				// the results of the commands killed by the stop
+				continue
+			}
+			p.result(r)
+			if r.Timeout && p.recovery().Tools {
+				leveled("debug", "Timeout:", r.Name)
+			}
 			msg = fmt.Sprintln(p.pname(p.Name, 5), ":", Green.Bold("Command"), Green.Bold("\"")+r.Name+Green.Bold("\""))
 			if r.Err != nil {
 				out = BufferOut{Time: time.Now(), Text: r.Err.Error(), Type: flag}
 				p.stamp("error", out, msg, fmt.Sprint(Red.Regular(r.Err.Error())))
+				if flag != "error" {
+					p.failed(ctx, r)
+				}
+			} else if len(r.Errors) > 0 {
+				out = BufferOut{Time: time.Now(), Text: r.Out, Type: flag, Errors: r.Errors}
+				p.stamp("error", out, msg, fmt.Sprint(Red.Regular(strings.Join(r.Errors, "\n"))))
 			} else {
 				out = BufferOut{Time: time.Now(), Text: r.Out, Type: flag}
 				p.stamp("log", out, msg, fmt.Sprint(r.Out))
@@ -486,31 +2396,124 @@ func (p *Project) cmd(stop <-chan bool, flag string, global bool) {
 
 // Watch the files tree of a project
 func (p *Project) walk(path string, info os.FileInfo, err error) error {
+	if p.Watcher.Symlinks && info != nil && info.Mode()&os.ModeSymlink != 0 {
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			return p.follow(path)
+		}
+	}
+	if p.Watcher.MaxDepth > 0 && info != nil && info.IsDir() && p.depth(path) > p.Watcher.MaxDepth {
+		return filepath.SkipDir
+	}
+	if p.gitignore != nil && info != nil && info.IsDir() {
+		if p.gitignore.Ignored(path, true) {
+			return filepath.SkipDir
+		}
+		if err := p.gitignore.Load(path); err != nil {
+			p.Err(err)
+		}
+	}
 	if p.Validate(path, true) {
 		result := p.watcher.Walk(path, p.init)
 		if result != "" {
-			if p.parent.Settings.Recovery.Index {
-				log.Println("Indexing", path)
+			if p.recovery().Index {
+				leveled("debug", "Indexing", path)
 			}
-			p.tools(p.stop, path, info)
+			p.index(result, info.IsDir())
+			p.tools(p.current(), path, info)
 			if info.IsDir() {
 				// tools dir
 				p.folders++
 			} else {
 				// tools files
 				p.files++
+				p.changed(path)
+			}
+		}
+	}
+	return nil
+}
+
+// Index saves a watched path
+func (p *Project) index(path string, dir bool) {
+	if p.paths == nil {
+		p.paths = make(map[string]bool)
+	}
+	p.paths[path] = dir
+}
+
+// Prune removes a path and all the watched paths below it, returns the number of files removed
+func (p *Project) prune(path string) (files int) {
+	if _, ok := p.paths[path]; !ok {
+		p.watcher.Remove(path)
+		delete(p.hashes, path)
+	}
+	for v, dir := range p.paths {
+		if v == path || strings.HasPrefix(v, path+string(os.PathSeparator)) {
+			p.watcher.Remove(v)
+			delete(p.paths, v)
+			delete(p.hashes, v)
+			if !dir {
+				files++
 			}
 		}
 	}
+	return files
+}
+
+// Single adds a file listed in the watched paths
+func (p *Project) single(path string) {
+	if p.singles == nil {
+		p.singles = make(map[string]bool)
+	}
+	p.singles[path] = true
+	if p.watcher.Walk(path, p.init) != "" {
+		p.index(path, false)
+		if p.recovery().Index {
+			leveled("debug", "Indexing", path)
+		}
+		p.files++
+		p.changed(path)
+	}
+}
+
+// Follow a symlinked dir, unless its target is already indexed
+func (p *Project) follow(path string) error {
+	if !p.visit(path) {
+		return nil
+	}
+	// walk the link itself, in order to keep the paths inside the project
+	if err := filepath.Walk(path+string(os.PathSeparator), p.walk); err != nil {
+		p.Err(err)
+	}
 	return nil
 }
 
+// Visit marks the real path of a dir as indexed, false if it was already indexed
+func (p *Project) visit(path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	if p.visited == nil {
+		p.visited = make(map[string]bool)
+	}
+	for v := range p.visited {
+		if real == v || strings.HasPrefix(real, v+string(os.PathSeparator)) {
+			return false
+		}
+	}
+	p.visited[real] = true
+	return true
+}
+
 // Print on files, cli, ws
+// the type is out, warn as the outputs of the tools, log or error, the level of the json records
 func (p *Project) stamp(t string, o BufferOut, msg string, stream string) {
 	ctime := time.Now()
 	content := []string{ctime.Format("2006-01-02 15:04:05"), strings.ToUpper(p.Name), ":", o.Text, "\r\n", stream}
+	opts := p.options()
 	switch t {
-	case "out":
+	case "out", "warn":
 		p.Buffer.StdOut = append(p.Buffer.StdOut, o)
 		if p.parent.Settings.Files.Outputs.Status {
 			f := p.parent.Settings.Create(p.Path, p.parent.Settings.Files.Outputs.Name)
@@ -535,27 +2538,63 @@ func (p *Project) stamp(t string, o BufferOut, msg string, stream string) {
 			}
 		}
 	}
-	if msg != "" {
-		log.Print(msg)
+	if n := opts.BufferSize; n > 0 {
+		p.Buffer.StdOut, p.Buffer.StdLog, p.Buffer.StdErr = tail(p.Buffer.StdOut, n), tail(p.Buffer.StdLog, n), tail(p.Buffer.StdErr, n)
 	}
-	if stream != "" {
-		fmt.Fprintln(Output, stream)
+	if opts.uncolored() {
+		msg, stream = ansi.ReplaceAllString(msg, ""), ansi.ReplaceAllString(stream, "")
 	}
-	go func() {
-		p.parent.Sync <- "sync"
-	}()
+	// the info messages aren't printed if quiet, the outputs and the errors are
+	if t == "log" && opts.Verbosity == "quiet" {
+		msg = ""
+	}
+	if logFormat == "json" {
+		level := map[string]string{"out": "info", "log": "info", "warn": "warn", "error": "error"}[t]
+		for _, v := range []string{msg, stream} {
+			if v == "" {
+				continue
+			}
+			rec := parse(v)
+			rec.Level, rec.Activity, rec.Command = level, p.Name, o.Type
+			if v == stream {
+				rec.Message = strings.TrimRight(ansi.ReplaceAllString(stream, ""), "\r\n")
+			}
+			rec.emit(Output)
+		}
+	} else {
+		if msg != "" {
+			log.Print(msg)
+		}
+		if stream != "" {
+			fmt.Fprintln(Output, stamped(stream, p.prefix(), time.Now()))
+		}
+	}
+	// the channel of the server, the project may be replaced by a reload before the send
+	if c := p.parent.Sync; c != nil {
+		go func() {
+			c <- "sync"
+		}()
+	}
+}
+
+// Tail returns the last entries of a buffer
+func tail(list []BufferOut, n int) []BufferOut {
+	if len(list) <= n {
+		return list
+	}
+	return append(list[:0:0], list[len(list)-n:]...)
 }
 
 // Run a project
-func (p *Project) run(path string, stream chan Response, stop <-chan bool) (err error) {
+func (p *Project) run(ctx context.Context, path string, stream chan Response) (err error) {
 	var args []string
 	var build *exec.Cmd
 	var r Response
 	defer func() {
 		// https://github.com/golang/go/issues/5615
 		// https://github.com/golang/go/issues/6720
-		if build != nil {
-			build.Process.Signal(os.Interrupt)
+		if build != nil && build.Process != nil {
+			signalGroup(build.Process, os.Interrupt)
 		}
 	}()
 
@@ -616,13 +2655,9 @@ func (p *Project) run(path string, stream chan Response, stop <-chan bool) (err
 	if p.Tools.Run.Dir != "" {
 		build.Dir = p.Tools.Run.Dir
 	}
-	for _, e := range os.Environ() {
-        build.Env = append(build.Env, e)
-    }
-	for k, v := range p.Env {
-		build.Env = append(build.Env, fmt.Sprintf("%s=%s", k, v))
-	}
-	if err := build.Start(); err != nil {
+	build.Env = p.environ()
+	setGroup(build)
+	if err := startGroup(build); err != nil {
 		return err
 	}
 	execOutput, execError := bufio.NewScanner(stdout), bufio.NewScanner(stderr)
@@ -646,7 +2681,7 @@ func (p *Project) run(path string, stream chan Response, stop <-chan bool) (err
 	go scanner(stopError, execError, true)
 	for {
 		select {
-		case <-stop:
+		case <-ctx.Done():
 			return
 		case <-stopOutput:
 			return
@@ -658,6 +2693,12 @@ func (p *Project) run(path string, stream chan Response, stop <-chan bool) (err
 
 // Print with time after
 func (r *Response) print(start time.Time, p *Project) {
+	var (
+		msg string
+		out BufferOut
+	)
+	r.Duration = time.Since(start)
+	p.result(*r)
 	if r.Err != nil {
 		msg = fmt.Sprintln(p.pname(p.Name, 2), ":", Red.Bold(r.Name), "\n", r.Err.Error())
 		out = BufferOut{Time: time.Now(), Text: r.Err.Error(), Type: r.Name, Stream: r.Out}
@@ -669,39 +2710,580 @@ func (r *Response) print(start time.Time, p *Project) {
 	}
 }
 
-// Exec an additional command from a defined path if specified
-func (c *Command) exec(base string, stop <-chan bool) (response Response) {
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	done := make(chan error)
-	args := strings.Split(strings.Replace(strings.Replace(c.Cmd, "'", "", -1), "\"", "", -1), " ")
-	ex := exec.Command(args[0], args[1:]...)
-	ex.Dir = base
+// Terminate sends the stop signal to a process and kills it if it doesn't exit within the stop timeout
+func (c *Command) terminate(process *os.Process, done <-chan error) {
+	sig, ok := signals["SIG"+strings.TrimPrefix(strings.ToUpper(c.StopSignal), "SIG")]
+	if !ok || signalGroup(process, sig) != nil {
+		killGroup(process)
+		return
+	}
+	grace := c.StopTimeout
+	if grace <= 0 {
+		grace = Grace
+	}
+	select {
+	case <-done:
+	case <-time.After(grace):
+		killGroup(process)
+	}
+}
+
+// Ready checks if a command accepts the connections or prints a line matching the pattern
+func (c *Command) ready(pattern *regexp.Regexp, output string) bool {
+	switch {
+	case pattern != nil:
+		for _, line := range strings.Split(output, "\n") {
+			if pattern.MatchString(line) {
+				return true
+			}
+		}
+		return false
+	case strings.HasPrefix(c.WaitFor, "http://") || strings.HasPrefix(c.WaitFor, "https://"):
+		client := http.Client{Timeout: time.Second}
+		resp, err := client.Get(c.WaitFor)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	default:
+		conn, err := net.DialTimeout("tcp", strings.TrimPrefix(c.WaitFor, "tcp://"), time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+}
+
+// Exec an additional command from a defined path if specified, retried on failures
+func (c *Command) exec(ctx context.Context, base string) (response Response) {
+	start := time.Now()
+	defer func() { response.Duration = time.Since(start) }()
+	// the command doesn't run if a before hook fails
+	for _, h := range c.Before {
+		h.vars = append(h.vars, c.vars...)
+		if r := h.exec(ctx, base); ctx.Err() != nil {
+			return
+		} else if r.Err != nil {
+			response.Name = c.Cmd
+			response.Err = errors.New("before hook " + h.Cmd + ": " + r.Err.Error())
+			return
+		}
+	}
+	defer func() {
+		if len(c.After) == 0 {
+			return
+		}
+		if response.exited != nil {
+			// a command left running, the hooks run after its exit or its stop
+			go func(exited <-chan struct{}) {
+				<-exited
+				hctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+				defer cancel()
+				for _, h := range c.After {
+					h.vars = append(h.vars, c.vars...)
+					if r := h.exec(hctx, base); r.Err != nil {
+						leveled("error", Red.Regular("after hook "+h.Cmd+": "+r.Err.Error()))
+					}
+				}
+			}(response.exited)
+			return
+		}
+		hctx := ctx
+		if ctx.Err() != nil {
+			// after a stop of the command, for a limited time
+			var cancel context.CancelFunc
+			hctx, cancel = context.WithTimeout(context.Background(), HookTimeout)
+			defer cancel()
+		}
+		for _, h := range c.After {
+			h.vars = append(h.vars, c.vars...)
+			if r := h.exec(hctx, base); r.Err != nil && response.Err == nil && ctx.Err() == nil {
+				response.Err = errors.New("after hook " + h.Cmd + ": " + r.Err.Error())
+			}
+		}
+	}()
+	delay := c.Retry.Delay
+	for i := 0; ; i++ {
+		if len(c.Pipe) > 0 {
+			response = c.pipe(ctx, base)
+		} else {
+			response = c.start(ctx, base)
+		}
+		if response.Err == nil || i >= c.Retry.Count {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if c.Retry.Backoff > 1 {
+			delay = time.Duration(float64(delay) * c.Retry.Backoff)
+		}
+	}
+}
+
+// Args returns the docker args to run a command in the container, and the docker command removing what it started
+// relative host paths of the volumes are resolved from the dir of the command, a command run by exec writes its pid
+// as the kill of the docker client doesn't stop it
+func (c *Container) args(cmd []string, dir string, flags ...string) (args []string, stop []string) {
+	name := fmt.Sprint(RPrefix, "-", time.Now().UnixNano())
+	if c.Name != "" {
+		pid := "/tmp/" + name + ".pid"
+		args = []string{"docker", "exec"}
+		cmd = append([]string{"sh", "-c", `echo $$ > ` + pid + ` && exec "$0" "$@"`}, cmd...)
+		stop = []string{"docker", "exec", c.Name, "sh", "-c", `p=$(cat ` + pid + `) && kill $p; for i in 1 2 3 4 5; do kill -0 $p 2>/dev/null || break; sleep 1; done; kill -9 $p 2>/dev/null; rm -f ` + pid}
+	} else {
+		args = []string{"docker", "run", "--rm", "--name", name}
+		stop = []string{"docker", "rm", "-f", name}
+		for _, v := range c.Volumes {
+			if s := strings.SplitN(v, ":", 2); len(s) == 2 && strings.HasPrefix(s[0], ".") {
+				v = filepath.Join(dir, s[0]) + ":" + s[1]
+			}
+			args = append(args, "-v", v)
+		}
+	}
+	args = append(args, flags...)
+	if c.Workdir != "" {
+		args = append(args, "-w", c.Workdir)
+	}
+	if c.Name != "" {
+		args = append(args, c.Name)
+	} else {
+		args = append(args, c.Image)
+	}
+	return append(args, cmd...), stop
+}
+
+// Env adds the variables of the env file to the environment of a command
+func (c *Command) env(ex *exec.Cmd) error {
+	env := c.base
+	if env == nil {
+		env = inherited()
+	}
+	vars, err := c.variables(ex.Dir)
+	if err != nil {
+		return err
+	}
+	ex.Env = append(append([]string{}, env...), vars...)
+	return nil
+}
+
+// Variables returns the extra env variables of a command and the ones of its env file, as key=value
+func (c *Command) variables(dir string) ([]string, error) {
+	vars := append([]string{}, c.vars...)
+	if c.EnvFile == "" {
+		return vars, nil
+	}
+	name := c.EnvFile
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	envs, err := dotenv(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range sortedMap(envs) {
+		vars = append(vars, k+"="+envs[k])
+	}
+	return vars, nil
+}
+
+// Exported returns the docker flags passing the variables of the project and of a command into its container
+// only the names are passed, docker reads the values from the env of its client
+func (c *Command) exported(dir string) ([]string, error) {
+	vars, err := c.variables(dir)
+	if err != nil {
+		return nil, err
+	}
+	var flags []string
+	seen := make(map[string]bool)
+	for _, v := range append(append([]string{}, c.keys...), vars...) {
+		k := strings.SplitN(v, "=", 2)[0]
+		if !seen[k] {
+			seen[k] = true
+			flags = append(flags, "-e", k)
+		}
+	}
+	return flags, nil
+}
+
+// Conditional checks if a command has a condition on the changed paths
+func (c *Command) conditional() bool {
+	return len(c.When) > 0 || len(c.WhenRegex) > 0
+}
+
+// When checks if a command runs for the changed paths, always true without paths or conditions
+func (c *Command) when(rels []string) (bool, error) {
+	if len(rels) == 0 || !c.conditional() {
+		return true, nil
+	}
+	var regex []*regexp.Regexp
+	for _, v := range c.WhenRegex {
+		r, err := regexp.Compile(v)
+		if err != nil {
+			return false, err
+		}
+		regex = append(regex, r)
+	}
+	t := Trigger{Paths: c.When}
+	for _, rel := range rels {
+		if t.match(rel) {
+			return true, nil
+		}
+		for _, r := range regex {
+			if r.MatchString(rel) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Dir returns the working dir of a command
+func (c *Command) dir(base string) string {
 	// make cmd path
 	if c.Path != "" {
 		if strings.Contains(c.Path, base) {
-			ex.Dir = c.Path
-		} else {
-			ex.Dir = filepath.Join(base, c.Path)
+			return c.Path
 		}
+		return filepath.Join(base, c.Path)
+	}
+	return base
+}
+
+// Pipe runs the stages of a pipeline, the output of each one is the input of the next one
+func (c *Command) pipe(ctx context.Context, base string) (response Response) {
+	var stdout buffer
+	var stderr buffer
+	var cmds []*exec.Cmd
+	var names []string
+	for _, s := range c.Pipe {
+		names = append(names, s.Cmd)
+	}
+	response.Name = strings.Join(names, " | ")
+	for _, s := range c.Pipe {
+		args := fields(s.Cmd)
+		if s.Shell {
+			args = shell(s.Cmd)
+		}
+		if len(args) == 0 {
+			response.Err = errors.New("empty command")
+			return
+		}
+		ex := exec.Command(args[0], args[1:]...)
+		ex.Dir = s.dir(c.dir(base))
+		s.vars = append(s.vars, c.vars...)
+		if err := s.env(ex); err != nil {
+			response.Err = err
+			return
+		}
+		ex.Stderr = &stderr
+		if len(cmds) > 0 {
+			in, err := cmds[len(cmds)-1].StdoutPipe()
+			if err != nil {
+				response.Err = err
+				return
+			}
+			ex.Stdin = in
+		}
+		setGroup(ex)
+		cmds = append(cmds, ex)
+	}
+	cmds[len(cmds)-1].Stdout = &stdout
+	// kill every stage
+	kill := func() {
+		for _, ex := range cmds {
+			if ex.Process != nil {
+				killGroup(ex.Process)
+			}
+		}
+	}
+	for _, ex := range cmds {
+		if err := startGroup(ex); err != nil {
+			kill()
+			response.Err = err
+			return
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		var failed error
+		for _, ex := range cmds {
+			if err := ex.Wait(); err != nil && failed == nil {
+				failed = err
+			}
+		}
+		done <- failed
+	}()
+	var timeout <-chan time.Time
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
 	}
-	ex.Stdout = &stdout
-	ex.Stderr = &stderr
-	// Start command
-	ex.Start()
-	go func() { done <- ex.Wait() }()
-	// Wait a result
 	select {
-	case <-stop:
-		// Stop running command
-		ex.Process.Kill()
+	case <-ctx.Done():
+		kill()
+		response.Name = ""
+	case <-timeout:
+		kill()
+		response.Timeout = true
+		response.Err = errors.New(stderr.String() + stdout.String() + "killed after a timeout of " + c.Timeout.String())
 	case err := <-done:
-		// Command completed
-		response.Name = c.Cmd
 		response.Out = stdout.String()
 		if err != nil {
+			response.ExitCode = exitCode(err)
 			response.Err = errors.New(stderr.String() + stdout.String())
 		}
 	}
 	return
 }
+
+// Start a command and wait for its result
+func (c *Command) start(ctx context.Context, base string) (response Response) {
+	var stdout buffer
+	var stderr buffer
+	var logFile io.Closer
+	done := make(chan error, 1)
+	args := fields(c.Cmd)
+	if c.args != nil {
+		args = c.args
+	} else if c.Shell {
+		// pipes, redirections and globs are handled by the shell
+		args = shell(c.Cmd)
+	}
+	if len(args) == 0 {
+		response.Name = c.Cmd
+		response.Err = errors.New("empty command")
+		return
+	}
+	// docker run or exec, with the env of the project and of the command
+	var container []string
+	if c.Container.Image != "" || c.Container.Name != "" {
+		if c.Shell {
+			args = []string{"sh", "-c", c.Cmd}
+		}
+		flags, err := c.exported(c.dir(base))
+		if err != nil {
+			response.Name = c.Cmd
+			response.Err = err
+			return
+		}
+		if c.Stdin {
+			flags = append(flags, "-i")
+		}
+		if c.Pty {
+			flags = append(flags, "-t")
+		}
+		args, container = c.Container.args(args, c.dir(base), flags...)
+	}
+	ex := exec.Command(args[0], args[1:]...)
+	ex.Dir = c.dir(base)
+	if err := c.env(ex); err != nil {
+		response.Name = c.Cmd
+		response.Err = err
+		return
+	}
+	ex.Stdout = &stdout
+	ex.Stderr = &stderr
+	// stream the output to the log file
+	if c.Log != "" {
+		name := c.Log
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(base, name)
+		}
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if c.Append {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(name, flag, Permission)
+		if err != nil {
+			response.Name = c.Cmd
+			response.Err = err
+			return
+		}
+		logFile = f
+		ex.Stdout = io.MultiWriter(&stdout, f)
+		ex.Stderr = io.MultiWriter(&stderr, f)
+	}
+	// the output of a daemon printed line by line, as the one of its project
+	var lines []*lineWriter
+	if c.Daemon && c.Output {
+		lines = []*lineWriter{
+			{w: Output, prefix: c.prefix, activity: c.project, command: c.Cmd},
+			{w: Output, prefix: c.prefix, activity: c.project, command: c.Cmd},
+		}
+		ex.Stdout = io.MultiWriter(ex.Stdout, lines[0])
+		ex.Stderr = io.MultiWriter(ex.Stderr, lines[1])
+	}
+	// pseudo terminal, stdout and stderr are merged
+	var pty, tty *os.File
+	copied := make(chan bool)
+	if c.Pty {
+		var err error
+		if pty, tty, err = openPty(); err != nil {
+			response.Name = c.Cmd
+			response.Err = err
+			return
+		}
+		output := ex.Stdout
+		ex.Stdin, ex.Stdout, ex.Stderr = tty, tty, tty
+		go func() {
+			io.Copy(output, pty)
+			close(copied)
+		}()
+	}
+	// kill the command if it hangs
+	var timeout <-chan time.Time
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	// check the readiness, the command is left running once it's ready
+	var ready <-chan time.Time
+	var pattern *regexp.Regexp
+	if c.WaitFor != "" {
+		if !strings.Contains(c.WaitFor, "://") && !isAddr(c.WaitFor) {
+			var err error
+			if pattern, err = regexp.Compile(c.WaitFor); err != nil {
+				response.Name = c.Cmd
+				response.Err = err
+				return
+			}
+		}
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		ready = ticker.C
+	}
+	var in io.WriteCloser
+	if c.Stdin && pty != nil {
+		in = pty
+	} else if c.Stdin {
+		var err error
+		if in, err = ex.StdinPipe(); err != nil {
+			response.Name = c.Cmd
+			response.Err = err
+			return
+		}
+	}
+	// Start command
+	if pty != nil {
+		setPty(ex)
+	} else {
+		setGroup(ex)
+	}
+	// the limits are applied before the exec, the children can't escape them
+	err := setLimits(ex, c)
+	if err == nil {
+		err = startGroup(ex)
+	}
+	if tty != nil {
+		// used only by the command
+		tty.Close()
+	}
+	if err != nil {
+		if pty != nil {
+			pty.Close()
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+		response.Name = c.Cmd
+		response.Err = err
+		return
+	}
+	if in != nil {
+		stdin.set(in)
+	}
+	exited := make(chan struct{})
+	go func() {
+		err := ex.Wait()
+		if in != nil {
+			stdin.unset(in)
+		}
+		if pty != nil {
+			<-copied
+			pty.Close()
+		}
+		for _, l := range lines {
+			l.flush()
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+		if container != nil {
+			// the container and the command of docker exec survive to the kill of the docker client
+			exec.Command(container[0], container[1:]...).Run()
+		}
+		done <- err
+		close(exited)
+	}()
+	// left running, stopped on the next reload
+	detach := func() {
+		response.Name = c.Cmd
+		response.exited = exited
+		response.Out = stdout.String()
+		stdout.Close()
+		stderr.Close()
+		go func() {
+			select {
+			case <-alive(ctx).Done():
+				c.terminate(ex.Process, done)
+			case <-done:
+			}
+		}()
+	}
+	if c.Daemon {
+		detach()
+		return
+	}
+	// Wait a result
+	for {
+		select {
+		case <-ctx.Done():
+			// Stop running command
+			c.terminate(ex.Process, done)
+		case <-ready:
+			if !c.ready(pattern, stdout.String()+stderr.String()) {
+				continue
+			}
+			detach()
+		case <-timeout:
+			// Command timed out
+			killGroup(ex.Process)
+			response.Name = c.Cmd
+			response.Out = stdout.String()
+			response.Timeout = true
+			response.Err = errors.New(stderr.String() + stdout.String() + "killed after a timeout of " + c.Timeout.String())
+		case err := <-done:
+			// Command completed
+			response.Name = c.Cmd
+			response.Out = stdout.String()
+			if err != nil {
+				response.ExitCode = exitCode(err)
+				response.Err = errors.New(stderr.String() + stdout.String())
+			}
+			// custom error pattern
+			if c.ErrorPattern != "" {
+				r, err := regexp.Compile(c.ErrorPattern)
+				if err != nil {
+					response.Err = err
+					return
+				}
+				for _, line := range strings.Split(response.Out, "\n") {
+					if r.MatchString(line) {
+						response.Errors = append(response.Errors, line)
+					}
+				}
+				if c.ErrorFail && response.Err == nil && len(response.Errors) > 0 {
+					response.Err = errors.New(strings.Join(response.Errors, "\n"))
+				}
+			}
+		}
+		return
+	}
+}
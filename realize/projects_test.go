@@ -2,10 +2,18 @@ package realize
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -53,8 +61,12 @@ func TestProject_Before(t *testing.T) {
 		},
 	})
 	r.Projects[0].Before()
-	if os.Getenv(input) != input {
-		t.Error("Unexpected error expected", input, "instead", os.Getenv(input))
+	if !included(asStrings(r.Projects[0].environ()), input+"="+input) || os.Getenv(input) != "" {
+		t.Error("Unexpected env", r.Projects[0].environ())
+	}
+	// the defaults of the resolved config
+	if p := r.Projects[0]; p.Watcher.Debounce != Debounce || p.StopOnError == nil || !*p.StopOnError {
+		t.Error("Unexpected defaults", p.Watcher.Debounce, p.StopOnError)
 	}
 }
 
@@ -92,6 +104,60 @@ func TestProject_Change(t *testing.T) {
 	}
 }
 
+func TestProject_Options(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	off := false
+	r := Realize{Sync: make(chan string, 10)}
+	r.Settings.Options = Options{Color: &off, BufferSize: 2}
+	r.Projects = append(r.Projects, Project{Name: "app", parent: &r, Options: Options{Verbosity: "quiet"}})
+	p := &r.Projects[0]
+	if o := p.options(); o.Color == nil || *o.Color || o.BufferSize != 2 || o.Verbosity != "quiet" {
+		t.Error("Unexpected options", o)
+	}
+	for i := 0; i < 3; i++ {
+		p.stamp("log", BufferOut{Text: string(rune('0' + i))}, Green.Bold("started\n"), "")
+	}
+	p.stamp("error", BufferOut{Text: "failed"}, Red.Bold("failed\n"), "")
+	if len(p.Buffer.StdLog) != 2 || p.Buffer.StdLog[0].Text != "1" || len(p.Buffer.StdErr) != 1 {
+		t.Error("Unexpected buffer", p.Buffer)
+	}
+	if buf.String() != "failed\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+	if p.recovery().Index {
+		t.Error("Unexpected recovery")
+	}
+	// the legacy and the recovery of the settings overridden by the project
+	r.Settings.Legacy, r.Settings.Recovery = Legacy{Interval: time.Second}, Recovery{Tools: true}
+	p.Legacy, p.Recovery, p.Polling = Legacy{Force: true}, Recovery{Index: true}, Polling{Interval: 2 * time.Second}
+	if l := p.legacy(); !l.Force || l.Interval != 2*time.Second {
+		t.Error("Unexpected legacy", l)
+	}
+	if rec := p.recovery(); !rec.Index || rec.Events || !rec.Tools {
+		t.Error("Unexpected recovery", rec)
+	}
+	p.Verbosity = "verbose"
+	if rec := p.recovery(); !rec.Index || !rec.Events || !rec.Tools {
+		t.Error("Unexpected recovery", rec)
+	}
+}
+
+func TestProject_Prefix(t *testing.T) {
+	r := Realize{}
+	r.Projects = []Project{{Name: "api", parent: &r}}
+	if v := r.Projects[0].prefix(); v != "" {
+		t.Errorf("Unexpected prefix of the only project %q", v)
+	}
+	r.Projects = append(r.Projects, Project{Name: "worker", parent: &r})
+	if v := ansi.ReplaceAllString(r.Projects[0].prefix(), ""); v != "api    | " {
+		t.Errorf("Unexpected prefix %q", v)
+	}
+	if c, _ := r.Projects[1].fill(Command{Cmd: "go run ."}, "."); c.project != "worker" || c.prefix != r.Projects[1].prefix() {
+		t.Error("Unexpected command", c)
+	}
+}
+
 func TestProject_Reload(t *testing.T) {
 	var buf bytes.Buffer
 	log.SetOutput(&buf)
@@ -106,13 +172,280 @@ func TestProject_Reload(t *testing.T) {
 	r.Reload = func(context Context) {
 		log.Println(context.Path)
 	}
-	stop := make(chan bool)
-	r.Projects[0].Reload(input, stop)
+	r.Projects[0].Reload(context.Background(), input)
 	if !strings.Contains(buf.String(), input) {
 		t.Error("Unexpected error")
 	}
 }
 
+func TestProject_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "touch ran && sleep 5", Shell: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(filepath.Join(dir, "ran")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Error("Expected a first reload", err)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("Project not stopped by its context")
+	}
+}
+
+func TestProject_RunPanic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{Sync: make(chan string, 100)}
+	r.Reload = func(c Context) {
+		panic("broken reload")
+	}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "after", Cmd: "touch cleaned", Global: true}}
+	done := make(chan bool)
+	go func() {
+		p.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Project not stopped by a panic")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cleaned")); err != nil {
+		t.Error("Expected the after commands", err)
+	}
+}
+
+func TestProject_Queue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.go")
+	ioutil.WriteFile(file, []byte("package a"), 0644)
+	r := Realize{Sync: make(chan string, 100)}
+	go func() {
+		for range r.Sync {
+		}
+	}()
+	var mu sync.Mutex
+	var reloads []string
+	r.Reload = func(c Context) {
+		mu.Lock()
+		reloads = append(reloads, strings.Join(c.Paths, ","))
+		mu.Unlock()
+		time.Sleep(400 * time.Millisecond)
+	}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir, Watcher: Watch{Paths: []string{"/"}, Exts: []string{"go"}, Debounce: 10 * time.Millisecond, Queue: true}})
+	p := &r.Projects[0]
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		ioutil.WriteFile(file, []byte("package a"+strings.Repeat("\n", i+1)), 0644)
+	}
+	time.Sleep(900 * time.Millisecond)
+	cancel()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reloads) != 2 || reloads[1] != file {
+		t.Error("Expected a single reload of the queued changes", reloads)
+	}
+}
+
+func TestProject_Plan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	for _, v := range []string{"a.go", "b.txt", "sub/c.go"} {
+		ioutil.WriteFile(filepath.Join(dir, v), []byte("package a"), 0644)
+	}
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Name: "plan", Path: dir, Watcher: Watch{Paths: []string{"/"}, Exts: []string{"go"}}})
+	p := &r.Projects[0]
+	p.Tools.Vet.Status = true
+	p.Watcher.Scripts = []Command{
+		{Type: "before", Cmd: "touch before"},
+		{Type: "after", Name: "test", Cmd: "go test ./..."},
+		{Type: "after", Name: "deploy", Cmd: "touch deploy", Needs: []string{"test"}},
+	}
+	var buf bytes.Buffer
+	if err := p.Plan(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"- a.go", "- sub/c.go", "before (sequence):\n    - touch before", "- Vet: go vet", "after (graph):", "deploy: touch deploy [needs test]"} {
+		if !strings.Contains(buf.String(), v) {
+			t.Error("Expected", v, "in the plan", buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "b.txt") {
+		t.Error("Unexpected file in the plan", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "before")); err == nil {
+		t.Error("Unexpected command run by the plan")
+	}
+}
+
+func TestProject_Check(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	for _, v := range []string{"a.go", "sub/b.go", "docs/c.md"} {
+		ioutil.WriteFile(filepath.Join(dir, v), []byte("package a"), 0644)
+	}
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{Name: "check", Path: dir, EnvFile: ".env", Watcher: Watch{Paths: []string{"/", "sub", "docs", "missing", "gen/**/*.go"}, Exts: []string{"go"}, Ignore: []string{"sub"}}})
+	r.Projects[0].Watcher.Scripts = []Command{{Type: "after", Cmd: "make", Path: "build"}}
+	var buf bytes.Buffer
+	if err := r.Check(&buf); err == nil || err.Error() != "3 errors in the config" {
+		t.Error("Expected the errors", err)
+	}
+	for _, v := range []string{
+		"error: env_file: stat " + filepath.Join(dir, ".env"),
+		"error: command make: path: stat " + filepath.Join(dir, "build"),
+		"error: watcher.paths: stat " + filepath.Join(dir, "missing"),
+		"warning: watcher.paths: gen/**/*.go matches nothing",
+		"warning: watcher.ignored_paths: sub excludes the whole watched path sub",
+		"warning: watcher.paths: docs has no watched file",
+	} {
+		if !strings.Contains(buf.String(), v) {
+			t.Error("Expected", v, "in", buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "watched path /") {
+		t.Error("Unexpected warning", buf.String())
+	}
+	r.Projects[0].EnvFile, r.Projects[0].Watcher.Scripts, r.Projects[0].Watcher.Paths = "", nil, []string{"/"}
+	if err := r.Check(&buf); err != nil {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestProject_Lint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "cmd", "build"), 0755)
+	os.MkdirAll(filepath.Join(dir, "vendor", "x"), 0755)
+	for _, v := range []string{"a.go", "cmd/b.go", "cmd/c.html", "vendor/x/a.tmpl"} {
+		ioutil.WriteFile(filepath.Join(dir, v), []byte("package a"), 0644)
+	}
+	r := Realize{}
+	r.Schema.Scripts = map[string]Command{"make": {Cmd: "make", Path: "build", Before: []Command{{Cmd: "gen", Path: "cmd/build"}}}}
+	p := Project{Name: "lint", Path: dir, parent: &r, Watcher: Watch{Paths: []string{"/", "cmd"}, Exts: []string{"go", "tmpl"}, Ignore: []string{"cmd/b.go", "vendor", ".git", "tmp"}}}
+	p.Watcher.Scripts = []Command{{Type: "after", Use: "make"}}
+	var buf bytes.Buffer
+	if hints := p.Lint(&buf); hints != 4 {
+		t.Error("Unexpected suggestions", hints, buf.String())
+	}
+	// the files of the ignored dirs aren't walked, the default ignored paths aren't suggested
+	for _, v := range []string{
+		"lint: watcher.paths: cmd is inside /, its files are watched twice\n    suggestion: remove cmd",
+		"lint: watcher.ignored_paths: tmp matches no file of the watched paths",
+		"lint: watcher.extensions: tmpl matches no file of the watched paths\n    suggestion: remove it, the files have the extensions go, html",
+		"lint: command make: path: build doesn't exist\n    suggestion: did you mean cmd/build?",
+	} {
+		if !strings.Contains(buf.String(), v) {
+			t.Error("Expected", v, "in", buf.String())
+		}
+	}
+}
+
+func TestProject_Platform(t *testing.T) {
+	other := &Project{Path: "other", Args: []string{"--other"}}
+	current := &Project{Path: "bin", Watcher: Watch{Exts: []string{"exe"}}, Tools: Tools{Run: Tool{Args: []string{"-v"}}}}
+	p := Project{Path: "app", Args: []string{"--debug"}, Watcher: Watch{Paths: []string{"/"}, Exts: []string{"go"}}, Tools: Tools{Run: Tool{Status: true}}, Windows: other, Darwin: other, Linux: other}
+	switch runtime.GOOS {
+	case "windows":
+		p.Windows = current
+	case "darwin":
+		p.Darwin = current
+	default:
+		p.Linux = current
+	}
+	p.platform()
+	if p.Path != "bin" || p.Args[0] != "--debug" || p.Watcher.Exts[0] != "exe" || p.Watcher.Paths[0] != "/" || !p.Tools.Run.Status || p.Tools.Run.Args[0] != "-v" {
+		t.Error("Unexpected project", p)
+	}
+	if p.Windows != nil || p.Darwin != nil || p.Linux != nil {
+		t.Error("Unexpected overrides", p)
+	}
+	// the overrides of a command and of its hooks
+	c := Command{Cmd: "./bin/app", StopSignal: "SIGTERM", After: []Command{{Cmd: "rm app.pid"}}}
+	c.Windows = &Command{Cmd: `bin\app.exe`, StopSignal: "SIGKILL"}
+	c.After[0].Windows = &Command{Cmd: "del app.pid"}
+	c.Darwin, c.Linux = c.Windows, c.Windows
+	c.After[0].Darwin, c.After[0].Linux = c.After[0].Windows, c.After[0].Windows
+	s := Schema{}
+	c, err := s.resolve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cmd != `bin\app.exe` || c.StopSignal != "SIGKILL" || c.After[0].Cmd != "del app.pid" {
+		t.Error("Unexpected command", c)
+	}
+}
+
+func TestProject_Profile(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Name: "profile", Profile: "bench"})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "go vet"}}
+	p.Profiles = map[string][]Command{
+		"dev":   {{Type: "after", Cmd: "./app --debug"}},
+		"bench": {{Type: "after", Cmd: "go test -bench ."}},
+	}
+	p.resolve()
+	if len(p.Watcher.Scripts) != 2 || p.Watcher.Scripts[1].Cmd != "go test -bench ." {
+		t.Error("Unexpected scripts", p.Watcher.Scripts)
+	}
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	p.Profile = "missing"
+	p.resolve()
+	if len(p.Watcher.Scripts) != 2 || !strings.Contains(buf.String(), "profile missing not found") {
+		t.Error("Expected an error for a missing profile", buf.String())
+	}
+}
+
 func TestProject_Validate(t *testing.T) {
 	data := map[string]bool{
 		"":                        false,
@@ -140,6 +473,56 @@ func TestProject_Validate(t *testing.T) {
 	}
 }
 
+func TestProject_ValidateShared(t *testing.T) {
+	r := Realize{}
+	r.Schema.Ignore = []string{"vendor", "web"}
+	r.Projects = append(r.Projects, Project{
+		parent:  &r,
+		Watcher: Watch{Exts: []string{"go"}, Ignore: []string{"!web/api"}},
+	})
+	wd := Wdir()
+	data := map[string]bool{
+		"vendor/a.go":  false,
+		"web/a.go":     false,
+		"web/api/a.go": true,
+		"cmd/a.go":     true,
+	}
+	for i, v := range data {
+		if result := r.Projects[0].Validate(filepath.Join(wd, i), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
+
+func TestProject_ValidateTriggers(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts:     []string{"go"},
+			Ignore:   []string{"vendor"},
+			Hidden:   true,
+			Triggers: []Trigger{{Paths: []string{"go.mod", "assets/*.scss"}}},
+		},
+	})
+	wd := Wdir()
+	// the triggers are out of the ignored paths as the other files
+	data := map[string]bool{
+		"go.mod":             true,
+		"sub/go.mod":         true,
+		"assets/a.scss":      true,
+		"vendor/x/go.mod":    false,
+		"assets/x/a.scss":    false,
+		".cache/x/go.mod":    false,
+		"assets/.tmp/a.scss": false,
+	}
+	for i, v := range data {
+		if result := r.Projects[0].Validate(filepath.Join(wd, i), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
+
 func TestProject_Watch(t *testing.T) {
 	var wg sync.WaitGroup
 	r := Realize{}
@@ -156,3 +539,1344 @@ func TestProject_Watch(t *testing.T) {
 	r.Projects[0].Watch(&wg)
 	wg.Wait()
 }
+
+func TestProject_Legacy(t *testing.T) {
+	r := Realize{}
+	r.Settings.Legacy.Interval = time.Second
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+	})
+	if l := r.Projects[0].legacy(); l.Force || l.Interval != time.Second {
+		t.Error("Unexpected legacy settings", l)
+	}
+	r.Projects[0].Polling = Polling{Enabled: true, Interval: 200 * time.Millisecond}
+	if l := r.Projects[0].legacy(); !l.Force || l.Interval != 200*time.Millisecond {
+		t.Error("Unexpected legacy settings", l)
+	}
+}
+
+func TestWatch_Debounce(t *testing.T) {
+	w := Watch{}
+	if w.debounce() != Debounce {
+		t.Error("Expected default debounce instead", w.debounce())
+	}
+	w.Debounce = 2 * time.Second
+	if w.debounce() != 2*time.Second {
+		t.Error("Expected 2s instead", w.debounce())
+	}
+}
+
+func TestProject_ValidateRegex(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts:     []string{"go"},
+			Regex:    []string{`^internal/.*\.go$`},
+			IgnRegex: []string{`_gen\.go$`},
+		},
+	})
+	if err := r.Projects[0].Watcher.compile(); err != nil {
+		t.Fatal(err)
+	}
+	wd := Wdir()
+	data := map[string]bool{
+		"internal/a.go":     true,
+		"internal/a_gen.go": false,
+		"cmd/a.go":          false,
+		"internal/sub":      true,
+	}
+	for i, v := range data {
+		if result := r.Projects[0].Validate(filepath.Join(wd, i), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+	r.Projects[0].Watcher.Regex = []string{"("}
+	if err := r.Projects[0].Watcher.compile(); err == nil {
+		t.Error("Expected an invalid regex error")
+	}
+}
+
+func TestProject_Changed(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+	})
+	f, err := ioutil.TempFile("", "hash_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("package main")
+	f.Close()
+	p := &r.Projects[0]
+	if !p.changed(f.Name()) || !p.changed(f.Name()) {
+		t.Error("Expected always changed without hash check")
+	}
+	p.Watcher.Hash = true
+	if !p.changed(f.Name()) {
+		t.Error("Expected changed on first check")
+	}
+	if p.changed(f.Name()) {
+		t.Error("Expected unchanged content")
+	}
+	ioutil.WriteFile(f.Name(), []byte("package test"), Permission)
+	if !p.changed(f.Name()) {
+		t.Error("Expected changed content")
+	}
+}
+
+func TestProject_Modified(t *testing.T) {
+	f, err := ioutil.TempFile("", "chmod_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("package main")
+	f.Close()
+	p := Project{Watcher: Watch{Hash: true, Chmod: true}}
+	if !p.modified(fsnotify.Event{Name: f.Name(), Op: fsnotify.Write}) || p.modified(fsnotify.Event{Name: f.Name(), Op: fsnotify.Write}) {
+		t.Error("Expected the hash of the writes to be compared")
+	}
+	// the content is the same, the mode is the change
+	os.Chmod(f.Name(), 0755)
+	if !p.modified(fsnotify.Event{Name: f.Name(), Op: fsnotify.Chmod}) {
+		t.Error("Expected the chmod as a change with the hash")
+	}
+	p.Watcher.Chmod = false
+	if p.modified(fsnotify.Event{Name: f.Name(), Op: fsnotify.Chmod}) {
+		t.Error("Unexpected chmod as a change")
+	}
+}
+
+func TestProject_Follow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symlink_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shared, err := ioutil.TempDir("", "symlink_shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(shared)
+	ioutil.WriteFile(filepath.Join(shared, "a.go"), []byte("package a"), Permission)
+	if err := os.Symlink(shared, filepath.Join(dir, "shared")); err != nil {
+		t.Skip("Symlinks not supported", err)
+	}
+	// cycle
+	os.Symlink(dir, filepath.Join(shared, "loop"))
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Path:   dir,
+		Watcher: Watch{
+			Exts:     []string{"go"},
+			Symlinks: true,
+		},
+	})
+	p := &r.Projects[0]
+	p.watcher = PollingWatcher(0)
+	defer p.watcher.Close()
+	p.visit(dir)
+	if err := filepath.Walk(dir, p.walk); err != nil {
+		t.Fatal(err)
+	}
+	if p.files != 1 {
+		t.Error("Expected one file indexed instead", p.files)
+	}
+}
+
+func TestProject_ValidateGlob(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts:   []string{"go"},
+			Paths:  []string{"src/**/*.go"},
+			Ignore: []string{"**/testdata"},
+		},
+	})
+	wd := Wdir()
+	data := map[string]bool{
+		"src/a.go":            true,
+		"src/a/b/c.go":        true,
+		"cmd/a.go":            false,
+		"src/testdata/a.go":   false,
+		"src/a/testdata/b.go": false,
+	}
+	for i, v := range data {
+		if result := r.Projects[0].Validate(filepath.Join(wd, i), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
+
+func TestProject_ValidateNegation(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts:   []string{"go"},
+			Paths:  []string{"./...", "!./vendor", "!./testdata"},
+			Ignore: []string{"cmd", "!cmd/app"},
+		},
+	})
+	wd := Wdir()
+	data := map[string]bool{
+		"a.go":            true,
+		"src/a.go":        true,
+		"vendor":          false,
+		"vendor/a/b.go":   false,
+		"testdata/a.go":   false,
+		"cmd/a.go":        false,
+		"cmd/app/main.go": true,
+	}
+	for i, v := range data {
+		if result := r.Projects[0].Validate(filepath.Join(wd, i), false); result != v {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
+
+func TestProject_Single(t *testing.T) {
+	f, err := ioutil.TempFile("", "single_test*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts: []string{"go"},
+		},
+	})
+	p := &r.Projects[0]
+	p.watcher = PollingWatcher(0)
+	defer p.watcher.Close()
+	if p.Validate(f.Name(), false) {
+		t.Error("Unexpected valid path", f.Name())
+	}
+	p.single(f.Name())
+	if !p.Validate(f.Name(), true) {
+		t.Error("Expected a valid path", f.Name())
+	}
+	if p.files != 1 {
+		t.Error("Expected one file instead", p.files)
+	}
+}
+
+func TestBatch_Add(t *testing.T) {
+	b := batch{}
+	b.add("a.go")
+	b.add("b.go")
+	b.add("a.go")
+	if len(b.files) != 2 {
+		t.Error("Expected two files instead", b.files)
+	}
+}
+
+func TestWatch_Trigger(t *testing.T) {
+	w := Watch{
+		Triggers: []Trigger{
+			{Exts: []string{"scss", "css"}, Debounce: 50 * time.Millisecond},
+			{Exts: []string{"tmpl"}},
+			{Paths: []string{"go.mod", "/tools/go.sum"}},
+		},
+	}
+	if i := w.trigger("/a/sub/go.mod", "sub/go.mod"); i != 2 {
+		t.Error("Expected the go.mod trigger instead", i)
+	}
+	if i := w.trigger("/a/tools/go.sum", "tools/go.sum"); i != 2 {
+		t.Error("Expected the go.sum trigger instead", i)
+	}
+	if i := w.trigger("/a/go.sum", "go.sum"); i != -1 {
+		t.Error("Unexpected trigger", i)
+	}
+	if i := w.trigger("/a/b.css", "b.css"); i != 0 {
+		t.Error("Expected the first trigger instead", i)
+	}
+	if i := w.trigger("/a/b.go", "b.go"); i != -1 {
+		t.Error("Unexpected trigger", i)
+	}
+	w.Triggers[0].Ignore = []string{"vendor", "web/*.min.css"}
+	for _, v := range []string{"vendor/x/a.css", "web/a.min.css"} {
+		if i := w.trigger("/a/"+v, v); i != -1 {
+			t.Error("Unexpected trigger of an ignored path", v, i)
+		}
+	}
+	if i := w.trigger("/a/web/a.css", "web/a.css"); i != 0 {
+		t.Error("Expected the first trigger instead", i)
+	}
+	if d := w.delay(0); d != 50*time.Millisecond {
+		t.Error("Unexpected delay", d)
+	}
+	if d := w.delay(1); d != Debounce {
+		t.Error("Unexpected delay", d)
+	}
+	if d := w.delay(-1); d != Debounce {
+		t.Error("Unexpected delay", d)
+	}
+}
+
+func TestWatch_Visible(t *testing.T) {
+	w := Watch{Visible: []string{".config/templates", "**/.keep"}}
+	data := map[string]bool{
+		".config":                  true,
+		".config/templates":        true,
+		".config/templates/a.tmpl": true,
+		".config/other.yaml":       false,
+		".git/config":              false,
+		"a/.keep":                  true,
+	}
+	for i, v := range data {
+		if w.visible(i) != v {
+			t.Error("Unexpected result", i, "expected", v)
+		}
+	}
+}
+
+func TestWatch_SameExt(t *testing.T) {
+	nocase, sensitive := true, false
+	w := Watch{NoCase: &nocase}
+	if !w.sameExt("go", "GO") {
+		t.Error("Expected a case insensitive match")
+	}
+	w.NoCase = &sensitive
+	if w.sameExt("go", "GO") || !w.sameExt("go", "go") {
+		t.Error("Expected a case sensitive match")
+	}
+}
+
+func TestProject_Prune(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+	})
+	p := &r.Projects[0]
+	p.watcher = PollingWatcher(0)
+	defer p.watcher.Close()
+	sep := string(os.PathSeparator)
+	p.index("a", true)
+	p.index("a"+sep+"b.go", false)
+	p.index("a"+sep+"c", true)
+	p.index("a"+sep+"c"+sep+"d.go", false)
+	p.index("ab.go", false)
+	if files := p.prune("a"); files != 2 {
+		t.Error("Expected two files pruned instead", files)
+	}
+	if _, ok := p.paths["ab.go"]; len(p.paths) != 1 || !ok {
+		t.Error("Unexpected paths", p.paths)
+	}
+}
+
+func TestProject_ValidateSize(t *testing.T) {
+	f, err := ioutil.TempFile("", "size_test*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("package main")
+	f.Close()
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Watcher: Watch{
+			Exts: []string{"go"},
+		},
+	})
+	if !r.Projects[0].Validate(f.Name(), true) {
+		t.Error("Expected a valid file")
+	}
+	r.Projects[0].Watcher.MaxSize = 4
+	if r.Projects[0].Validate(f.Name(), true) {
+		t.Error("Expected a file too big")
+	}
+}
+
+func TestProject_WatchSkipFirst(t *testing.T) {
+	var wg sync.WaitGroup
+	r := Realize{}
+	reloads := 0
+	r.Reload = func(context Context) {
+		reloads++
+	}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		exit:   make(chan os.Signal, 1),
+		Watcher: Watch{
+			SkipFirst: true,
+		},
+	})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(r.Projects[0].exit)
+	}()
+	wg.Add(1)
+	r.Projects[0].Watch(&wg)
+	wg.Wait()
+	if reloads != 0 {
+		t.Error("Unexpected reloads", reloads)
+	}
+}
+
+func TestProject_WalkDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "depth_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b", "c"), Permission); err != nil {
+		t.Fatal(err)
+	}
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{
+		parent: &r,
+		Path:   dir,
+		Watcher: Watch{
+			MaxDepth: 2,
+		},
+	})
+	p := &r.Projects[0]
+	p.watcher = PollingWatcher(0)
+	defer p.watcher.Close()
+	if err := filepath.Walk(dir, p.walk); err != nil {
+		t.Fatal(err)
+	}
+	if p.folders != 3 {
+		t.Error("Expected three folders instead", p.folders)
+	}
+}
+
+func TestCommand_Timeout(t *testing.T) {
+	c := Command{Cmd: "sleep 5", Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	r := c.exec(context.Background(), Wdir())
+	if !r.Timeout || r.Err == nil {
+		t.Error("Expected a timeout", r)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Command not killed after the timeout")
+	}
+	c = Command{Cmd: "true", Timeout: time.Second}
+	if r := c.exec(context.Background(), Wdir()); r.Timeout || r.Err != nil {
+		t.Error("Unexpected timeout", r)
+	}
+}
+
+func TestCommand_Shell(t *testing.T) {
+	c := Command{Cmd: "echo 'a b' | tr a-z A-Z && echo c", Shell: true}
+	r := c.exec(context.Background(), Wdir())
+	if r.Err != nil || r.Out != "A B\nc\n" {
+		t.Error("Unexpected shell output", r.Out, r.Err)
+	}
+}
+
+func TestCommand_StopSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Command{Cmd: `trap 'touch stopped; kill $!; exit 0' TERM; sleep 5 & wait`, Shell: true, StopSignal: "SIGTERM", StopTimeout: 2 * time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	c.exec(ctx, dir)
+	if _, err := os.Stat(filepath.Join(dir, "stopped")); err != nil {
+		t.Error("Expected a clean shutdown", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Unexpected wait of the stop timeout")
+	}
+}
+
+func TestCommand_Retry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Command{Cmd: "test -f ready || { touch ready; exit 1; }", Shell: true}
+	if r := c.exec(context.Background(), dir); r.Err == nil {
+		t.Error("Expected a failure without retries")
+	}
+	os.Remove(filepath.Join(dir, "ready"))
+	c.Retry = Retry{Count: 2, Delay: 10 * time.Millisecond, Backoff: 2}
+	if r := c.exec(context.Background(), dir); r.Err != nil {
+		t.Error("Unexpected failure after a retry", r.Err)
+	}
+}
+
+func TestCommand_ErrorPattern(t *testing.T) {
+	c := Command{Cmd: "printf 'ok\nERROR: db down\nok\n'", Shell: true, ErrorPattern: "^ERROR"}
+	r := c.exec(context.Background(), Wdir())
+	if r.Err != nil || len(r.Errors) != 1 || r.Errors[0] != "ERROR: db down" {
+		t.Error("Unexpected errors", r.Errors, r.Err)
+	}
+	c.ErrorFail = true
+	if r := c.exec(context.Background(), Wdir()); r.Err == nil {
+		t.Error("Expected a failure on the error pattern")
+	}
+}
+
+func TestProject_CommandsFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Command{Cmd: "exit 3", Shell: true}
+	if r := c.exec(context.Background(), dir); r.Err == nil || r.ExitCode != 3 {
+		t.Error("Unexpected exit code", r.ExitCode)
+	}
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.commands(context.Background(), []Command{c, {Cmd: "touch next"}}, "before")
+	if _, err := os.Stat(filepath.Join(dir, "next")); err == nil {
+		t.Error("Unexpected command after a failure")
+	}
+	c.IgnoreErrors = true
+	p.commands(context.Background(), []Command{c, {Cmd: "touch next"}}, "before")
+	if _, err := os.Stat(filepath.Join(dir, "next")); err != nil {
+		t.Error("Expected the command after an ignored failure", err)
+	}
+}
+
+func TestProject_Failed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "error", Cmd: "echo $REALIZE_FAILED_COMMAND $REALIZE_FAILED_OUTPUT > failed", Shell: true}}
+	p.commands(context.Background(), []Command{{Cmd: "echo broken; exit 1", Shell: true}}, "before")
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "failed")); string(b) != "echo broken; exit 1 broken\n" {
+		t.Error("Unexpected error command env", string(b))
+	}
+}
+
+func TestProject_Success(t *testing.T) {
+	dir, err := ioutil.TempDir("", "success")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "exit 1", Shell: true}, {Type: "success", Cmd: "touch ok"}}
+	p.Reload(context.Background(), "")
+	if _, err := os.Stat(filepath.Join(dir, "ok")); err == nil {
+		t.Error("Unexpected success command after a failure")
+	}
+	// the ignored errors aren't failures of the reload
+	p.Watcher.Scripts[0].IgnoreErrors = true
+	p.Reload(context.Background(), "")
+	if _, err := os.Stat(filepath.Join(dir, "ok")); err != nil {
+		t.Error("Expected the success command", err)
+	}
+	// the failures of the triggers and of the schedules are out of the reload
+	ctx := context.WithValue(context.Background(), failures{}, new(int32))
+	p.failed(context.Background(), Response{Name: "trigger", Err: errors.New("failed")})
+	p.failed(ctx, Response{Name: "ignored", Err: errors.New("failed"), ignored: true})
+	if n := reloadFailures(ctx); n != 0 {
+		t.Error("Unexpected failures of the reload", n)
+	}
+	p.failed(ctx, Response{Name: "before", Err: errors.New("failed")})
+	if n := reloadFailures(ctx); n != 1 {
+		t.Error("Expected a failure of the reload", n)
+	}
+}
+
+func TestProject_Scheduled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "after", Cmd: "echo run >> runs", Shell: true, Schedule: "@every 100ms"}}
+	p.Reload(context.Background(), "")
+	if _, err := os.Stat(filepath.Join(dir, "runs")); err == nil {
+		t.Error("Unexpected scheduled command on a reload")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.scheduled(ctx)
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "runs"))
+	if n := strings.Count(string(b), "run"); n < 2 {
+		t.Error("Expected scheduled runs instead", n)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if b2, _ := ioutil.ReadFile(filepath.Join(dir, "runs")); len(b2) != len(b) {
+		t.Error("Unexpected run after the stop")
+	}
+}
+
+func TestProject_StopOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "exit 1", Shell: true}, {Type: "before", Cmd: "touch next"}, {Type: "after", Cmd: "touch after"}}
+	p.Reload(context.Background(), "")
+	for _, v := range []string{"next", "after"} {
+		if _, err := os.Stat(filepath.Join(dir, v)); err == nil {
+			t.Error("Unexpected command after a failure", v)
+		}
+	}
+	stop := false
+	p.StopOnError = &stop
+	p.Reload(context.Background(), "")
+	for _, v := range []string{"next", "after"} {
+		if _, err := os.Stat(filepath.Join(dir, v)); err != nil {
+			t.Error("Expected the command after a failure", v)
+		}
+	}
+}
+
+func TestCommand_Log(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Command{Cmd: "echo text", Log: "out.log", Append: true}
+	c.exec(context.Background(), dir)
+	c.exec(context.Background(), dir)
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "out.log")); string(b) != "text\ntext\n" {
+		t.Error("Unexpected appended log", string(b))
+	}
+	c.Append = false
+	c.exec(context.Background(), dir)
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "out.log")); string(b) != "text\n" {
+		t.Error("Unexpected truncated log", string(b))
+	}
+}
+
+func TestCommand_Stdin(t *testing.T) {
+	r := Realize{}
+	result := make(chan Response)
+	c := Command{Cmd: "head -n 1", Stdin: true}
+	go func() { result <- c.exec(context.Background(), Wdir()) }()
+	for i := 0; i < 100; i++ {
+		stdin.Lock()
+		ready := stdin.w != nil
+		stdin.Unlock()
+		if ready {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Shortcuts(strings.NewReader("text\n"))
+	select {
+	case res := <-result:
+		if res.Out != "text\n" {
+			t.Error("Unexpected output", res.Out)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Input not forwarded to the command")
+	}
+}
+
+func TestProject_Expand(t *testing.T) {
+	p := Project{Path: "."}
+	wd := Wdir()
+	data := map[string]string{
+		"":                 "go test ./...  ",
+		"a.go":             "go test ./... ./a.go go",
+		"pkg/handler/b.go": "go test ./pkg/handler/... ./pkg/handler/b.go go",
+	}
+	for i, v := range data {
+		path := ""
+		if i != "" {
+			path = filepath.Join(wd, i)
+		}
+		if result, err := p.expand("go test {{.Dir}}/... {{.File}} {{.Ext}}", path); err != nil || result != v {
+			t.Error("Unexpected result", i, "expected", v, result, err)
+		}
+	}
+	if _, err := p.expand("go test {{.Dir", ""); err == nil {
+		t.Error("Expected a template error")
+	}
+}
+
+func TestCommand_WaitFor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := Command{Cmd: "echo listening; sleep 5", Shell: true, WaitFor: "^listening"}
+	start := time.Now()
+	if r := c.exec(ctx, Wdir()); r.Err != nil {
+		t.Error("Unexpected error", r.Err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("Command not left running once ready")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c = Command{Cmd: "sleep 5", WaitFor: l.Addr().String()}
+	start = time.Now()
+	if r := c.exec(ctx, Wdir()); r.Err != nil || time.Since(start) > 2*time.Second {
+		t.Error("Expected a ready address", r.Err)
+	}
+	c = Command{Cmd: "true", WaitFor: "^never"}
+	if r := c.exec(ctx, Wdir()); r.Err != nil {
+		t.Error("Unexpected error of a completed command", r.Err)
+	}
+}
+
+func TestCommand_Daemon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := Command{Cmd: `trap 'touch stopped; kill $!; exit 0' TERM; sleep 5 & wait`, Shell: true, Daemon: true, StopSignal: "SIGTERM"}
+	start := time.Now()
+	c.exec(ctx, dir)
+	if time.Since(start) > time.Second {
+		t.Error("Daemon not left running")
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(filepath.Join(dir, "stopped")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Error("Daemon not stopped on reload", err)
+	}
+}
+
+func TestProject_RestartDelay(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, init: true, RestartDelay: 200 * time.Millisecond})
+	p := &r.Projects[0]
+	start := time.Now()
+	p.Reload(context.Background(), "")
+	if time.Since(start) < p.RestartDelay {
+		t.Error("Expected a wait before the reload")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	p.Reload(ctx, "")
+	if time.Since(start) >= p.RestartDelay {
+		t.Error("Unexpected wait of a stopped reload")
+	}
+}
+
+func TestProject_Timeout(t *testing.T) {
+	var results []Response
+	r := Realize{}
+	r.Result = func(c Context) {
+		results = append(results, c.Response)
+	}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: Wdir(), Timeout: 200 * time.Millisecond})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "sleep 5"}, {Type: "after", Cmd: "echo after"}}
+	start := time.Now()
+	p.Reload(context.Background(), "")
+	if time.Since(start) > 2*time.Second {
+		t.Error("Reload not stopped after the timeout")
+	}
+	for _, v := range results {
+		if v.Name == "reload" && v.Timeout {
+			return
+		}
+	}
+	t.Error("Expected a timeout of the reload", results)
+}
+
+func TestCommand_Once(t *testing.T) {
+	dir, err := ioutil.TempDir("", "once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{{Type: "before", Cmd: "echo once >> runs", Shell: true, Once: true}, {Type: "after", Cmd: "echo every >> runs", Shell: true}}
+	p.Reload(context.Background(), "")
+	p.Reload(context.Background(), "")
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "runs")); string(b) != "once\nevery\nevery\n" {
+		t.Error("Unexpected runs", string(b))
+	}
+}
+
+func TestProject_Result(t *testing.T) {
+	var results []Response
+	r := Realize{}
+	r.Result = func(c Context) {
+		results = append(results, c.Response)
+	}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: Wdir()})
+	r.Projects[0].commands(context.Background(), []Command{{Cmd: "echo text"}, {Cmd: "false"}}, "before")
+	if len(results) != 2 || results[0].Out != "text\n" || results[1].Err == nil || results[1].Duration <= 0 {
+		t.Error("Unexpected results", results)
+	}
+}
+
+func TestCommand_Pipe(t *testing.T) {
+	c := Command{Pipe: []Command{{Cmd: "echo b a c"}, {Cmd: "tr ' ' '\n'", Shell: true}, {Cmd: "sort"}}}
+	r := c.exec(context.Background(), Wdir())
+	if r.Err != nil || r.Out != "a\nb\nc\n" || r.Name != "echo b a c | tr ' ' '\n' | sort" {
+		t.Error("Unexpected pipe result", r.Name, r.Out, r.Err)
+	}
+	c = Command{Pipe: []Command{{Cmd: "false"}, {Cmd: "cat"}}}
+	if r := c.exec(context.Background(), Wdir()); r.Err == nil {
+		t.Error("Expected the failure of a stage")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	c = Command{Pipe: []Command{{Cmd: "sleep 5"}, {Cmd: "cat"}}}
+	c.exec(ctx, Wdir())
+	if time.Since(start) > time.Second {
+		t.Error("Pipe not stopped")
+	}
+}
+
+func TestCommand_Hooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := Command{
+		Cmd:    "echo main >> order",
+		Shell:  true,
+		Before: []Command{{Cmd: "echo before >> order", Shell: true}},
+		After:  []Command{{Cmd: "echo after >> order", Shell: true}},
+	}
+	if r := c.exec(context.Background(), dir); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	order, _ := ioutil.ReadFile(filepath.Join(dir, "order"))
+	if string(order) != "before\nmain\nafter\n" {
+		t.Error("Unexpected order", string(order))
+	}
+	// the command doesn't run after a failed before hook
+	os.Remove(filepath.Join(dir, "order"))
+	c.Before = []Command{{Cmd: "false"}}
+	if r := c.exec(context.Background(), dir); r.Err == nil || !strings.Contains(r.Err.Error(), "before hook false") {
+		t.Error("Expected the failure of the before hook", r.Err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "order")); err == nil {
+		t.Error("Unexpected command after a failed before hook")
+	}
+	// the after hooks run after a stop
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	c = Command{Cmd: "sleep 5", After: []Command{{Cmd: "touch stopped"}}}
+	c.exec(ctx, dir)
+	if _, err := os.Stat(filepath.Join(dir, "stopped")); err != nil {
+		t.Error("Expected the after hook after a stop", err)
+	}
+	// the after hooks of a daemon run after its stop, not at its start
+	ctx, cancel = context.WithCancel(context.Background())
+	c = Command{Cmd: "sleep 5", Daemon: true, After: []Command{{Cmd: "touch daemon"}}}
+	r := c.exec(ctx, dir)
+	if _, err := os.Stat(filepath.Join(dir, "daemon")); err == nil {
+		t.Error("Unexpected after hook of a running daemon")
+	}
+	cancel()
+	<-r.exited
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(filepath.Join(dir, "daemon")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Error("Expected the after hook after the stop of the daemon", err)
+	}
+}
+
+func TestContainer_Args(t *testing.T) {
+	c := Container{Image: "golang", Volumes: []string{".:/src", "/tmp:/tmp"}, Workdir: "/src"}
+	args, stop := c.args([]string{"go", "test"}, "/app", "-t")
+	if len(stop) != 4 || strings.Join(stop[:3], " ") != "docker rm -f" {
+		t.Fatal("Unexpected stop", stop)
+	}
+	expected := []string{"docker", "run", "--rm", "--name", stop[3], "-v", filepath.Join("/app", ".") + ":/src", "-v", "/tmp:/tmp", "-t", "-w", "/src", "golang", "go", "test"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Error("Unexpected args", args)
+	}
+	c = Container{Name: "dev", Workdir: "/src"}
+	args, stop = c.args([]string{"go", "test"}, "/app")
+	if strings.Join(args[:6], " ") != "docker exec -w /src dev sh" || strings.Join(args[len(args)-2:], " ") != "go test" || !strings.Contains(args[7], "exec \"$0\" \"$@\"") {
+		t.Error("Unexpected args", args)
+	}
+	// the command of exec is killed by its pid inside the container
+	if strings.Join(stop[:5], " ") != "docker exec dev sh -c" || !strings.Contains(args[7], "echo $$ >") || !strings.Contains(stop[5], "kill -9") {
+		t.Error("Unexpected stop", stop)
+	}
+}
+
+func TestCommand_Exported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("DB=postgres\n"), 0644)
+	c := Command{EnvFile: ".env", keys: []string{"APP"}, vars: []string{"REALIZE_FAILED_CMD=go test", "APP=twice"}}
+	flags, err := c.exported(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the names, the values stay in the env of the docker client
+	if strings.Join(flags, " ") != "-e APP -e REALIZE_FAILED_CMD -e DB" {
+		t.Error("Unexpected flags", flags)
+	}
+}
+
+func TestProject_EnvSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "db")
+	ioutil.WriteFile(name, []byte("s3cret\n"), 0600)
+	os.Setenv("REALIZE_DB_PASS", "realize")
+	defer os.Unsetenv("REALIZE_DB_PASS")
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir, Secrets: map[string]Secret{"REALIZE_DB_PASS": {File: name}}}, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	p.environment()
+	if env := asStrings(p.environ()); !included(env, "REALIZE_DB_PASS=s3cret") || included(asStrings(r.Projects[1].environ()), "REALIZE_DB_PASS=s3cret") || os.Getenv("REALIZE_DB_PASS") != "realize" {
+		t.Error("Unexpected env", env)
+	}
+	// a secret not resolved is unset, not the previous value
+	os.Remove(name)
+	p.environment()
+	for _, e := range p.environ() {
+		if strings.HasPrefix(e, "REALIZE_DB_PASS=") {
+			t.Error("Unexpected secret", e)
+		}
+	}
+}
+
+func TestProject_EnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, ".env")
+	ioutil.WriteFile(name, []byte("REALIZE_A=file\nREALIZE_B=file\n"), Permission)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir, EnvFile: ".env", Env: map[string]string{"REALIZE_B": "env"}})
+	p := &r.Projects[0]
+	p.environment()
+	env := asStrings(p.environ())
+	if !included(env, "REALIZE_A=file") || !included(env, "REALIZE_B=env") || included(env, "REALIZE_B=file") || os.Getenv("REALIZE_A") != "" {
+		t.Error("Unexpected env", env)
+	}
+	// the variables removed from the file are removed, not the ones of realize
+	os.Setenv("REALIZE_E", "realize")
+	defer os.Unsetenv("REALIZE_E")
+	ioutil.WriteFile(name, []byte("REALIZE_C=file\nREALIZE_E=file\n"), Permission)
+	p.environment()
+	ioutil.WriteFile(name, []byte("REALIZE_C=file\n"), Permission)
+	p.environment()
+	if env = asStrings(p.environ()); included(env, "REALIZE_A=file") || !included(env, "REALIZE_C=file") || !included(env, "REALIZE_E=realize") {
+		t.Error("Env file not loaded again", env)
+	}
+	// the env of the project is the one of its commands
+	c, _ := p.fill(Command{Cmd: "printenv REALIZE_C"}, dir)
+	if r := c.exec(context.Background(), dir); r.Out != "file\n" {
+		t.Error("Unexpected command env", r.Out, r.Err)
+	}
+	c = Command{Cmd: "printenv REALIZE_D", EnvFile: "cmd.env"}
+	ioutil.WriteFile(filepath.Join(dir, "cmd.env"), []byte("REALIZE_D=cmd\n"), Permission)
+	if r := c.exec(context.Background(), dir); r.Out != "cmd\n" {
+		t.Error("Unexpected command env", r.Out, r.Err)
+	}
+}
+
+func TestCommand_When(t *testing.T) {
+	c := Command{When: []string{"*.scss"}, WhenRegex: []string{`^cmd/.*\.go$`}}
+	data := map[string]bool{
+		"assets/a.scss": true,
+		"cmd/main.go":   true,
+		"app/main.go":   false,
+	}
+	for i, v := range data {
+		if ok, err := c.when([]string{i}); err != nil || ok != v {
+			t.Error("Unexpected result", i, "expected", v, ok, err)
+		}
+	}
+	if ok, _ := c.when(nil); !ok {
+		t.Error("Expected a run without changed paths")
+	}
+	if ok, _ := c.when([]string{"app/main.go", "a.scss"}); !ok {
+		t.Error("Expected a run for one of the changed paths")
+	}
+}
+
+func TestProject_Affected(t *testing.T) {
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: "/app"})
+	p := &r.Projects[0]
+	p.Watcher.Scripts = []Command{
+		{Type: "before", Cmd: "sass", When: []string{"*.scss"}},
+		{Type: "after", Cmd: "./server"},
+		{Type: "after", Cmd: "go test ./cmd/...", WhenRegex: []string{"^cmd/"}},
+	}
+	if cmds := p.affected([]string{"/app/assets/a.scss"}); len(cmds) != 1 || cmds[0].Cmd != "sass" {
+		t.Error("Unexpected affected commands", cmds)
+	}
+	if cmds := p.affected([]string{"/app/assets/a.scss", "/app/cmd/main.go"}); len(cmds) != 2 {
+		t.Error("Unexpected affected commands", cmds)
+	}
+	if cmds := p.affected([]string{"/app/assets/a.scss", "/app/main.go"}); cmds != nil {
+		t.Error("Expected the whole reload for an unmatched file", cmds)
+	}
+	s := scope{cancels: make(map[string]context.CancelFunc)}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := s.restart(ctx, "sass")
+	second := s.restart(ctx, "sass")
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected the previous instance stopped")
+	}
+	cancel()
+	select {
+	case <-second.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected the instance stopped by the reload")
+	}
+}
+
+func TestProject_CommandsGraph(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	cmds := []Command{
+		{Name: "test", Cmd: "test -f build -a -f assets", Shell: true, Needs: []string{"build", "assets"}},
+		{Name: "build", Cmd: "touch build"},
+		{Name: "assets", Cmd: "touch assets"},
+		{Name: "deploy", Cmd: "touch deploy", Needs: []string{"test"}},
+		{Name: "broken", Cmd: "false"},
+		{Name: "skipped", Cmd: "touch skipped", Needs: []string{"broken"}},
+	}
+	p.commands(context.Background(), cmds, "before")
+	for _, v := range []string{"build", "assets", "deploy"} {
+		if _, err := os.Stat(filepath.Join(dir, v)); err != nil {
+			t.Error("Expected the command", v, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "skipped")); err == nil {
+		t.Error("Unexpected command after a failed need")
+	}
+	var results []Response
+	r.Result = func(c Context) {
+		results = append(results, c.Response)
+	}
+	p.commands(context.Background(), []Command{{Name: "a", Cmd: "true", Needs: []string{"b"}}, {Name: "b", Cmd: "true", Needs: []string{"a"}}}, "before")
+	if len(results) != 1 || results[0].Err == nil {
+		t.Error("Expected a cycle error", results)
+	}
+}
+
+func TestCommand_Lock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var mu sync.Mutex
+	var results []Response
+	r := Realize{}
+	r.Result = func(c Context) {
+		mu.Lock()
+		results = append(results, c.Response)
+		mu.Unlock()
+	}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir}, Project{parent: &r, Path: dir})
+	c := Command{Cmd: "test ! -f busy && touch busy && sleep 0.2 && rm busy", Shell: true, Lock: "build"}
+	var wg sync.WaitGroup
+	for i := range r.Projects {
+		wg.Add(1)
+		go func(p *Project) {
+			defer wg.Done()
+			p.commands(context.Background(), []Command{c}, "before")
+		}(&r.Projects[i])
+	}
+	wg.Wait()
+	if len(results) != 2 || results[0].Err != nil || results[1].Err != nil {
+		t.Error("Unexpected commands at the same time", results)
+	}
+	lock(context.Background(), "build")
+	defer unlock("build")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if lock(ctx, "build") {
+		t.Error("Unexpected lock already held")
+	}
+}
+
+func TestProject_Priority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "priority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir, Concurrency: 1})
+	p := &r.Projects[0]
+	cmds := []Command{
+		{Name: "lint", Cmd: "echo lint >> order", Shell: true},
+		{Name: "server", Cmd: "echo server >> order", Shell: true, Priority: 10},
+		{Name: "deploy", Cmd: "echo deploy >> order", Shell: true, Needs: []string{"server"}},
+	}
+	p.commands(context.Background(), cmds, "before")
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "order")); string(b) != "server\nlint\ndeploy\n" {
+		t.Error("Unexpected order", string(b))
+	}
+}
+
+func TestProject_FailFast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failfast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir, FailFast: true})
+	p := &r.Projects[0]
+	cmds := []Command{
+		{Name: "slow", Cmd: "sleep 5 && touch slow", Shell: true},
+		{Name: "broken", Cmd: "false"},
+		{Name: "deploy", Cmd: "touch deploy", Needs: []string{"slow"}},
+	}
+	start := time.Now()
+	p.commands(context.Background(), cmds, "before")
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the siblings stopped at the first failure")
+	}
+	for _, v := range []string{"slow", "deploy"} {
+		if _, err := os.Stat(filepath.Join(dir, v)); err == nil {
+			t.Error("Unexpected command after a failure", v)
+		}
+	}
+}
+
+func TestProject_Prev(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	cmds := []Command{
+		{Cmd: "echo first && exit 3", Shell: true, IgnoreErrors: true},
+		{Cmd: "echo \"$REALIZE_PREV_OUT $REALIZE_PREV_CODE\" > prev", Shell: true},
+	}
+	p.commands(context.Background(), cmds, "before")
+	prev, _ := ioutil.ReadFile(filepath.Join(dir, "prev"))
+	if string(prev) != "first 3\n" {
+		t.Error("Unexpected previous output", string(prev))
+	}
+	// an output over the size of an env string of linux, not printed
+	defer func(w io.Writer) { Output = w }(Output)
+	Output = ioutil.Discard
+	cmds = []Command{
+		{Cmd: "head -c 300000 /dev/zero | tr '\\0' a", Shell: true},
+		{Cmd: "printf %s \"$REALIZE_PREV_OUT\" | wc -c > prev", Shell: true},
+	}
+	p.commands(context.Background(), cmds, "before")
+	prev, _ = ioutil.ReadFile(filepath.Join(dir, "prev"))
+	if strings.TrimSpace(string(prev)) != strconv.Itoa(maxOutput+3) {
+		t.Error("Unexpected size of the previous output", string(prev))
+	}
+}
+
+func TestProject_Confirm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confirm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	cmds := []Command{
+		{Confirm: Confirm{Message: "drop the database?"}},
+		{Cmd: "touch dropped"},
+	}
+	answer := func(line string) {
+		for {
+			confirm.Lock()
+			waiting := confirm.c != nil
+			confirm.Unlock()
+			if waiting {
+				r.Shortcuts(strings.NewReader(line + "\n"))
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	go answer("n")
+	p.commands(context.Background(), cmds, "before")
+	if _, err := os.Stat(filepath.Join(dir, "dropped")); err == nil {
+		t.Error("Unexpected command after a refused confirmation")
+	}
+	if !strings.Contains(buf.String(), "drop the database? [y/N]") {
+		t.Error("Expected the question", buf.String())
+	}
+	go answer("y")
+	p.commands(context.Background(), cmds, "before")
+	if _, err := os.Stat(filepath.Join(dir, "dropped")); err != nil {
+		t.Error("Expected the command after the confirmation", err)
+	}
+	// default answer after the timeout
+	os.Remove(filepath.Join(dir, "dropped"))
+	cmds[0].Confirm.Timeout, cmds[0].Confirm.Default = 50*time.Millisecond, true
+	p.commands(context.Background(), cmds, "before")
+	if _, err := os.Stat(filepath.Join(dir, "dropped")); err != nil {
+		t.Error("Expected the default answer after the timeout", err)
+	}
+}
+
+func TestProject_Nested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := Realize{}
+	r.Projects = append(r.Projects, Project{parent: &r, Path: dir})
+	p := &r.Projects[0]
+	// a, then b in parallel with the sequence c and d, then e, c waits for the file of b
+	cmds := []Command{
+		{Name: "e", Cmd: "echo e >> order", Shell: true, Needs: []string{"b", "d"}},
+		{Name: "d", Cmd: "echo d >> order", Shell: true, Needs: []string{"c"}},
+		{Name: "c", Cmd: "until [ -f b ]; do sleep 0.01; done; echo c >> order", Shell: true, Needs: []string{"a"}},
+		{Name: "b", Cmd: "echo b >> order && touch b", Shell: true, Needs: []string{"a"}},
+		{Name: "a", Cmd: "echo a >> order", Shell: true},
+	}
+	p.commands(context.Background(), cmds, "before")
+	order, _ := ioutil.ReadFile(filepath.Join(dir, "order"))
+	if string(order) != "a\nb\nc\nd\ne\n" {
+		t.Error("Unexpected order", string(order))
+	}
+	// stopped once a file is written by a running command
+	stopped := func(name string) context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			for {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					cancel()
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		return ctx
+	}
+	// a stopped graph starts no command and waits for the running ones
+	os.Remove(filepath.Join(dir, "order"))
+	os.Remove(filepath.Join(dir, "b"))
+	cmds[2].Cmd = "until [ -f b ]; do sleep 0.01; done; echo c >> order && touch c && exec sleep 10"
+	start := time.Now()
+	p.commands(stopped("c"), cmds, "before")
+	if order, _ = ioutil.ReadFile(filepath.Join(dir, "order")); string(order) != "a\nb\nc\n" {
+		t.Error("Unexpected commands after the stop", string(order))
+	}
+	// the same for a sequence
+	os.Remove(filepath.Join(dir, "order"))
+	p.commands(stopped("started"), []Command{{Cmd: "touch started && exec sleep 10", Shell: true}, {Cmd: "echo next >> order", Shell: true}}, "before")
+	if _, err := os.Stat(filepath.Join(dir, "order")); err == nil {
+		t.Error("Unexpected command after the stop")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Expected the running commands to be killed by the stop")
+	}
+}
+
+func TestLimiter_Take(t *testing.T) {
+	now := time.Now()
+	l := limiter{RateLimit: RateLimit{Every: 2 * time.Second, Burst: 3}}
+	for i := 0; i < 3; i++ {
+		if wait := l.take(now); wait != 0 {
+			t.Error("Unexpected wait within the burst", i, wait)
+		}
+	}
+	if wait := l.take(now.Add(time.Second)); wait != time.Second {
+		t.Error("Unexpected wait over the burst", wait)
+	}
+	if wait := l.take(now.Add(2 * time.Second)); wait != 0 {
+		t.Error("Unexpected wait after the interval", wait)
+	}
+	l = limiter{}
+	for i := 0; i < 10; i++ {
+		if wait := l.take(now); wait != 0 {
+			t.Error("Unexpected wait without a limit", wait)
+		}
+	}
+}
+
+func TestTriggers_UnmarshalYAML(t *testing.T) {
+	var w Watch
+	if err := yaml.Unmarshal([]byte("triggers:\n  tmpl:\n  - command: make templates\n  assets/*.scss:\n  - command: sass\n"), &w); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Triggers) != 2 || w.Triggers[0].Paths[0] != "assets/*.scss" || w.Triggers[1].Exts[0] != "tmpl" || w.Triggers[1].Scripts[0].Cmd != "make templates" {
+		t.Error("Unexpected triggers", w.Triggers)
+	}
+	w = Watch{}
+	if err := yaml.Unmarshal([]byte("triggers:\n- extensions: [tmpl]\n  reload: true\n"), &w); err != nil || len(w.Triggers) != 1 || !w.Triggers[0].Reload {
+		t.Error("Unexpected triggers", w.Triggers, err)
+	}
+}
+
+// AsStrings returns a list of strings as a list of values
+func asStrings(list []string) []interface{} {
+	var out []interface{}
+	for _, v := range list {
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,16 @@
+// +build linux
+
+package realize
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommand_Pty(t *testing.T) {
+	c := Command{Cmd: "test -t 1 && echo terminal", Shell: true, Pty: true}
+	if r := c.exec(context.Background(), Wdir()); r.Err != nil || !strings.Contains(r.Out, "terminal") {
+		t.Error("Expected a terminal", r.Out, r.Err)
+	}
+}
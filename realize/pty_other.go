@@ -0,0 +1,19 @@
+// +build !linux
+
+package realize
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// openPty isn't supported out of linux
+func openPty() (master *os.File, slave *os.File, err error) {
+	return nil, nil, errors.New("pty isn't supported on this platform")
+}
+
+// setPty starts a command in its own process group
+func setPty(cmd *exec.Cmd) {
+	setGroup(cmd)
+}
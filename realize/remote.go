@@ -0,0 +1,164 @@
+package realize
+
+import (
+	"bufio"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Remote is used to watch a project over ssh, the project path is the local mount of the remote path
+type Remote struct {
+	Host string   `yaml:"host" json:"host"`
+	Path string   `yaml:"path" json:"path"`
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// sshWatcher receives the events of inotifywait running on a remote host
+// sshWatcher satisfies the FileWatcher interface
+type sshWatcher struct {
+	cmd    *exec.Cmd
+	remote string
+	local  string
+	events chan fsnotify.Event
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+// SSHWatcher starts inotifywait over ssh and returns a watcher of its events
+func SSHWatcher(r Remote, local string) (FileWatcher, error) {
+	if r.Host == "" || r.Path == "" {
+		return nil, errors.New("remote host and path are required")
+	}
+	args := append(append([]string{}, r.Args...), r.Host, "inotifywait", "-m", "-r", "-q",
+		"-e", "modify,attrib,create,delete,move", "--format", "'%e|%w%f'", shellQuote(r.Path))
+	w := &sshWatcher{
+		cmd:    exec.Command("ssh", args...),
+		remote: r.Path,
+		local:  local,
+		events: make(chan fsnotify.Event),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+	}
+	w.cmd.Env = inherited()
+	stdout, err := w.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := w.cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.cmd.Start(); err != nil {
+		return nil, err
+	}
+	// the pipes are read until their end before the wait of the command
+	var reads sync.WaitGroup
+	reads.Add(2)
+	go func() {
+		defer reads.Done()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if e, ok := parseInotify(scanner.Text(), w.remote, w.local); ok {
+				select {
+				case w.events <- e:
+				case <-w.done:
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		defer reads.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			select {
+			case w.errors <- errors.New(scanner.Text()):
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		reads.Wait()
+		// the remote watch stopped without a close, as by a lost connection
+		err := w.cmd.Wait()
+		if err == nil {
+			err = errors.New("ssh: the remote watch exited")
+		}
+		select {
+		case w.errors <- err:
+		case <-w.done:
+		}
+	}()
+	return w, nil
+}
+
+// Close stops the remote inotifywait
+func (w *sshWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		if w.cmd.Process != nil {
+			w.cmd.Process.Kill()
+		}
+	})
+	return nil
+}
+
+// Add is a no-op, the remote path is watched recursively
+func (w *sshWatcher) Add(name string) error {
+	return nil
+}
+
+// Remove is a no-op, the remote path is watched recursively
+func (w *sshWatcher) Remove(name string) error {
+	return nil
+}
+
+// Walk ssh
+func (w *sshWatcher) Walk(path string, init bool) string {
+	return path
+}
+
+// Errors returns the errors channel
+func (w *sshWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Events returns the events channel
+func (w *sshWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+// Parse a line of inotifywait, formatted as "EVENTS|path", to a local event
+func parseInotify(line, remote, local string) (e fsnotify.Event, ok bool) {
+	arr := strings.SplitN(line, "|", 2)
+	if len(arr) != 2 {
+		return e, false
+	}
+	rel, err := filepath.Rel(remote, arr[1])
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return e, false
+	}
+	e.Name = filepath.Join(local, filepath.FromSlash(rel))
+	for _, v := range strings.Split(arr[0], ",") {
+		switch v {
+		case "MODIFY":
+			e.Op |= fsnotify.Write
+		case "ATTRIB":
+			e.Op |= fsnotify.Chmod
+		case "CREATE", "MOVED_TO":
+			e.Op |= fsnotify.Create
+		case "DELETE":
+			e.Op |= fsnotify.Remove
+		case "MOVED_FROM":
+			e.Op |= fsnotify.Rename
+		}
+	}
+	return e, e.Op != 0
+}
@@ -0,0 +1,66 @@
+package realize
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInotify(t *testing.T) {
+	local := filepath.FromSlash("/home/user/app")
+	data := map[string]fsnotify.Event{
+		"MODIFY|/srv/app/main.go":      {Name: filepath.Join(local, "main.go"), Op: fsnotify.Write},
+		"CREATE,ISDIR|/srv/app/pkg":    {Name: filepath.Join(local, "pkg"), Op: fsnotify.Create},
+		"MOVED_FROM|/srv/app/pkg/a.go": {Name: filepath.Join(local, "pkg", "a.go"), Op: fsnotify.Rename},
+		"DELETE|/srv/app/a.go":         {Name: filepath.Join(local, "a.go"), Op: fsnotify.Remove},
+		"ATTRIB|/srv/app/a.go":         {Name: filepath.Join(local, "a.go"), Op: fsnotify.Chmod},
+	}
+	for i, v := range data {
+		e, ok := parseInotify(i, "/srv/app", local)
+		if !ok || e != v {
+			t.Error("Unexpected event", i, "expected", v, e)
+		}
+	}
+	for _, v := range []string{"MODIFY /srv/app/a.go", "MODIFY|/srv/other/a.go", "OPEN|/srv/app/a.go"} {
+		if _, ok := parseInotify(v, "/srv/app", local); ok {
+			t.Error("Unexpected event", v)
+		}
+	}
+}
+
+func TestSSHWatcher_Exit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("No shell script on Windows")
+	}
+	dir, err := ioutil.TempDir("", "ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// an ssh printing its args and losing the connection
+	ioutil.WriteFile(filepath.Join(dir, "ssh"), []byte("#!/bin/sh\necho \"$@\" >&2\nexit 255\n"), 0755)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	w, err := SSHWatcher(Remote{Host: "dev", Path: "/srv/it's"}, "/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var errs []string
+	for len(errs) < 2 {
+		select {
+		case err := <-w.Errors():
+			errs = append(errs, err.Error())
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the exit of the remote watch", errs)
+		}
+	}
+	if !strings.HasSuffix(errs[0], `'/srv/it'\''s'`) || errs[1] != "exit status 255" {
+		t.Error("Unexpected errors", errs)
+	}
+}
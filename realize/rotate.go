@@ -0,0 +1,122 @@
+package realize
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFile of the whole output, the one of realize and of the commands, rotated by size or by age
+type LogFile struct {
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// size in megabytes of the file before its rotation, unlimited by default
+	MaxSize int `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+	// age of the file before its rotation, as 24h, unlimited by default
+	MaxAge time.Duration `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+	// rotated files kept as name.1, the newest, to name.n, 3 by default
+	Backups int `yaml:"backups,omitempty" json:"backups,omitempty"`
+}
+
+// rotator writes a log file without the colors and rotates it, its errors are reported once to the terminal
+type rotator struct {
+	sync.Mutex
+	LogFile
+	term    io.Writer
+	file    *os.File
+	size    int64
+	created time.Time
+	failed  bool
+}
+
+// Tee returns the output written also to the log file, and the func closing the file
+func (l LogFile) tee(w io.Writer) (io.Writer, func(), error) {
+	if l.Name == "" {
+		return w, func() {}, nil
+	}
+	r := &rotator{LogFile: l, term: w}
+	if err := r.open(); err != nil {
+		return w, func() {}, err
+	}
+	return io.MultiWriter(w, r), r.close, nil
+}
+
+// Write appends to the file without the escape sequences of the colors, rotated before if too big or too old
+// an error of the file is reported once until a next write succeeds, and never returned to the output
+func (r *rotator) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	out := ansi.ReplaceAll(p, nil)
+	var err error
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(out)) > int64(r.MaxSize)<<20 || r.MaxAge > 0 && time.Since(r.created) > r.MaxAge {
+		err = r.rotate()
+	}
+	if err == nil {
+		var n int
+		n, err = r.file.Write(out)
+		r.size += int64(n)
+	}
+	if err != nil && !r.failed {
+		fmt.Fprintln(r.term, Red.Bold("log file not written: ")+err.Error())
+	}
+	r.failed = err != nil
+	return len(p), nil
+}
+
+// Open opens the file to append to it, its age is the one recorded at its creation
+func (r *rotator) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.Name), Permission); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.Name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, fi.Size()
+	// the creation time of the file in a sidecar, the changes of the file don't change its age
+	content, _ := ioutil.ReadFile(r.sidecar())
+	if r.created, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(content))); err != nil || fi.Size() == 0 {
+		r.created = time.Now()
+		ioutil.WriteFile(r.sidecar(), []byte(r.created.Format(time.RFC3339Nano)+"\n"), 0644)
+	}
+	return nil
+}
+
+// Sidecar returns the file of the creation time of the log file
+func (r *rotator) sidecar() string {
+	return filepath.Join(filepath.Dir(r.Name), "."+filepath.Base(r.Name)+".created")
+}
+
+// Rotate renames the file as name.1 and the older ones after it, the ones over the backups are removed
+func (r *rotator) rotate() error {
+	r.file.Close()
+	backups := r.Backups
+	if backups <= 0 {
+		backups = 3
+	}
+	os.Remove(fmt.Sprintf("%s.%d", r.Name, backups))
+	for i := backups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.Name, i), fmt.Sprintf("%s.%d", r.Name, i+1))
+	}
+	if err := os.Rename(r.Name, r.Name+".1"); err != nil {
+		r.open()
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the file
+func (r *rotator) close() {
+	r.Lock()
+	defer r.Unlock()
+	r.file.Close()
+}
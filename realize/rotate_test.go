@@ -0,0 +1,88 @@
+package realize
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFile_Tee(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "logs", "realize.log")
+	var buf bytes.Buffer
+	w, closer, err := LogFile{Name: name, MaxSize: 1, Backups: 2}.tee(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(Green.Bold("started") + "\n"))
+	content, _ := ioutil.ReadFile(name)
+	if string(content) != "started\n" || !strings.Contains(buf.String(), "started") {
+		t.Errorf("Unexpected log %q", content)
+	}
+	// a line over the size rotates the file
+	line := []byte(strings.Repeat("x", 1<<20-4) + "\n")
+	for i := 0; i < 3; i++ {
+		w.Write(line)
+	}
+	closer()
+	for _, v := range []string{name, name + ".1", name + ".2"} {
+		if _, err := os.Stat(v); err != nil {
+			t.Error("Expected the file", v)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); err == nil {
+		t.Error("Expected only two backups")
+	}
+	// the age of a file is the one of its creation, not of its last change
+	w, closer, err = LogFile{Name: name, MaxAge: time.Hour}.tee(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("appended\n"))
+	closer()
+	if content, _ := ioutil.ReadFile(name); !strings.HasSuffix(string(content), "x\nappended\n") {
+		t.Errorf("Unexpected log %q", content)
+	}
+	// a file older than the max age is rotated at the first write
+	ioutil.WriteFile(filepath.Join(dir, "logs", ".realize.log.created"), []byte(time.Now().Add(-2*time.Hour).Format(time.RFC3339Nano)), 0644)
+	w, closer, err = LogFile{Name: name, MaxAge: time.Hour}.tee(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("restarted\n"))
+	closer()
+	if content, _ := ioutil.ReadFile(name); string(content) != "restarted\n" {
+		t.Errorf("Unexpected log %q", content)
+	}
+}
+
+func TestRotator_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var term bytes.Buffer
+	r := &rotator{LogFile: LogFile{Name: filepath.Join(dir, "realize.log")}, term: &term}
+	if err := r.open(); err != nil {
+		t.Fatal(err)
+	}
+	// the errors of the file are reported once, not returned
+	r.file.Close()
+	for i := 0; i < 2; i++ {
+		if n, err := r.Write([]byte("line\n")); n != 5 || err != nil {
+			t.Error("Unexpected write", n, err)
+		}
+	}
+	if strings.Count(term.String(), "log file not written") != 1 {
+		t.Errorf("Unexpected report %q", term.String())
+	}
+}
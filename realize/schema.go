@@ -2,14 +2,38 @@ package realize
 
 import (
 	"errors"
+	"fmt"
 	"gopkg.in/urfave/cli.v2"
+	"gopkg.in/yaml.v2"
+	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 )
 
+// variables of the config values, as ${VAR} or ${VAR:-default}, $${VAR} is left as ${VAR}
+var variable = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// parameters of the named commands, as {{.name}}
+var parameter = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
 // Schema projects list
 type Schema struct {
 	Projects []Project `yaml:"schema" json:"schema"`
+	// projects of a top-level projects list, added after the schema ones
+	List []Project `yaml:"projects,omitempty" json:"projects,omitempty"`
+	// bases of the projects by name, used by the projects with "extends: name" and not run
+	Templates map[string]Project `yaml:"templates,omitempty" json:"templates,omitempty"`
+	// named commands, used by the commands of the projects
+	Scripts map[string]Command `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+	// ignored paths of all the projects, before their own ones
+	Ignore []string `yaml:"ignored_paths,omitempty" json:"ignored_paths,omitempty"`
+	// config files merged under this one, the maps are merged and the lists joined
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	// paths of the projects as read in the config, by their resolved path
+	origins map[string]string
 }
 
 // Add a project if unique
@@ -33,6 +57,398 @@ func (s *Schema) Remove(name string) error {
 	return errors.New("project not found")
 }
 
+// Interpolate expands the env variables in the values of the projects and of the named commands
+// an unset variable without a default is left as it is, for the shell of the commands
+func (s *Schema) Interpolate() {
+	interpolate(reflect.ValueOf(s).Elem(), substitute)
+}
+
+// Interpolate replaces the strings of a value by the ones returned by fn
+func interpolate(v reflect.Value, fn func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(fn(v.String()))
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			interpolate(v.Elem(), fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				interpolate(v.Field(i), fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			interpolate(v.Index(i), fn)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			interpolate(e, fn)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+// Substitute replaces the variables of a string, the default is used if a variable is unset or empty
+func substitute(s string) string {
+	return variable.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		g := variable.FindStringSubmatch(m)
+		v, ok := os.LookupEnv(g[1])
+		switch {
+		case ok && v != "":
+			return v
+		case g[2] != "":
+			return g[3]
+		case ok:
+			return v
+		}
+		return m
+	})
+}
+
+// Set overrides a value of all the projects as key=value, or appends to a list as key+=value
+// the key is the path of the config keys of a project as watcher.paths, the value is decoded as yaml
+func (s *Schema) Set(expr string) error {
+	i := strings.Index(expr, "=")
+	if i <= 0 {
+		return errors.New("set " + expr + ": expected key=value or key+=value")
+	}
+	key, add := strings.TrimSuffix(expr[:i], "+"), strings.HasSuffix(expr[:i], "+")
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(expr[i+1:]), &value); err != nil {
+		return errors.New("set " + key + ": " + strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	for k := range s.Projects {
+		y, err := yaml.Marshal(s.Projects[k])
+		if err != nil {
+			return err
+		}
+		var doc interface{}
+		if err = yaml.Unmarshal(y, &doc); err != nil {
+			return err
+		}
+		if doc, err = override(doc, strings.Split(key, "."), value, add); err != nil {
+			return errors.New("set " + key + ": " + err.Error())
+		}
+		if y, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+		var p Project
+		if err = yaml.UnmarshalStrict(y, &p); err != nil {
+			msg := []string{err.Error()}
+			if e, ok := err.(*yaml.TypeError); ok {
+				// the lines of the marshaled project are not the ones of a file
+				msg = e.Errors
+				for i, v := range msg {
+					if n := strings.Index(v, ": "); strings.HasPrefix(v, "line ") && n > 0 {
+						msg[i] = v[n+2:]
+					}
+				}
+			}
+			return errors.New("set " + key + ": " + strings.Join(msg, ", "))
+		}
+		s.Projects[k] = p
+	}
+	return nil
+}
+
+// Override sets a value at the path of the keys, the missing maps are added
+func override(doc interface{}, keys []string, value interface{}, add bool) (interface{}, error) {
+	if len(keys) == 0 {
+		if !add {
+			return value, nil
+		}
+		list, ok := doc.([]interface{})
+		if doc != nil && !ok {
+			return nil, errors.New("not a list")
+		}
+		if values, ok := value.([]interface{}); ok {
+			return append(list, values...), nil
+		}
+		return append(list, value), nil
+	}
+	m, ok := doc.(map[interface{}]interface{})
+	if doc != nil && !ok {
+		return nil, errors.New(keys[0] + " is not in a map")
+	}
+	if m == nil {
+		m = make(map[interface{}]interface{})
+	}
+	v, err := override(m[keys[0]], keys[1:], value, add)
+	if err != nil {
+		return nil, err
+	}
+	m[keys[0]] = v
+	return m, nil
+}
+
+// Check validates the values of the config, the errors name the wrong keys
+func (s *Schema) check() error {
+	var errs []string
+	var command func(key string, c Command, typed bool)
+	command = func(key string, c Command, typed bool) {
+		switch strings.ToLower(c.Type) {
+		case "before", "after", "error", "success":
+		case "":
+			if typed {
+				errs = append(errs, key+".type: missing, expected before, after, error or success")
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("%s.type: %q is not a valid command type, expected before, after, error or success", key, c.Type))
+		}
+		if def, ok := s.Scripts[c.Use]; c.Use != "" && !ok {
+			errs = append(errs, fmt.Sprintf("%s.use: %q is not a named command of the scripts", key, c.Use))
+		} else if c.Use != "" {
+			if err := def.arguments(c.Use, c.With); err != nil {
+				errs = append(errs, key+".with: "+err.Error())
+			}
+		} else if len(c.With) > 0 {
+			errs = append(errs, key+".with: the parameters of a command without use")
+		}
+		if c.Schedule != "" {
+			if _, err := parseCron(c.Schedule); err != nil {
+				errs = append(errs, key+".schedule: "+err.Error())
+			}
+		}
+		for i, v := range c.Pipe {
+			command(fmt.Sprintf("%s.pipe[%d]", key, i), v, false)
+		}
+		for i, v := range c.Before {
+			command(fmt.Sprintf("%s.before[%d]", key, i), v, false)
+		}
+		for i, v := range c.After {
+			command(fmt.Sprintf("%s.after[%d]", key, i), v, false)
+		}
+		for i, o := range []*Command{c.Windows, c.Darwin, c.Linux} {
+			if o != nil {
+				command(key+"."+[]string{"windows", "darwin", "linux"}[i], *o, false)
+			}
+		}
+	}
+	var names []string
+	for name := range s.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command("scripts."+name, s.Scripts[name], false)
+	}
+	// the names are the prefixes of the outputs
+	keys := make(map[string]string)
+	for i, p := range append(s.Projects[:len(s.Projects):len(s.Projects)], s.List...) {
+		key := fmt.Sprintf("schema[%d]", i)
+		if i >= len(s.Projects) {
+			key = fmt.Sprintf("projects[%d]", i-len(s.Projects))
+		}
+		if p.Name != "" {
+			if k, ok := keys[p.Name]; ok {
+				errs = append(errs, fmt.Sprintf("%s %s: name: %q is already the name of %s", key, p.Name, p.Name, k))
+			}
+			keys[p.Name] = key
+			key += " " + p.Name
+		}
+		for j, c := range p.Watcher.Scripts {
+			command(fmt.Sprintf("%s: watcher.scripts[%d]", key, j), c, c.Use == "")
+		}
+		for j, t := range p.Watcher.Triggers {
+			for k, c := range t.Scripts {
+				command(fmt.Sprintf("%s: watcher.triggers[%d].scripts[%d]", key, j, k), c, false)
+			}
+		}
+		names = names[:0]
+		for name := range p.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for j, c := range p.Profiles[name] {
+				command(fmt.Sprintf("%s: profiles.%s[%d]", key, name, j), c, c.Use == "")
+			}
+		}
+		if _, ok := p.Profiles[p.Profile]; p.Profile != "" && !ok {
+			errs = append(errs, fmt.Sprintf("%s: profile: %q is not one of the profiles", key, p.Profile))
+		}
+		if p.Verbosity != "" && p.Verbosity != "quiet" && p.Verbosity != "verbose" {
+			errs = append(errs, fmt.Sprintf("%s: verbosity: %q is not a valid verbosity, expected quiet or verbose", key, p.Verbosity))
+		}
+		if p.Watcher.Strategy != "" && p.Watcher.Strategy != "affected" {
+			errs = append(errs, fmt.Sprintf("%s: watcher.strategy: %q is not a valid strategy, expected affected", key, p.Watcher.Strategy))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// Join adds the projects of the projects list to the schema ones, a single list is written
+// the paths of the projects are resolved against the dir of the config, the written ones are the read ones
+func (s *Schema) join(dir string) {
+	s.Projects = append(s.Projects, s.List...)
+	s.List = nil
+	s.origins = make(map[string]string)
+	for k, p := range s.Projects {
+		if !filepath.IsAbs(p.Path) {
+			s.Projects[k].Path = filepath.Join(dir, p.Path)
+			s.origins[s.Projects[k].Path] = p.Path
+		}
+	}
+}
+
+// Relative returns the path of a project as written in the config, the one read or a path of the working directory relative to the config
+func (s Schema) relative(dir, path string) string {
+	if v, ok := s.origins[path]; ok {
+		return v
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return rel
+	}
+	return path
+}
+
+// Relative sets the paths of the projects of a config by a func, true if one is changed
+func relative(doc map[interface{}]interface{}, path func(string) string) bool {
+	changed := false
+	projects, _ := doc["schema"].([]interface{})
+	for _, v := range projects {
+		if m, ok := v.(map[interface{}]interface{}); ok {
+			if p, ok := m["path"].(string); ok {
+				if rel := path(p); rel == "" {
+					delete(m, "path")
+					changed = true
+				} else if rel != p {
+					m["path"], changed = rel, true
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// Resolve returns the named command used by a command, overridden by its non empty fields
+func (s *Schema) resolve(c Command) (Command, error) {
+	if c.Use != "" {
+		def, ok := s.Scripts[c.Use]
+		if !ok {
+			return c, errors.New("command " + c.Use + " not found")
+		}
+		if def.Use != "" {
+			return c, errors.New("command " + c.Use + " can't use another command")
+		}
+		if len(def.Params) > 0 || len(c.With) > 0 {
+			var err error
+			if def, err = def.instance(c.Use, c.With); err != nil {
+				return c, err
+			}
+		}
+		src, dst := reflect.ValueOf(c), reflect.ValueOf(&def).Elem()
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath != "" {
+				// unexported
+				continue
+			}
+			if f := src.Field(i); !reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+				dst.Field(i).Set(f)
+			}
+		}
+		def.Use, def.With = "", nil
+		c = def
+	}
+	c = c.platform()
+	for _, cmds := range []*[]Command{&c.Pipe, &c.Before, &c.After} {
+		if len(*cmds) == 0 {
+			continue
+		}
+		resolved := make([]Command, len(*cmds))
+		for i, v := range *cmds {
+			var err error
+			if resolved[i], err = s.resolve(v); err != nil {
+				return c, err
+			}
+		}
+		*cmds = resolved
+	}
+	return c, nil
+}
+
+// Arguments validates the arguments of the parameters of a named command, the ones without a default are required
+func (c Command) arguments(name string, with map[string]string) error {
+	var errs []string
+	for _, k := range sortedMap(with) {
+		if _, ok := c.Params[k]; !ok {
+			errs = append(errs, fmt.Sprintf("command %s has no parameter %s", name, k))
+		}
+	}
+	for _, k := range sortedMap(c.Params) {
+		if _, ok := with[k]; !ok && c.Params[k] == "" {
+			errs = append(errs, fmt.Sprintf("command %s: parameter %s missing", name, k))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Instance returns a copy of a named command with its parameters replaced by the arguments or their defaults
+// the other templates, as {{.File}}, are left to the run of the command
+func (c Command) instance(name string, with map[string]string) (Command, error) {
+	if err := c.arguments(name, with); err != nil {
+		return c, err
+	}
+	values := make(map[string]string)
+	for k, v := range c.Params {
+		values[k] = v
+	}
+	for k, v := range with {
+		values[k] = v
+	}
+	// a deep copy, the lists and the maps are shared with the named command
+	var i Command
+	y, err := yaml.Marshal(c)
+	if err == nil {
+		err = yaml.Unmarshal(y, &i)
+	}
+	if err != nil {
+		return c, err
+	}
+	i.Params = nil
+	interpolate(reflect.ValueOf(&i).Elem(), func(s string) string {
+		return parameter.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := values[parameter.FindStringSubmatch(m)[1]]; ok {
+				return v
+			}
+			return m
+		})
+	})
+	return i, nil
+}
+
+// SortedMap returns the keys of a map of strings in order
+func sortedMap(m map[string]string) []string {
+	var list []string
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
 // New create a project using cli fields
 func (s *Schema) New(c *cli.Context) Project {
 	name := filepath.Base(c.String("path"))
@@ -68,7 +484,7 @@ func (s *Schema) New(c *cli.Context) Project {
 		Args: params(c),
 		Watcher: Watch{
 			Paths:  []string{"/"},
-			Ignore: []string{".git", ".realize", "vendor"},
+			Ignore: append([]string{}, defaultIgnore...),
 			Exts:   []string{"go"},
 		},
 	}
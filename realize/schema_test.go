@@ -3,8 +3,12 @@ package realize
 import (
 	"flag"
 	"gopkg.in/urfave/cli.v2"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSchema_Add(t *testing.T) {
@@ -102,3 +106,151 @@ func TestSchema_Filter(t *testing.T) {
 		t.Error("Expected one project")
 	}
 }
+
+func TestSchema_Resolve(t *testing.T) {
+	s := Schema{Scripts: map[string]Command{
+		"test": {Cmd: "go test ./...", Path: "app", Timeout: time.Minute},
+	}}
+	c, err := s.resolve(Command{Use: "test", Type: "after", Path: "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cmd != "go test ./..." || c.Type != "after" || c.Path != "cmd" || c.Timeout != time.Minute || c.Use != "" {
+		t.Error("Unexpected command", c)
+	}
+	c, err = s.resolve(Command{Pipe: []Command{{Use: "test"}, {Cmd: "tparse"}}})
+	if err != nil || c.Pipe[0].Cmd != "go test ./..." {
+		t.Error("Unexpected pipe", c.Pipe, err)
+	}
+	if _, err := s.resolve(Command{Use: "missing"}); err == nil {
+		t.Error("Expected an error")
+	}
+	c, err = s.resolve(Command{Cmd: "./app", Before: []Command{{Use: "test"}}})
+	if err != nil || c.Before[0].Cmd != "go test ./..." {
+		t.Error("Unexpected hooks", c.Before, err)
+	}
+}
+
+func TestSchema_ResolveParams(t *testing.T) {
+	s := Schema{Scripts: map[string]Command{
+		"serve": {Cmd: "go run {{.pkg}} --port {{ .port }} {{.File}}", Params: map[string]string{"pkg": "", "port": "8080"}, Pipe: []Command{{Cmd: "tee {{.pkg}}.log"}}},
+	}}
+	c, err := s.resolve(Command{Use: "serve", With: map[string]string{"pkg": "./cmd/api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cmd != "go run ./cmd/api --port 8080 {{.File}}" || c.Pipe[0].Cmd != "tee ./cmd/api.log" || c.Params != nil || c.With != nil {
+		t.Error("Unexpected command", c)
+	}
+	c, err = s.resolve(Command{Use: "serve", With: map[string]string{"pkg": "./cmd/web", "port": "9090"}})
+	if err != nil || c.Cmd != "go run ./cmd/web --port 9090 {{.File}}" {
+		t.Error("Unexpected command", c, err)
+	}
+	// the named command is unchanged
+	if s.Scripts["serve"].Pipe[0].Cmd != "tee {{.pkg}}.log" {
+		t.Error("Unexpected named command", s.Scripts["serve"])
+	}
+	if _, err := s.resolve(Command{Use: "serve"}); err == nil || err.Error() != "command serve: parameter pkg missing" {
+		t.Error("Expected the missing parameter", err)
+	}
+	if _, err := s.resolve(Command{Use: "serve", With: map[string]string{"pkg": ".", "host": "localhost"}}); err == nil || err.Error() != "command serve has no parameter host" {
+		t.Error("Expected the unknown parameter", err)
+	}
+}
+
+func TestSchema_Interpolate(t *testing.T) {
+	os.Setenv("REALIZE_TEST_PORT", "8080")
+	os.Setenv("REALIZE_TEST_EMPTY", "")
+	defer os.Unsetenv("REALIZE_TEST_PORT")
+	defer os.Unsetenv("REALIZE_TEST_EMPTY")
+	s := Schema{Scripts: map[string]Command{"serve": {Cmd: "./app --port ${REALIZE_TEST_PORT}"}}}
+	s.Projects = []Project{{
+		Path: "${REALIZE_TEST_ROOT:-/srv}/app",
+		Env:  map[string]string{"DB": "${REALIZE_TEST_EMPTY:-localhost}:${REALIZE_TEST_PORT}"},
+		Watcher: Watch{
+			Paths:   []string{"${REALIZE_TEST_EMPTY}/src"},
+			Scripts: []Command{{Cmd: "echo ${REALIZE_PREV_OUT} $${REALIZE_TEST_PORT} $HOME", Pipe: []Command{{Cmd: "grep ${REALIZE_TEST_PORT}"}}}},
+		},
+	}}
+	s.Interpolate()
+	p := s.Projects[0]
+	if p.Path != "/srv/app" || p.Env["DB"] != "localhost:8080" || p.Watcher.Paths[0] != "/src" {
+		t.Error("Unexpected values", p.Path, p.Env, p.Watcher.Paths)
+	}
+	if c := p.Watcher.Scripts[0]; c.Cmd != "echo ${REALIZE_PREV_OUT} ${REALIZE_TEST_PORT} $HOME" || c.Pipe[0].Cmd != "grep 8080" {
+		t.Error("Unexpected commands", c.Cmd, c.Pipe[0].Cmd)
+	}
+	if s.Scripts["serve"].Cmd != "./app --port 8080" {
+		t.Error("Unexpected named command", s.Scripts["serve"].Cmd)
+	}
+}
+
+func TestSchema_Check(t *testing.T) {
+	s := Schema{Scripts: map[string]Command{"test": {Cmd: "go test ./..."}}}
+	s.Projects = []Project{{Name: "app", Profiles: map[string][]Command{"dev": {{Type: "after", Cmd: "./app"}}}}}
+	s.Projects[0].Watcher.Scripts = []Command{{Type: "before", Cmd: "go vet"}, {Use: "test"}, {Type: "After", Cmd: "./app", Schedule: "@hourly"}}
+	if err := s.check(); err != nil {
+		t.Error("Unexpected error", err)
+	}
+	s.Projects[0].Profile = "bench"
+	s.Projects[0].Watcher.Strategy = "all"
+	s.Projects[0].Watcher.Scripts = []Command{
+		{Type: "pararell", Cmd: "go vet"},
+		{Cmd: "go test"},
+		{Type: "after", Use: "missing", Schedule: "* *"},
+		{Type: "after", Cmd: "./app", Before: []Command{{Use: "missing"}}},
+		{Type: "after", Use: "test", With: map[string]string{"pkg": "./..."}},
+	}
+	s.List = []Project{{Name: "app"}}
+	err := s.check()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, v := range []string{
+		`schema[0] app: watcher.scripts[0].type: "pararell" is not a valid command type`,
+		"schema[0] app: watcher.scripts[1].type: missing",
+		`schema[0] app: watcher.scripts[2].use: "missing" is not a named command`,
+		"schema[0] app: watcher.scripts[2].schedule: schedule * *",
+		`schema[0] app: watcher.scripts[3].before[0].use: "missing"`,
+		"schema[0] app: watcher.scripts[4].with: command test has no parameter pkg",
+		`schema[0] app: profile: "bench" is not one of the profiles`,
+		`schema[0] app: watcher.strategy: "all" is not a valid strategy`,
+		`projects[0] app: name: "app" is already the name of schema[0]`,
+	} {
+		if !strings.Contains(err.Error(), v) {
+			t.Error("Expected", v, "in", err)
+		}
+	}
+}
+
+func TestSchema_Set(t *testing.T) {
+	s := Schema{Projects: []Project{{Name: "app", Args: []string{"--debug"}, Watcher: Watch{Paths: []string{"/"}, Debounce: 300 * time.Millisecond}}, {Name: "api"}}}
+	for _, v := range []string{
+		"args=[--port, 9000]",
+		"watcher.paths+=../shared",
+		"watcher.ignored_paths+=[tmp, dist]",
+		"commands.run.status=true",
+		`watcher.scripts+={type: after, command: "go test ./..."}`,
+	} {
+		if err := s.Set(v); err != nil {
+			t.Fatal(v, err)
+		}
+	}
+	p := s.Projects[0]
+	if !reflect.DeepEqual(p.Args, []string{"--port", "9000"}) || !reflect.DeepEqual(p.Watcher.Paths, []string{"/", "../shared"}) || p.Watcher.Debounce != 300*time.Millisecond {
+		t.Error("Unexpected project", p)
+	}
+	if !reflect.DeepEqual(s.Projects[1].Watcher.Ignore, []string{"tmp", "dist"}) || !s.Projects[1].Tools.Run.Status || s.Projects[1].Watcher.Scripts[0].Cmd != "go test ./..." {
+		t.Error("Unexpected project", s.Projects[1])
+	}
+	for expr, msg := range map[string]string{
+		"args":              "set args: expected key=value",
+		"watcher.pahts=[/]": "set watcher.pahts: field pahts not found in type realize.Watch",
+		"name.first=x":      "set name.first: first is not in a map",
+		"name+=x":           "set name: not a list",
+	} {
+		if err := s.Set(expr); err == nil || !strings.HasPrefix(err.Error(), msg) {
+			t.Error("Expected", msg, "instead", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package realize
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// SecretKey is the env variable of the key of the encrypted values
+const SecretKey = "REALIZE_SECRET_KEY"
+
+// Secret is the value of an env variable of the secrets of a project, a plain value, an encrypted one or a reference to a file or to an entry of the os keychain
+// the referenced values are read at each reload and never written in the config
+type Secret struct {
+	Value string `yaml:"-" json:"-"`
+	// file of the value, relative to the home dir with ~/
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+	// keychain entry as service/account, from the keychain of macOS or the secret service of linux
+	Keychain string `yaml:"keychain,omitempty" json:"keychain,omitempty"`
+	// value encrypted by realize encrypt, decrypted with the key of REALIZE_SECRET_KEY
+	Encrypted string `yaml:"encrypted,omitempty" json:"encrypted,omitempty"`
+}
+
+// reference fields of a secret, without the custom marshalers
+type reference Secret
+
+// UnmarshalYAML reads a plain value or a reference
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&s.Value); err == nil {
+		return nil
+	}
+	var ref reference
+	if err := unmarshal(&ref); err != nil {
+		return err
+	}
+	n := 0
+	for _, v := range []string{ref.File, ref.Keychain, ref.Encrypted} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("env: a secret is a file, a keychain entry or an encrypted value")
+	}
+	*s = Secret(ref)
+	return nil
+}
+
+// MarshalYAML writes a plain value or the reference, never the referenced value
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s.File == "" && s.Keychain == "" && s.Encrypted == "" {
+		return s.Value, nil
+	}
+	return reference(s), nil
+}
+
+// MarshalJSON writes a plain value or the reference, as the yaml one
+func (s Secret) MarshalJSON() ([]byte, error) {
+	v, _ := s.MarshalYAML()
+	return json.Marshal(v)
+}
+
+// Resolve returns the value of a secret, the one of the file without its trailing newline or the one of the keychain
+func (s Secret) resolve() (string, error) {
+	switch {
+	case s.File != "":
+		name := s.File
+		if strings.HasPrefix(name, "~/") {
+			name = filepath.Join(home(), name[2:])
+		}
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case s.Keychain != "":
+		entry := strings.SplitN(s.Keychain, "/", 2)
+		if len(entry) != 2 {
+			return "", errors.New("keychain " + s.Keychain + ": expected service/account")
+		}
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("security", "find-generic-password", "-s", entry[0], "-a", entry[1], "-w")
+		case "linux":
+			cmd = exec.Command("secret-tool", "lookup", "service", entry[0], "account", entry[1])
+		default:
+			return "", errors.New("keychain " + s.Keychain + ": not supported on " + runtime.GOOS)
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			return "", errors.New("keychain " + s.Keychain + ": " + err.Error())
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	case s.Encrypted != "":
+		return decrypt(s.Encrypted, os.Getenv(SecretKey))
+	}
+	return s.Value, nil
+}
+
+// Encrypt returns a value sealed by aes-gcm with the key, as base64 of the nonce and the sealed value
+func Encrypt(value, key string) (string, error) {
+	gcm, err := sealer(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// Decrypt returns an encrypted value, an error for a wrong key
+func decrypt(value, key string) (string, error) {
+	gcm, err := sealer(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted: not a value of realize encrypt")
+	}
+	out, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("encrypted: wrong key of " + SecretKey)
+	}
+	return string(out), nil
+}
+
+// NewKey returns a random key of the encrypted values, as base64 of 32 bytes
+func NewKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Sealer returns the aes-gcm of a key, a random one of 32 bytes as base64 and not a passphrase
+func sealer(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, errors.New("encrypted: " + SecretKey + " isn't set")
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("encrypted: " + SecretKey + " isn't a key of 32 bytes as base64, see realize encrypt --new-key")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Inherited returns the env of realize without the key of the encrypted values, never passed to the commands
+func inherited() []string {
+	var env []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, SecretKey+"=") {
+			env = append(env, e)
+		}
+	}
+	return env
+}
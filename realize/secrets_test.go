@@ -0,0 +1,111 @@
+package realize
+
+import (
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecret_UnmarshalYAML(t *testing.T) {
+	var env map[string]Secret
+	if err := yaml.UnmarshalStrict([]byte("DB_HOST: localhost\nDB_PASS: {file: ~/.secrets/db}\nAPI_KEY: {keychain: app/api}\n"), &env); err != nil {
+		t.Fatal(err)
+	}
+	if env["DB_HOST"].Value != "localhost" || env["DB_PASS"].File != "~/.secrets/db" || env["API_KEY"].Keychain != "app/api" {
+		t.Error("Unexpected env", env)
+	}
+	// the references are written, not their values
+	out, err := yaml.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "DB_HOST: localhost\n") || !strings.Contains(string(out), "DB_PASS:\n  file: ~/.secrets/db\n") {
+		t.Error("Unexpected yaml", string(out))
+	}
+	for _, v := range []string{"A: {file: a, keychain: b/c}", "A: {}", "A: {path: a}"} {
+		if err := yaml.UnmarshalStrict([]byte(v), &env); err == nil {
+			t.Error("Expected an error for", v)
+		}
+	}
+}
+
+func TestProject_Secrets(t *testing.T) {
+	var p Project
+	if err := yaml.Unmarshal([]byte("env:\n  DB_HOST: localhost\nsecrets:\n  DB_PASS: {file: ~/.secrets/db}\n"), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Env["DB_HOST"] != "localhost" || p.Secrets["DB_PASS"].File != "~/.secrets/db" {
+		t.Error("Unexpected project", p.Env, p.Secrets)
+	}
+	// the env has only plain values
+	if err := yaml.Unmarshal([]byte("env:\n  DB_PASS: {file: ~/.secrets/db}\n"), &Project{}); err == nil {
+		t.Error("Expected an error of a secret in the env")
+	}
+}
+
+func TestSecret_Resolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "db"), []byte("s3cret\n"), 0600)
+	if v, err := (Secret{File: filepath.Join(dir, "db")}).resolve(); err != nil || v != "s3cret" {
+		t.Error("Unexpected value", v, err)
+	}
+	if v, err := (Secret{Value: "plain"}).resolve(); err != nil || v != "plain" {
+		t.Error("Unexpected value", v, err)
+	}
+	if _, err := (Secret{File: filepath.Join(dir, "missing")}).resolve(); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if _, err := (Secret{Keychain: "app"}).resolve(); err == nil || !strings.Contains(err.Error(), "expected service/account") {
+		t.Error("Expected an error for the keychain entry", err)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	defer os.Setenv(SecretKey, os.Getenv(SecretKey))
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encrypt("s3cret", "passphrase"); err == nil {
+		t.Error("Expected an error for a passphrase")
+	}
+	sealed, err := Encrypt("s3cret", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Encrypt("s3cret", key); again == sealed {
+		t.Error("Expected a new nonce")
+	}
+	var env map[string]Secret
+	if err := yaml.UnmarshalStrict([]byte("DB_PASS: {encrypted: \""+sealed+"\"}\n"), &env); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(SecretKey, key)
+	if v, err := env["DB_PASS"].resolve(); err != nil || v != "s3cret" {
+		t.Error("Unexpected value", v, err)
+	}
+	for _, e := range (&Project{}).environ() {
+		if strings.HasPrefix(e, SecretKey+"=") {
+			t.Error("Unexpected key in the env of the commands")
+		}
+	}
+	other, _ := NewKey()
+	os.Setenv(SecretKey, other)
+	if _, err := env["DB_PASS"].resolve(); err == nil || err.Error() != "encrypted: wrong key of "+SecretKey {
+		t.Error("Expected the wrong key", err)
+	}
+	os.Setenv(SecretKey, "")
+	if _, err := env["DB_PASS"].resolve(); err == nil || !strings.Contains(err.Error(), "isn't set") {
+		t.Error("Expected the missing key", err)
+	}
+	if err := yaml.UnmarshalStrict([]byte("A: {encrypted: a, file: b}"), &env); err == nil {
+		t.Error("Expected an error for an encrypted file")
+	}
+}
@@ -32,16 +32,23 @@ type Server struct {
 	Host   string   `yaml:"host" json:"host"`
 }
 
+// Marshal returns the config as json, not swapped by a reload at the same time
+func (s *Server) marshal() ([]byte, error) {
+	swap.RLock()
+	defer swap.RUnlock()
+	return json.Marshal(s.Parent)
+}
+
 // Websocket projects
 func (s *Server) projects(c echo.Context) (err error) {
 	websocket.Handler(func(ws *websocket.Conn) {
-		msg, _ := json.Marshal(s.Parent)
+		msg, _ := s.marshal()
 		err = websocket.Message.Send(ws, string(msg))
 		go func() {
 			for {
 				select {
 				case <-s.Parent.Sync:
-					msg, _ := json.Marshal(s.Parent)
+					msg, _ := s.marshal()
 					err = websocket.Message.Send(ws, string(msg))
 					if err != nil {
 						break
@@ -56,9 +63,13 @@ func (s *Server) projects(c echo.Context) (err error) {
 			if err != nil {
 				break
 			} else {
+				swap.Lock()
 				err := json.Unmarshal([]byte(text), &s.Parent)
 				if err == nil {
 					s.Parent.Settings.Write(s.Parent)
+				}
+				swap.Unlock()
+				if err == nil {
 					break
 				}
 			}
@@ -179,6 +190,7 @@ func (s *Server) OpenURL() error {
 			return fmt.Errorf("operating system %q is not supported", runtime.GOOS)
 		}
 		cmd := exec.Command(open, url)
+		cmd.Env = inherited()
 		cmd.Stderr = &stderr
 		if err := cmd.Run(); err != nil {
 			return errors.New(stderr.String())
@@ -1,11 +1,18 @@
 package realize
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/user"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,8 +23,15 @@ const (
 	FileOut    = ".r.outputs.log"
 	FileErr    = ".r.errors.log"
 	FileLog    = ".r.logs.log"
+	Debounce   = 300 * time.Millisecond
+	Grace      = 5 * time.Second
+	// max time of the after hooks of a stopped command
+	HookTimeout = time.Minute
 )
 
+// ignored paths of the new projects, not suggested for removal by the lint if they match nothing
+var defaultIgnore = []string{".git", ".realize", "vendor"}
+
 // random string preference
 const (
 	letterIdxBits = 6                    // 6 bits to represent a letter index
@@ -29,21 +43,44 @@ const (
 // Settings defines a group of general settings and options
 type Settings struct {
 	Files     `yaml:"files,omitempty" json:"files,omitempty"`
-	FileLimit int32    `yaml:"flimit,omitempty" json:"flimit,omitempty"`
-	Legacy    Legacy   `yaml:"legacy" json:"legacy"`
-	Recovery  Recovery `yaml:"recovery,omitempty" json:"recovery,omitempty"`
+	FileLimit int32  `yaml:"flimit,omitempty" json:"flimit,omitempty"`
+	Watcher   string `yaml:"watcher,omitempty" json:"watcher,omitempty"`
+	// options of the output of all the projects, overridden by the ones of a project
+	Options `yaml:",inline" json:",inline"`
+	// format of the log, text or json as a json object by line
+	LogFormat string `yaml:"log_format,omitempty" json:"log_format,omitempty"`
+	// timestamp of the lines of the output, rfc3339, kitchen, relative to the start, none or a go time layout, 15:04:05 by default
+	Timestamp string `yaml:"timestamp,omitempty" json:"timestamp,omitempty"`
+	// file of the whole output, rotated
+	LogFile LogFile `yaml:"log_file,omitempty" json:"log_file,omitempty"`
+	// unknown keys of the config ignored with a warning, not an error
+	Lax bool `yaml:"-" json:"-"`
 }
 
 type Recovery struct {
-	Index  bool
-	Events bool
-	Tools  bool
+	Index  bool `yaml:"index,omitempty" json:"index,omitempty"`
+	Events bool `yaml:"events,omitempty" json:"events,omitempty"`
+	Tools  bool `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// Options of the watcher and of the output, set in the settings and overridden by the non empty ones of a project
+type Options struct {
+	// polling watcher and its interval
+	Legacy Legacy `yaml:"legacy,omitempty" json:"legacy,omitempty"`
+	// details of the watcher and of the commands printed
+	Recovery Recovery `yaml:"recovery,omitempty" json:"recovery,omitempty"`
+	// colors of the output, disabled with false, by default if the output is a terminal
+	Color *bool `yaml:"color,omitempty" json:"color,omitempty"`
+	// quiet prints only the outputs and the errors, verbose also the details of the recovery
+	Verbosity string `yaml:"verbosity,omitempty" json:"verbosity,omitempty"`
+	// max entries of each buffer of a project kept for the web ui, unlimited by default
+	BufferSize int `yaml:"buffer_size,omitempty" json:"buffer_size,omitempty"`
 }
 
 // Legacy is used to force polling and set a custom interval
 type Legacy struct {
-	Force    bool          `yaml:"force" json:"force"`
-	Interval time.Duration `yaml:"interval" json:"interval"`
+	Force    bool          `yaml:"force,omitempty" json:"force,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
 }
 
 // Files defines the files generated by realize
@@ -76,30 +113,441 @@ func (s *Settings) Remove(d string) error {
 	return err
 }
 
-// Read config file
+// Config returns the config file, the toml or the json one if there isn't the yaml one
+func Config() string {
+	if _, err := os.Stat(RFile); os.IsNotExist(err) {
+		for _, e := range []string{".toml", ".json"} {
+			name := strings.TrimSuffix(RFile, filepath.Ext(RFile)) + e
+			if _, err := os.Stat(name); err == nil {
+				return name
+			}
+		}
+	}
+	return RFile
+}
+
+// Discover returns the nearest config of a dir or of its parents, the default one or a realize.yaml, empty if there isn't one
+func Discover(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	base := strings.TrimSuffix(File, filepath.Ext(File))
+	for {
+		for _, v := range []string{File, base + ".toml", base + ".json", RPrefix + RExt} {
+			if info, err := os.Stat(filepath.Join(dir, v)); err == nil && !info.IsDir() {
+				return filepath.Join(dir, v)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Read config file, yaml, toml or json by its extension, a json config is decoded as yaml
+// the unknown keys, the wrong types and the invalid values are errors with their line or key
 func (s *Settings) Read(out interface{}) error {
+	name := Config()
 	// backward compatibility
-	if _, err := os.Stat(RFile); err != nil {
+	if _, err := os.Stat(name); err != nil {
 		return err
 	}
-	content, err := s.Stream(RFile)
-	if err == nil {
-		err = yaml.Unmarshal(content, out)
+	source, err := s.Stream(name)
+	if err != nil {
 		return err
 	}
-	return err
+	content, err := decode(name, source)
+	if err != nil {
+		return err
+	}
+	// the lines are known only for a yaml or json config without includes, the toml ones by their keys
+	toml := filepath.Ext(name) == ".toml"
+	known := !toml
+	var doc map[interface{}]interface{}
+	if yaml.Unmarshal(content, &doc) == nil {
+		changed := doc["include"] != nil
+		if changed {
+			base, err := fragments(name, doc, map[string]bool{})
+			if err != nil {
+				return err
+			}
+			doc, _ = merge(base, doc).(map[interface{}]interface{})
+		}
+		// the personal defaults, under the config and its includes
+		defaults, err := personal()
+		if err != nil {
+			return err
+		}
+		if defaults != nil {
+			doc, _ = merge(defaults, doc).(map[interface{}]interface{})
+			changed = true
+		}
+		inherited, err := inherit(doc)
+		if err != nil {
+			return lines(name, err)
+		}
+		expanded, err := expand(doc)
+		if err != nil {
+			return lines(name, err)
+		}
+		if changed || inherited || expanded {
+			if content, err = yaml.Marshal(doc); err != nil {
+				return err
+			}
+			known = false
+		}
+	}
+	if err = yaml.UnmarshalStrict(content, out); err != nil {
+		e, ok := err.(*yaml.TypeError)
+		if ok && !known {
+			// the lines of the yaml conversion, the ones of the keys of a toml config
+			for i, v := range e.Errors {
+				if n := strings.Index(v, ": "); strings.HasPrefix(v, "line ") && n > 0 {
+					e.Errors[i] = v[n+2:]
+					if line, err := strconv.Atoi(v[len("line "):n]); err == nil && toml {
+						if at := tomlLine(source, content, line); at > 0 {
+							e.Errors[i] = fmt.Sprintf("line %d: %s", at, v[n+2:])
+						}
+					}
+				}
+			}
+		}
+		// the unknown keys are only warnings if not strict, the wrong values are still errors
+		if !ok || !s.Lax || yaml.Unmarshal(content, out) != nil {
+			return lines(name, err)
+		}
+		for _, v := range strings.Split(lines(name, err).Error(), "\n") {
+			leveled("warn", Yellow.Bold("ignored: ")+v)
+		}
+	}
+	if c, ok := out.(interface{ check() error }); ok {
+		if err = c.check(); err != nil {
+			return lines(name, err)
+		}
+	}
+	if j, ok := out.(interface{ join(string) }); ok {
+		dir, _ := filepath.Abs(filepath.Dir(name))
+		j.join(dir)
+	}
+	return nil
+}
+
+// Decode returns a config as yaml, a toml one is converted by the yaml fields
+func decode(name string, content []byte) ([]byte, error) {
+	if filepath.Ext(name) != ".toml" {
+		return content, nil
+	}
+	v, err := fromTOML(content)
+	if err != nil {
+		return nil, lines(name, err)
+	}
+	if content, err = yaml.Marshal(v); err != nil {
+		return nil, lines(name, err)
+	}
+	return content, nil
+}
+
+// UserConfig returns the config of the personal defaults of the user, in the config dir of the user
+func UserConfig() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(home(), ".config")
+	}
+	return filepath.Join(dir, RPrefix, "config"+RExt)
+}
+
+// Home returns the home dir of the user
+func home() string {
+	if u, err := user.Current(); err == nil && u.HomeDir != "" {
+		return u.HomeDir
+	}
+	return os.Getenv("HOME")
+}
+
+// Personal returns the user config, nil if there isn't one, the projects are only set by the project configs
+func personal() (map[interface{}]interface{}, error) {
+	name := UserConfig()
+	content, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var doc map[interface{}]interface{}
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return nil, lines(name, err)
+	}
+	for _, k := range []string{"schema", "projects", "include"} {
+		if doc[k] != nil {
+			return nil, fmt.Errorf("%s: %s: not allowed in the user config, set it in the project config", name, k)
+		}
+	}
+	return doc, nil
+}
+
+// Fragments returns the included files of a config merged in order, the paths are relative to the config
+func fragments(name string, doc map[interface{}]interface{}, seen map[string]bool) (interface{}, error) {
+	abs, _ := filepath.Abs(name)
+	if seen[abs] {
+		return nil, errors.New(name + ": included again by its includes")
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+	includes, ok := doc["include"].([]interface{})
+	if !ok {
+		includes = []interface{}{doc["include"]}
+	}
+	var base interface{} = map[interface{}]interface{}{}
+	for _, v := range includes {
+		path, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: include: %v is not a file", name, v)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(name), path)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.New(name + ": include: " + err.Error())
+		}
+		if content, err = decode(path, content); err != nil {
+			return nil, err
+		}
+		var fragment map[interface{}]interface{}
+		if err = yaml.Unmarshal(content, &fragment); err != nil {
+			return nil, lines(path, err)
+		}
+		if fragment["include"] != nil {
+			nested, err := fragments(path, fragment, seen)
+			if err != nil {
+				return nil, err
+			}
+			// the includes of a fragment are relative to it
+			delete(fragment, "include")
+			fragment = merge(nested, fragment).(map[interface{}]interface{})
+		}
+		base = merge(base, fragment)
+	}
+	return base, nil
+}
+
+// Merge returns the values of a config over the ones of its includes
+// the maps are merged, the lists are joined without duplicates, the other values of the config win
+func merge(base, over interface{}) interface{} {
+	switch o := over.(type) {
+	case map[interface{}]interface{}:
+		if b, ok := base.(map[interface{}]interface{}); ok {
+			m := make(map[interface{}]interface{}, len(b)+len(o))
+			for k, v := range b {
+				m[k] = v
+			}
+			for k, v := range o {
+				m[k] = merge(b[k], v)
+			}
+			return m
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			list := append([]interface{}{}, b...)
+			for _, v := range o {
+				if !included(list, v) {
+					list = append(list, v)
+				}
+			}
+			return list
+		}
+	}
+	return over
+}
+
+// Subtract returns the values of a config without the ones of its includes, to write only its own values
+func subtract(v, base interface{}) interface{} {
+	switch m := v.(type) {
+	case map[interface{}]interface{}:
+		b, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return v
+		}
+		own := make(map[interface{}]interface{})
+		for k, e := range m {
+			be, ok := b[k]
+			if !ok {
+				own[k] = e
+			} else if !reflect.DeepEqual(e, be) {
+				if e = subtract(e, be); e != nil {
+					own[k] = e
+				}
+			}
+		}
+		if len(own) == 0 {
+			return nil
+		}
+		return own
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return v
+		}
+		var own []interface{}
+		for _, e := range m {
+			if !included(b, e) {
+				own = append(own, e)
+			}
+		}
+		if len(own) == 0 {
+			return nil
+		}
+		return own
+	}
+	return v
+}
+
+// Rebase returns the values of a project over the ones of its preset or of its base
+// the maps are merged, the lists and the other values of the project replace the ones of the base
+func rebase(base, over interface{}) interface{} {
+	o, ok := over.(map[interface{}]interface{})
+	b, isMap := base.(map[interface{}]interface{})
+	if !ok || !isMap {
+		return over
+	}
+	m := make(map[interface{}]interface{}, len(b)+len(o))
+	for k, v := range b {
+		m[k] = v
+	}
+	for k, v := range o {
+		m[k] = rebase(b[k], v)
+	}
+	return m
+}
+
+// Own returns the values of a project different from the ones of its preset or of its base, the lists are kept as a whole
+func own(v, base interface{}) interface{} {
+	m, ok := v.(map[interface{}]interface{})
+	b, isMap := base.(map[interface{}]interface{})
+	if !ok || !isMap {
+		return v
+	}
+	out := make(map[interface{}]interface{})
+	for k, e := range m {
+		be, found := b[k]
+		if !found {
+			out[k] = e
+		} else if !reflect.DeepEqual(e, be) {
+			if e = own(e, be); e != nil {
+				out[k] = e
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Included checks if a value is in a list
+func included(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
 }
 
-// Write config file
+// Lines prefixes each error of a config file with the file name, and the line if known as file:line: error
+func lines(file string, err error) error {
+	list := strings.Split(strings.TrimPrefix(err.Error(), "yaml: "), "\n")
+	if e, ok := err.(*yaml.TypeError); ok {
+		list = e.Errors
+	}
+	for i, v := range list {
+		v = strings.TrimSpace(v)
+		if n := strings.Index(v, ": "); strings.HasPrefix(v, "line ") && n > 0 {
+			list[i] = file + ":" + v[len("line "):n] + ": " + v[n+2:]
+		} else {
+			list[i] = file + ": " + v
+		}
+	}
+	return errors.New(strings.Join(list, "\n"))
+}
+
+// Write config file, in toml or json if the config is a toml or a json one
 func (s *Settings) Write(out interface{}) error {
+	name := Config()
 	y, err := yaml.Marshal(out)
 	if err != nil {
 		return err
 	}
-	s.Fatal(ioutil.WriteFile(RFile, y, Permission))
+	var v interface{}
+	if err = yaml.Unmarshal(y, &v); err != nil {
+		return err
+	}
+	if doc, ok := v.(map[interface{}]interface{}); ok {
+		// the values of the presets and of the includes are left to them, the ones of the user config are kept
+		// a shared value equal to a personal default is a value of the project config
+		changed := uninherit(doc)
+		changed = unexpand(doc) || changed
+		if rel, ok := out.(interface{ relative(string, string) string }); ok {
+			dir, _ := filepath.Abs(filepath.Dir(name))
+			changed = relative(doc, func(path string) string { return rel.relative(dir, path) }) || changed
+		}
+		if doc["include"] != nil {
+			base, err := fragments(name, doc, map[string]bool{})
+			if err != nil {
+				return err
+			}
+			if v = subtract(doc, base); v == nil {
+				v = map[interface{}]interface{}{}
+			}
+			changed = true
+		}
+		if changed {
+			if y, err = yaml.Marshal(v); err != nil {
+				return err
+			}
+		}
+	}
+	if e := filepath.Ext(name); e == ".toml" || e == ".json" {
+		if y, err = encode(name, v); err != nil {
+			return err
+		}
+	}
+	s.Fatal(ioutil.WriteFile(name, y, Permission))
 	return nil
 }
 
+// Encode returns a config as yaml, or as toml or json by the extension of the file
+func encode(name string, v interface{}) ([]byte, error) {
+	switch filepath.Ext(name) {
+	case ".toml":
+		return toTOML(v)
+	case ".json":
+		y, err := json.MarshalIndent(plain(v), "", "  ")
+		return append(y, '\n'), err
+	}
+	return yaml.Marshal(v)
+}
+
+// Plain converts the maps decoded from yaml to maps of strings, as required by json
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = plain(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = plain(e)
+		}
+	}
+	return v
+}
+
 // Stream return a byte stream of a given file
 func (s Settings) Stream(file string) ([]byte, error) {
 	_, err := os.Stat(file)
@@ -4,10 +4,24 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
+// TestMain runs the tests without the user config of the developer
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "xdg")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // Rand is used for generate a random string
 func random(n int) string {
 	src := rand.NewSource(time.Now().UnixNano())
@@ -83,6 +97,283 @@ func TestSettings_Read(t *testing.T) {
 	if err := s.Read(a); err != nil {
 		t.Fatal("Error unexpected", err)
 	}
+	// unknown keys, wrong types and invalid values
+	ioutil.WriteFile(d.Name(), []byte("schema:\n- name: app\n  watcher:\n    pararell: true\n    debounce: soon\n"), 0644)
+	err = s.Read(&Realize{})
+	if err == nil || !strings.Contains(err.Error(), d.Name()+":4: field pararell not found") || !strings.Contains(err.Error(), d.Name()+":5: cannot unmarshal") {
+		t.Error("Expected the errors with their line", err)
+	}
+	ioutil.WriteFile(d.Name(), []byte("schema:\n- name: app\n  watcher:\n    scripts:\n    - type: pararell\n      command: go vet\n"), 0644)
+	err = s.Read(&Realize{})
+	if err == nil || !strings.Contains(err.Error(), d.Name()+": schema[0] app: watcher.scripts[0].type: \"pararell\" is not a valid command type") {
+		t.Error("Expected the error of the type", err)
+	}
+	ioutil.WriteFile(d.Name(), []byte("schema:\n- name: app\n  watcher\n"), 0644)
+	if err = s.Read(&Realize{}); err == nil || !strings.HasPrefix(err.Error(), d.Name()+":") {
+		t.Error("Expected the syntax error with the file", err)
+	}
+	// a written config is read again
+	r := Realize{Schema: Schema{Projects: []Project{{Name: "app", Watcher: Watch{Exts: []string{"go"}, Scripts: []Command{{Type: "after", Cmd: "./app"}}}}}}}
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Read(&Realize{}); err != nil {
+		t.Error("Unexpected error of a written config", err)
+	}
+}
+
+func TestSettings_TOML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	name := filepath.Join(dir, ".realize.toml")
+	ioutil.WriteFile(name, []byte("[[schema]]\nname = \"app\"\n\n[schema.watcher]\nextensions = [\"go\"]\ndebounce = \"1s\"\n"), 0644)
+	if Config() != name {
+		t.Error("Unexpected config", Config())
+	}
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Name != "app" || r.Schema.Projects[0].Watcher.Debounce != time.Second {
+		t.Error("Unexpected config", r.Schema.Projects)
+	}
+	// written again in toml
+	r.Schema.Projects[0].Args = []string{"--debug"}
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(RFile); err == nil {
+		t.Error("Unexpected yaml config")
+	}
+	r = Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Args[0] != "--debug" {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+	ioutil.WriteFile(name, []byte("[[schema]]\nname = \"app\"\npararell = true\n"), 0644)
+	// the lines of the errors are the ones of the toml keys
+	if err := s.Read(&Realize{}); err == nil || err.Error() != name+":3: field pararell not found in type realize.Project" {
+		t.Error("Expected the unknown key", err)
+	}
+	ioutil.WriteFile(name, []byte("# config\n\n[[schema]]\nname = \"app\"\n\n[schema.watcher]\npaths = \"/\"\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || !strings.HasPrefix(err.Error(), name+":7: cannot unmarshal") {
+		t.Error("Expected the wrong type", err)
+	}
+	ioutil.WriteFile(name, []byte("[[schema]]\nname = app\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || err.Error() != name+":2: no value can start with a" {
+		t.Error("Expected the syntax error", err)
+	}
+}
+
+func TestSettings_JSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	name := filepath.Join(dir, ".realize.json")
+	ioutil.WriteFile(name, []byte("{\n\t\"schema\": [{\n\t\t\"name\": \"app\",\n\t\t\"watcher\": {\"extensions\": [\"go\"], \"debounce\": \"1s\"}\n\t}]\n}\n"), 0644)
+	if Config() != name {
+		t.Error("Unexpected config", Config())
+	}
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Watcher.Debounce != time.Second {
+		t.Error("Unexpected config", r.Schema.Projects)
+	}
+	// written again in json
+	r.Schema.Projects[0].Args = []string{"--debug"}
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(name)
+	if !strings.Contains(string(content), `"args": [`) {
+		t.Error("Expected a json config", string(content))
+	}
+	r = Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Args[0] != "--debug" {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+	ioutil.WriteFile(name, []byte("{\n  \"schema\": [{\n    \"pararell\": true\n  }]\n}\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || err.Error() != name+":3: field pararell not found in type realize.Project" {
+		t.Error("Expected the unknown key with its line", err)
+	}
+}
+
+func TestSettings_Include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	os.Mkdir(filepath.Join(dir, "shared"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "shared", "realize.common.toml"), []byte("include = [\"base.yaml\"]\nignored_paths = [\"vendor\"]\n\n[scripts.test]\ncommand = \"go test ./...\"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte("scripts:\n  lint:\n    command: golint\n"), 0644)
+	ioutil.WriteFile(RFile, []byte("include: [shared/realize.common.toml]\nignored_paths: [node_modules]\nscripts:\n  test:\n    command: go test -race ./...\nschema:\n- name: app\n"), 0644)
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(r.Schema.Ignore, ",") != "vendor,node_modules" || r.Schema.Scripts["test"].Cmd != "go test -race ./..." || r.Schema.Scripts["lint"].Cmd != "golint" {
+		t.Error("Unexpected merge", r.Schema.Ignore, r.Schema.Scripts)
+	}
+	// the values of the includes are not written
+	r.Schema.Projects[0].Args = []string{"--debug"}
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	for _, v := range []string{"golint", "vendor"} {
+		if strings.Contains(string(content), v) {
+			t.Error("Unexpected value of an include", v, string(content))
+		}
+	}
+	r = Realize{}
+	if err := s.Read(&r); err != nil || r.Schema.Scripts["lint"].Cmd != "golint" || r.Schema.Projects[0].Args[0] != "--debug" {
+		t.Error("Unexpected config", r.Schema, err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte("include: realize.common.toml\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || !strings.Contains(err.Error(), "included again") {
+		t.Error("Expected the cycle", err)
+	}
+	ioutil.WriteFile(RFile, []byte("include: [missing.yaml]\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || !strings.HasPrefix(err.Error(), RFile+": include:") {
+		t.Error("Expected the missing include", err)
+	}
+}
+
+func TestSettings_UserConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	name := filepath.Join(dir, "config", "realize", "config.yaml")
+	if UserConfig() != name {
+		t.Fatal("Unexpected user config", UserConfig())
+	}
+	os.MkdirAll(filepath.Dir(name), 0755)
+	ioutil.WriteFile(name, []byte("settings:\n  legacy:\n    force: true\n    interval: 2s\n  flimit: 1024\nserver:\n  open: true\n"), 0644)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	ioutil.WriteFile(RFile, []byte("settings:\n  flimit: 512\nschema:\n- name: app\n"), 0644)
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Settings.Legacy.Force || r.Settings.Legacy.Interval != 2*time.Second || r.Settings.FileLimit != 512 || !r.Server.Open {
+		t.Error("Unexpected settings", r.Settings, r.Server)
+	}
+	// a shared value equal to a personal default is kept in the project config
+	r.Settings.FileLimit = 1024
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadFile(RFile)
+	if !strings.Contains(string(content), "flimit: 1024") {
+		t.Error("Unexpected project config", string(content))
+	}
+	ioutil.WriteFile(name, []byte("schema:\n- name: mine\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || err.Error() != name+": schema: not allowed in the user config, set it in the project config" {
+		t.Error("Expected the projects of the user config", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	sub := filepath.Join(dir, "services", "auth")
+	os.MkdirAll(sub, 0755)
+	ioutil.WriteFile(filepath.Join(dir, "realize.yaml"), []byte("schema: []\n"), 0644)
+	if name := Discover(sub); name != filepath.Join(dir, "realize.yaml") {
+		t.Error("Unexpected config", name)
+	}
+	// the nearest one, the default name first
+	ioutil.WriteFile(filepath.Join(dir, "services", ".realize.toml"), []byte(""), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "services", "realize.yaml"), []byte(""), 0644)
+	if name := Discover(sub); name != filepath.Join(dir, "services", ".realize.toml") {
+		t.Error("Unexpected config", name)
+	}
+	os.Mkdir(filepath.Join(sub, ".realize.yaml"), 0755)
+	if name := Discover(sub); name != filepath.Join(dir, "services", ".realize.toml") {
+		t.Error("Expected the dirs to be skipped", name)
+	}
+}
+
+func TestSettings_Lax(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	ioutil.WriteFile(RFile, []byte("schema:\n- name: app\n  watcher:\n    ext: [go]\n"), 0644)
+	s := Settings{}
+	if err := s.Read(&Realize{}); err == nil || err.Error() != RFile+":4: field ext not found in type realize.Watch" {
+		t.Error("Expected the unknown key", err)
+	}
+	s.Lax = true
+	r := Realize{}
+	if err := s.Read(&r); err != nil || len(r.Schema.Projects) != 1 || r.Schema.Projects[0].Name != "app" {
+		t.Error("Unexpected config", r.Schema.Projects, err)
+	}
+	// the wrong values are still errors
+	ioutil.WriteFile(RFile, []byte("schema:\n- name: app\n  watcher:\n    paths: 1s\n    ext: [go]\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil {
+		t.Error("Expected the wrong value")
+	}
+}
+
+func TestSettings_Projects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := Settings{}
+	defer func(name string) { RFile = name }(RFile)
+	RFile = filepath.Join(dir, ".realize.yaml")
+	ioutil.WriteFile(RFile, []byte("schema:\n- name: app\nprojects:\n- name: api\n  path: cmd/api\n  watcher:\n    paths: [\"../..\"]\n- name: web\n  path: web\n"), 0644)
+	r := Realize{}
+	if err := s.Read(&r); err != nil {
+		t.Fatal(err)
+	}
+	// the paths are relative to the config, not to the working directory
+	if len(r.Schema.Projects) != 3 || r.Schema.Projects[1].Path != filepath.Join(dir, "cmd", "api") || r.Schema.Projects[2].Name != "web" || r.Schema.List != nil {
+		t.Error("Unexpected projects", r.Schema.Projects)
+	}
+	// and written as they are read
+	if err := s.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(RFile); !strings.Contains(string(content), "path: cmd/api\n") || strings.Contains(string(content), dir) {
+		t.Errorf("Unexpected config\n%s", content)
+	}
+	ioutil.WriteFile(RFile, []byte("projects:\n- name: api\n- name: api\n"), 0644)
+	if err := s.Read(&Realize{}); err == nil || !strings.Contains(err.Error(), `projects[1] api: name: "api" is already the name of projects[0]`) {
+		t.Error("Expected the duplicate name", err)
+	}
 }
 
 func TestSettings_Fatal(t *testing.T) {
@@ -1,9 +1,49 @@
 package realize
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/fatih/color"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// escape sequences of the colors
+var ansi = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// failures of the output of the json log, reported once
+var unwritten sync.Once
+
+// format of the log, text or json, and the timestamp of its lines, set by the settings at the start
+var (
+	logFormat string
+	timestamp string
+	started   time.Time
+)
+
+// Record is a line of the json log, a json object by line
+type Record struct {
+	Time  time.Time `json:"timestamp"`
+	Level string    `json:"level"`
+	// prefix of the messages of realize itself
+	Prefix string `json:"prefix,omitempty"`
+	// project of the message
+	Activity string `json:"activity,omitempty"`
+	// tool or command of the message
+	Command string `json:"command,omitempty"`
+	Message string `json:"message"`
+}
+
 var (
 	//Output writer
 	Output = color.Output
@@ -19,6 +59,19 @@ var (
 	Magenta = colorBase(color.FgHiMagenta)
 )
 
+// colors of the output without the settings, the ones of the terminal
+var terminal = struct{ off bool }{color.NoColor}
+
+// projects enabling the colors by their printed name, the other messages are printed without them if plain
+var tints = struct {
+	sync.RWMutex
+	plain   bool
+	colored map[string]bool
+}{}
+
+// colors of the names of the projects, as the ones of docker compose
+var palette = []colorBase{colorBase(color.FgHiCyan), Yellow, Green, Magenta, Blue, colorBase(color.FgCyan), colorBase(color.FgYellow), colorBase(color.FgGreen), colorBase(color.FgMagenta), colorBase(color.FgBlue)}
+
 // ColorBase type
 type colorBase color.Attribute
 
@@ -31,3 +84,258 @@ func (c colorBase) Regular(a ...interface{}) string {
 func (c colorBase) Bold(a ...interface{}) string {
 	return color.New(color.Attribute(c), color.Bold).Sprint(a...)
 }
+
+// Colors enables or disables the colors of the output, by default if the output is a terminal
+// a project can enable them over the settings, the messages of realize and of the other projects are then printed without them
+func (s Settings) colors(projects []Project) {
+	if logFormat == "json" {
+		return
+	}
+	enabled := false
+	for i := range projects {
+		if c := projects[i].Color; c != nil && *c {
+			enabled = true
+		}
+	}
+	color.NoColor = s.off() && !enabled
+	s.tints(projects)
+}
+
+// Tints sets the projects enabling the colors over the settings, as on a reload
+// the colors aren't enabled again during the output, a project enabling them after the start gets them at the next one
+func (s Settings) tints(projects []Project) {
+	tints.Lock()
+	defer tints.Unlock()
+	tints.colored = make(map[string]bool)
+	for i := range projects {
+		if c := projects[i].Color; c != nil && *c {
+			tints.colored[strings.ToUpper(projects[i].Name)] = true
+		}
+	}
+	tints.plain = s.off() && !color.NoColor
+}
+
+// Off checks if the settings disable the colors
+func (s Settings) off() bool {
+	if s.Color != nil {
+		return !*s.Color
+	}
+	return terminal.off
+}
+
+// Uncolored checks if a message of the log is printed without the colors, if its project doesn't enable them over the settings
+func uncolored(msg string) bool {
+	tints.RLock()
+	defer tints.RUnlock()
+	return tints.plain && !tints.colored[strings.ToUpper(parse(msg).Activity)]
+}
+
+// Uncolored checks if the output of a project is printed without the colors
+func (o Options) uncolored() bool {
+	if o.Color != nil {
+		return !*o.Color
+	}
+	tints.RLock()
+	defer tints.RUnlock()
+	return tints.plain
+}
+
+// words of the go time layouts, the other words of a layout are its mistakes
+var (
+	layoutWords = regexp.MustCompile(`January|Monday|Jan|Mon|MST|PM|pm|Z07(:?00){0,2}`)
+	words       = regexp.MustCompile(`[A-Za-z]{2}`)
+)
+
+// Format checks the format and the timestamp of the log
+func (s Settings) format() error {
+	var errs []string
+	switch s.LogFormat {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Sprintf("settings: log_format: %q is not a valid format, expected text or json", s.LogFormat))
+	}
+	if !validTimestamp(s.Timestamp) {
+		errs = append(errs, fmt.Sprintf("settings: timestamp: %q is not a valid timestamp, expected rfc3339, kitchen, relative, none or a go time layout", s.Timestamp))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// ValidTimestamp checks a timestamp of the settings, a go layout has at least an element and no other word, as rfc339
+func validTimestamp(v string) bool {
+	switch strings.ToLower(v) {
+	case "", "none", "rfc3339", "kitchen", "relative":
+		return true
+	}
+	if time.Date(2017, 11, 28, 22, 41, 37, 0, time.UTC).Format(v) == v {
+		// no element
+		return false
+	}
+	return !words.MatchString(layoutWords.ReplaceAllString(v, ""))
+}
+
+// Formats sets the format of the log, without colors if json
+func (s Settings) formats() error {
+	if err := s.format(); err != nil {
+		return err
+	}
+	logFormat, timestamp, started = s.LogFormat, s.Timestamp, time.Now()
+	if logFormat == "json" {
+		color.NoColor = true
+		// a closed output, as by | head, fails the writes instead of ending realize by a SIGPIPE
+		signal.Notify(make(chan os.Signal, 1), syscall.SIGPIPE)
+	}
+	return nil
+}
+
+// Clock returns the timestamp of a line, empty if none
+func clock(t time.Time) string {
+	switch strings.ToLower(timestamp) {
+	case "":
+		return t.Format("15:04:05")
+	case "none":
+		return ""
+	case "rfc3339":
+		return t.Format(time.RFC3339)
+	case "kitchen":
+		return t.Format(time.Kitchen)
+	case "relative":
+		// as +1m2.345s since the start
+		return "+" + (t.Sub(started) / time.Millisecond * time.Millisecond).String()
+	}
+	return t.Format(timestamp)
+}
+
+// Stamps checks if the lines of the outputs get a timestamp, only if set by the settings
+func stamps() bool {
+	return timestamp != "" && !strings.EqualFold(timestamp, "none")
+}
+
+// Stamped prefixes each line of an output with the name of its project and with its timestamp, only if set by the settings
+func stamped(out, prefix string, t time.Time) string {
+	ts := clock(t)
+	if timestamp == "" || ts == "" {
+		ts = ""
+	} else {
+		ts = Yellow.Regular("[") + ts + Yellow.Regular("]") + " "
+	}
+	if ts == "" && prefix == "" {
+		return out
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	for i := range lines {
+		lines[i] = ts + prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Tint returns the color of a project, the one of the palette of its index, the one of its name beyond the palette
+func tint(index int, name string) colorBase {
+	if index >= 0 && index < len(palette) {
+		return palette[index]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// lineWriter prints an output as it comes line by line, stamped as the outputs of its project or as records if json
+type lineWriter struct {
+	sync.Mutex
+	w        io.Writer
+	prefix   string
+	activity string
+	command  string
+	buf      []byte
+}
+
+// Write prints the complete lines, the last one is kept until its end
+// an output without a prefix or a timestamp is printed as it comes, as the prompts and the lines rewritten by \r
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.prefix == "" && !stamps() && logFormat != "json" {
+		return l.w.Write(p)
+	}
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(l.buf[:i])
+		l.buf = l.buf[i+1:]
+		if err := l.print(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush prints the last line without its end
+func (l *lineWriter) flush() {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.buf) > 0 {
+		l.print(string(l.buf))
+		l.buf = nil
+	}
+}
+
+// Print prints a line
+func (l *lineWriter) print(line string) error {
+	line = strings.TrimRight(line, "\r")
+	if logFormat == "json" {
+		rec := Record{Time: time.Now(), Level: "info", Activity: l.activity, Command: l.command, Message: ansi.ReplaceAllString(line, "")}
+		rec.emit(l.w)
+		return nil
+	}
+	_, err := fmt.Fprintln(l.w, stamped(line, l.prefix, time.Now()))
+	return err
+}
+
+// Parse returns the record of a line of the log, [name] : message, the name is the one of a project or of realize
+func parse(line string) Record {
+	rec := Record{Time: time.Now(), Level: "info", Message: strings.TrimSpace(ansi.ReplaceAllString(line, ""))}
+	if i := strings.Index(rec.Message, "] :"); strings.HasPrefix(rec.Message, "[") && i > 0 {
+		name := rec.Message[1:i]
+		rec.Message = strings.TrimSpace(rec.Message[i+3:])
+		if strings.EqualFold(name, RPrefix) {
+			rec.Prefix = RPrefix
+		} else {
+			rec.Activity = name
+		}
+	}
+	return rec
+}
+
+// Leveled prints a message of the log as log.Println, with its level as debug, info, warn or error in the json records
+func leveled(level string, v ...interface{}) {
+	if logFormat != "json" {
+		log.Println(v...)
+		return
+	}
+	rec := parse(fmt.Sprintln(v...))
+	rec.Level = level
+	rec.emit(Output)
+}
+
+// Emit prints a record, a failure of the output, as a closed pipe, is reported once to the stderr and doesn't stop realize
+func (rec Record) emit(w io.Writer) {
+	if err := rec.write(w); err != nil {
+		unwritten.Do(func() {
+			fmt.Fprintln(os.Stderr, "realize: log not written:", err)
+		})
+	}
+}
+
+// Write prints a record as a json line
+func (rec Record) write(w io.Writer) error {
+	out, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
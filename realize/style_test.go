@@ -2,9 +2,17 @@ package realize
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/fatih/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestStyle_Regular(t *testing.T) {
@@ -34,3 +42,161 @@ func TestStyle_Bold(t *testing.T) {
 		t.Error("Expected:", expected, "instead", result)
 	}
 }
+
+func TestSettings_Colors(t *testing.T) {
+	defer func(v bool) {
+		color.NoColor = v
+		tints.plain, tints.colored = false, nil
+	}(color.NoColor)
+	on, off := true, false
+	s := Settings{Options: Options{Color: &off}}
+	projects := []Project{{Name: "api"}, {Name: "web", Options: Options{Color: &on}}}
+	// the colors of the project over the settings, the other messages without them
+	s.colors(projects)
+	if color.NoColor || !uncolored("[API] : started") || uncolored("[WEB] : started") || !uncolored("[REALIZE] : started") {
+		t.Error("Unexpected colors of the messages", color.NoColor, tints.colored)
+	}
+	var buf bytes.Buffer
+	defer func(w io.Writer) { Output = w }(Output)
+	Output = &buf
+	LogWriter{}.Write([]byte(Yellow.Regular("[") + "API" + Yellow.Regular("]") + " : " + Green.Bold("started") + "\n"))
+	LogWriter{}.Write([]byte(Yellow.Regular("[") + "WEB" + Yellow.Regular("]") + " : " + Green.Bold("started") + "\n"))
+	lines := strings.Split(buf.String(), "\n")
+	if ansi.MatchString(lines[0]) || !ansi.MatchString(lines[1]) {
+		t.Errorf("Unexpected colors of the output %q", buf.String())
+	}
+	if !projects[0].Options.uncolored() || projects[1].Options.uncolored() {
+		t.Error("Unexpected colors of the projects")
+	}
+	// none of them
+	s.colors(projects[:1])
+	if !color.NoColor || uncolored("[API] : started") {
+		t.Error("Unexpected colors without a project enabling them")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	out := Output
+	Output, logFormat = &buf, "json"
+	defer func() { Output, logFormat = out, "" }()
+	LogWriter{}.Write([]byte(fmt.Sprintln("[REALIZE] :", Green.Bold("resumed"))))
+	p := Project{Name: "api", parent: &Realize{Sync: make(chan string, 2)}}
+	p.stamp("error", BufferOut{Type: "go build"}, fmt.Sprintln(p.pname(p.Name, 2), ":", "build failed"), "main.go:3: undefined: x\n")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected log\n%s", buf.String())
+	}
+	var recs []Record
+	for _, l := range lines {
+		var rec Record
+		if err := json.Unmarshal([]byte(l), &rec); err != nil || rec.Time.IsZero() {
+			t.Fatal("Unexpected record", l, err)
+		}
+		recs = append(recs, rec)
+	}
+	if recs[0].Prefix != "realize" || recs[0].Level != "info" || recs[0].Message != "resumed" {
+		t.Error("Unexpected record", recs[0])
+	}
+	if recs[1].Activity != "api" || recs[1].Command != "go build" || recs[1].Level != "error" || recs[1].Message != "build failed" {
+		t.Error("Unexpected record", recs[1])
+	}
+	if recs[2].Activity != "api" || recs[2].Message != "main.go:3: undefined: x" {
+		t.Error("Unexpected record", recs[2])
+	}
+	// the levels of the outputs of the tools and of the recovery
+	buf.Reset()
+	p.stamp("warn", BufferOut{Type: "go vet"}, "", "main.go:4: unreachable code\n")
+	leveled("debug", "Indexing", "main.go")
+	for i, level := range []string{"warn", "debug"} {
+		var rec Record
+		if err := json.Unmarshal([]byte(strings.Split(buf.String(), "\n")[i]), &rec); err != nil || rec.Level != level {
+			t.Error("Unexpected level", level, rec, err)
+		}
+	}
+	// a closed output is reported once
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+	defer func(f *os.File) { os.Stderr = f }(os.Stderr)
+	os.Stderr, Output, unwritten = stderr, closed{}, sync.Once{}
+	p.stamp("error", BufferOut{}, "", "failed\n")
+	LogWriter{}.Write([]byte("[REALIZE] : resumed\n"))
+	if content, _ := ioutil.ReadFile(stderr.Name()); strings.Count(string(content), "log not written") != 1 {
+		t.Errorf("Unexpected report %q", content)
+	}
+	if err := (Settings{LogFormat: "xml"}).format(); err == nil {
+		t.Error("Expected an invalid format")
+	}
+	for v, valid := range map[string]bool{"": true, "RFC3339": true, "relative": true, "15:04:05.000": true, time.RFC3339: true, time.UnixDate: true, "rfc339": false, "hh:mm:ss": false, "15h04 today": false} {
+		if err := (Settings{Timestamp: v}).format(); (err == nil) != valid {
+			t.Error("Unexpected timestamp", v, err)
+		}
+	}
+	if err := (Settings{LogFormat: "xml", Timestamp: "rfc339"}).format(); err == nil || strings.Count(err.Error(), "\n") != 1 || !strings.Contains(err.Error(), `timestamp: "rfc339"`) {
+		t.Error("Expected the errors of the format and of the timestamp", err)
+	}
+}
+
+// closed is an output of a closed pipe
+type closed struct{}
+
+func (closed) Write(p []byte) (int, error) {
+	return 0, syscall.EPIPE
+}
+
+func TestClock(t *testing.T) {
+	defer func() { timestamp = "" }()
+	now := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	started = now.Add(-1500 * time.Millisecond)
+	for format, expected := range map[string]string{"": "15:04:05", "none": "", "rfc3339": "2018-01-02T15:04:05Z", "kitchen": "3:04PM", "relative": "+1.5s", "2006-01-02": "2018-01-02"} {
+		timestamp = format
+		if v := clock(now); v != expected {
+			t.Error("Unexpected timestamp", format, v)
+		}
+	}
+	timestamp = "relative"
+	if v := ansi.ReplaceAllString(stamped("a\nb\n", "", now), ""); v != "[+1.5s] a\n[+1.5s] b" {
+		t.Errorf("Unexpected output %q", v)
+	}
+	// the outputs aren't stamped by default
+	timestamp = ""
+	if v := stamped("a\n", "", now); v != "a\n" {
+		t.Errorf("Unexpected output %q", v)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	// the palette in the order of the projects, the hash of the name beyond it
+	if tint(0, "api") != palette[0] || tint(1, "api") != palette[1] || tint(len(palette), "api") != tint(len(palette)+1, "api") {
+		t.Error("Unexpected colors of the projects")
+	}
+	var buf bytes.Buffer
+	// without a prefix and a timestamp, as it comes
+	raw := &lineWriter{w: &buf}
+	raw.Write([]byte("password: "))
+	raw.Write([]byte("\r50%\r100%"))
+	if buf.String() != "password: \r50%\r100%" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+	buf.Reset()
+	l := &lineWriter{w: &buf, prefix: "api | ", activity: "api", command: "go run ."}
+	l.Write([]byte("listening\r\nready"))
+	if buf.String() != "api | listening\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+	l.flush()
+	if buf.String() != "api | listening\napi | ready\n" {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+	buf.Reset()
+	logFormat = "json"
+	defer func() { logFormat = "" }()
+	l.Write([]byte("listening\n"))
+	var rec Record
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil || rec.Activity != "api" || rec.Command != "go run ." || rec.Message != "listening" {
+		t.Error("Unexpected record", buf.String(), err)
+	}
+}
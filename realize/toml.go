@@ -0,0 +1,155 @@
+package realize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml"
+	yaml3 "gopkg.in/yaml.v3"
+	"regexp"
+	"time"
+)
+
+// position of an error of go-toml, as (line, column)
+var tomlError = regexp.MustCompile(`^\((\d+), \d+\): `)
+
+// TOML decodes a toml document as maps of keys and values, the dates are decoded as strings
+func fromTOML(data []byte) (map[string]interface{}, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		// the line of the error as the yaml ones
+		return nil, errors.New(tomlError.ReplaceAllString(err.Error(), "line $1: "))
+	}
+	return plainTOML(tree.ToMap()).(map[string]interface{}), nil
+}
+
+// PlainTOML converts the dates and the times of a toml document to strings
+func plainTOML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = plainTOML(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = plainTOML(e)
+		}
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
+
+// TOMLLine returns the line in a toml document of a line of its yaml conversion, 0 if the value isn't one of the document
+func tomlLine(data, converted []byte, line int) int {
+	var doc yaml3.Node
+	if yaml3.Unmarshal(converted, &doc) != nil || len(doc.Content) == 0 {
+		return 0
+	}
+	keys := keyAt(doc.Content[0], line)
+	if keys == nil {
+		return 0
+	}
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return 0
+	}
+	// the position of the nearest key of the document
+	var pos toml.Position
+	var current interface{} = tree
+	for _, k := range keys {
+		switch t := current.(type) {
+		case *toml.Tree:
+			name, ok := k.(string)
+			if !ok || !t.Has(name) {
+				return pos.Line
+			}
+			pos, current = t.GetPositionPath([]string{name}), t.GetPath([]string{name})
+		case []*toml.Tree:
+			i, ok := k.(int)
+			if !ok || i >= len(t) {
+				return pos.Line
+			}
+			pos, current = t[i].Position(), t[i]
+		default:
+			return pos.Line
+		}
+	}
+	return pos.Line
+}
+
+// KeyAt returns the keys of the value of a line of a yaml node, nil if there isn't one
+func keyAt(n *yaml3.Node, line int) []interface{} {
+	switch n.Kind {
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Line == line || v.Line == line && v.Kind == yaml3.ScalarNode {
+				return []interface{}{k.Value}
+			}
+			if keys := keyAt(v, line); keys != nil {
+				return append([]interface{}{k.Value}, keys...)
+			}
+		}
+	case yaml3.SequenceNode:
+		for i, v := range n.Content {
+			if keys := keyAt(v, line); keys != nil {
+				return append([]interface{}{i}, keys...)
+			}
+			if v.Line == line {
+				return []interface{}{i}
+			}
+		}
+	}
+	return nil
+}
+
+// ToTOML encodes the maps of keys and values decoded from yaml as a toml document
+func toTOML(v interface{}) ([]byte, error) {
+	t, ok := table(v).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("toml: the document must be a table")
+	}
+	tree, err := toml.TreeFromMap(t)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err = toml.NewEncoder(&b).Indentation("").Encode(tree); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(b.Bytes(), "\n"), nil
+}
+
+// Table converts the maps decoded from yaml to maps of strings, without the null values
+func table(v interface{}) interface{} {
+	switch m := v.(type) {
+	case map[interface{}]interface{}:
+		t := make(map[string]interface{}, len(m))
+		for k, e := range m {
+			if e != nil {
+				t[fmt.Sprint(k)] = table(e)
+			}
+		}
+		return t
+	case map[string]interface{}:
+		t := make(map[string]interface{}, len(m))
+		for k, e := range m {
+			if e != nil {
+				t[k] = table(e)
+			}
+		}
+		return t
+	case []interface{}:
+		list := make([]interface{}, len(m))
+		for i, e := range m {
+			list[i] = table(e)
+		}
+		return list
+	case int:
+		return int64(m)
+	}
+	return v
+}
@@ -0,0 +1,119 @@
+package realize
+
+import (
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromTOML(t *testing.T) {
+	doc := `# config
+title = "app" # comment
+"quoted key" = 'C:\path'
+dotted.key = 1_000
+hex = 0xff
+float = 2.5
+flag = true
+date = 1979-05-27 07:32:00
+list = [
+  "a",
+  "b", # the last one
+]
+inline = { name = "x", list = [1, 2] }
+text = """
+first \
+  second\n"""
+
+[settings.legacy]
+force = true
+
+[[schema]]
+name = "one"
+
+[[schema.watcher.scripts]]
+type = "before"
+
+[[schema]]
+name = "two"
+`
+	v, err := fromTOML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"title":      "app",
+		"quoted key": `C:\path`,
+		"dotted":     map[string]interface{}{"key": int64(1000)},
+		"hex":        int64(255),
+		"float":      2.5,
+		"flag":       true,
+		"date":       "1979-05-27T07:32:00",
+		"list":       []interface{}{"a", "b"},
+		"inline":     map[string]interface{}{"name": "x", "list": []interface{}{int64(1), int64(2)}},
+		"text":       "first second\n",
+		"settings":   map[string]interface{}{"legacy": map[string]interface{}{"force": true}},
+		"schema": []interface{}{
+			map[string]interface{}{"name": "one", "watcher": map[string]interface{}{"scripts": []interface{}{map[string]interface{}{"type": "before"}}}},
+			map[string]interface{}{"name": "two"},
+		},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("Unexpected document %#v", v)
+	}
+	for doc, msg := range map[string]string{
+		"a = 1\na = 2":           "line 2: The following key was defined twice: a",
+		"a = \"open":             "line 1: unclosed string",
+		"a = 1 b = 2":            "line 1: parsing error",
+		"\n\n[a]\nb = [1, 2":     "line 4: unterminated array",
+		"[[a]]\n[a]":             "line 2: duplicated tables",
+		"[a]\nb = 1\n[a]\nc = 2": "line 3: duplicated tables",
+		"a = \"\\q\"":            "line 1: invalid escape",
+		"a = nope":               "line 1: no value can start with n",
+	} {
+		if _, err := fromTOML([]byte(doc)); err == nil || !strings.HasPrefix(err.Error(), msg) {
+			t.Error("Expected", msg, "instead", err)
+		}
+	}
+}
+
+func TestToTOML(t *testing.T) {
+	r := Realize{Schema: Schema{Projects: []Project{{
+		Name:    "app",
+		Path:    ".",
+		Args:    []string{"--port", "8080"},
+		Watcher: Watch{Exts: []string{"go"}, Paths: []string{"/"}, Debounce: Debounce, Scripts: []Command{{Type: "after", Cmd: "echo \"done\"\n"}}},
+	}}}}
+	y, err := yaml.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(y, &v); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := toTOML(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"[[schema]]\n", "args = [\"--port\", \"8080\"]\n", "[schema.watcher]\n", "debounce = \"300ms\"\n", "[[schema.watcher.scripts]]\ncommand = \"echo \\\"done\\\"\\n\"\n"} {
+		if !strings.Contains(string(doc), s) {
+			t.Error("Expected", s, "in", string(doc))
+		}
+	}
+	// decoded again as the same config
+	d, err := fromTOML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y, err = yaml.Marshal(d); err != nil {
+		t.Fatal(err)
+	}
+	var c Realize
+	if err := yaml.UnmarshalStrict(y, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Schema.Projects, r.Schema.Projects) {
+		t.Errorf("Unexpected config %#v", c.Schema.Projects)
+	}
+}
@@ -2,9 +2,9 @@ package realize
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
-	"log"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -94,7 +94,7 @@ func (t *Tools) Setup() {
 }
 
 // Exec a go tool
-func (t *Tool) Exec(path string, stop <-chan bool) (response Response) {
+func (t *Tool) Exec(ctx context.Context, path string) (response Response) {
 	if t.dir {
 		if filepath.Ext(path) != "" {
 			path = filepath.Dir(path)
@@ -120,13 +120,14 @@ func (t *Tool) Exec(path string, stop <-chan bool) (response Response) {
 		path = filepath.Dir(path)
 	}
 	if s := ext(path); s == "" || s == "go" {
-		if t.parent.parent.Settings.Recovery.Tools {
-			log.Println("Tool:", t.name, path, args)
+		if t.parent.recovery().Tools {
+			leveled("debug", "Tool:", t.name, path, args)
 		}
 		var out, stderr bytes.Buffer
 		done := make(chan error)
 		args = append(t.cmd, args...)
 		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = t.parent.environ()
 		if t.Dir != "" {
 			cmd.Dir, _ = filepath.Abs(t.Dir)
 		} else {
@@ -144,13 +145,14 @@ func (t *Tool) Exec(path string, stop <-chan bool) (response Response) {
 		go func() { done <- cmd.Wait() }()
 		// Wait a result
 		select {
-		case <-stop:
+		case <-ctx.Done():
 			// Stop running command
 			cmd.Process.Kill()
 		case err := <-done:
 			// Command completed
 			response.Name = t.name
 			if err != nil {
+				response.ExitCode = exitCode(err)
 				response.Err = errors.New(stderr.String() + out.String() + err.Error())
 			} else {
 				if t.Output {
@@ -163,12 +165,13 @@ func (t *Tool) Exec(path string, stop <-chan bool) (response Response) {
 }
 
 // Compile is used for build and install
-func (t *Tool) Compile(path string, stop <-chan bool) (response Response) {
+func (t *Tool) Compile(ctx context.Context, path string) (response Response) {
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	done := make(chan error)
 	args := append(t.cmd, t.Args...)
 	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = t.parent.environ()
 	if t.Dir != "" {
 		cmd.Dir, _ = filepath.Abs(t.Dir)
 	} else {
@@ -182,12 +185,13 @@ func (t *Tool) Compile(path string, stop <-chan bool) (response Response) {
 	// Wait a result
 	response.Name = t.name
 	select {
-	case <-stop:
+	case <-ctx.Done():
 		// Stop running command
 		cmd.Process.Kill()
 	case err := <-done:
 		// Command completed
 		if err != nil {
+			response.ExitCode = exitCode(err)
 			response.Err = errors.New(stderr.String() + err.Error())
 		}
 	}
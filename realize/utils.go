@@ -1,13 +1,37 @@
 package realize
 
 import (
+	"bytes"
 	"errors"
+	"go/scanner"
+	"go/token"
 	"gopkg.in/urfave/cli.v2"
+	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
 )
 
+// size of the env variables of an output, under the 128KB of a single env string of linux
+const maxOutput = 64 << 10
+
+// signals by name, used to stop the commands
+var signals = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGKILL": os.Kill,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
 // Params parse one by one the given argumentes
 func params(params *cli.Context) []string {
 	argsN := params.NArg()
@@ -22,14 +46,70 @@ func params(params *cli.Context) []string {
 }
 
 // Split each arguments in multiple fields
-func split(args, fields []string) []string {
-	for _, arg := range fields {
-		arr := strings.Fields(arg)
+func split(args, list []string) []string {
+	for _, arg := range list {
+		arr := fields(arg)
 		args = append(args, arr...)
 	}
 	return args
 }
 
+// Fields splits a command line on spaces, except the quoted ones
+// a backslash escapes a space or a quote, backslashes in paths are kept
+func fields(line string) []string {
+	var args []string
+	var arg []rune
+	var quote rune
+	started := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\' && i+1 < len(runes) && (quote == 0 && strings.ContainsRune(" \t'\"", runes[i+1]) || quote == '"' && runes[i+1] == '"'):
+			i++
+			arg = append(arg, runes[i])
+			started = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg = append(arg, c)
+		case c == '\'' || c == '"':
+			quote = c
+			started = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if started {
+				args = append(args, string(arg))
+				arg, started = nil, false
+			}
+		default:
+			arg = append(arg, c)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// ShellQuote quotes an arg for a posix shell, the single quotes of the arg included
+func shellQuote(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// Truncated returns the end of an output too long for an env variable, the end of an output is the one of its errors
+func truncated(out string) string {
+	if len(out) <= maxOutput {
+		return out
+	}
+	out = out[len(out)-maxOutput:]
+	// not in the middle of a rune
+	for len(out) > 0 && !utf8.RuneStart(out[0]) {
+		out = out[1:]
+	}
+	return "..." + out
+}
+
 // Duplicates check projects with same name or same combinations of main/path
 func duplicates(value Project, arr []Project) (Project, error) {
 	for _, val := range arr {
@@ -65,6 +145,94 @@ func replace(a []string, b string) []string {
 	return a
 }
 
+// ExitCode returns the exit status of a failed command, -1 if it didn't exit
+func exitCode(err error) int {
+	if e, ok := err.(*exec.ExitError); ok {
+		if status, ok := e.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+	return -1
+}
+
+// Dotenv parses the variables of an env file, one KEY=value per line
+func dotenv(name string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	envs := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, errors.New("invalid line in " + name + ": " + line)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch {
+		case len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"':
+			if v, err := strconv.Unquote(value); err == nil {
+				value = v
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		case len(value) > 1 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// inline comments
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		envs[key] = value
+	}
+	return envs, nil
+}
+
+// Buffer is a concurrency safe output buffer, the writes are discarded once it's closed
+type buffer struct {
+	sync.Mutex
+	b      bytes.Buffer
+	closed bool
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	if !b.closed {
+		b.b.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *buffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.b.String()
+}
+
+// Close discards the content and the next writes
+func (b *buffer) Close() {
+	b.Lock()
+	defer b.Unlock()
+	b.closed = true
+	b.b.Reset()
+}
+
+// IsAddr checks if a string is a host:port address
+func isAddr(s string) bool {
+	_, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return false
+	}
+	_, err = strconv.Atoi(port)
+	return err == nil
+}
+
 // Wdir return current working directory
 func Wdir() string {
 	dir, err := os.Getwd()
@@ -73,3 +241,105 @@ func Wdir() string {
 	}
 	return dir
 }
+
+// Normalize strips whitespace from a file content, and comments from go sources
+func normalize(ext string, content []byte) []byte {
+	var out bytes.Buffer
+	if ext != "go" {
+		for _, v := range bytes.Fields(content) {
+			out.Write(v)
+		}
+		return out.Bytes()
+	}
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(content))
+	s.Init(file, content, nil, 0)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		// semicolons depend on the line breaks
+		if tok == token.SEMICOLON {
+			continue
+		}
+		if lit == "" {
+			out.WriteString(tok.String())
+		} else {
+			out.WriteString(lit)
+		}
+		out.WriteByte(' ')
+	}
+	return out.Bytes()
+}
+
+// Glob reports whether a slash separated name matches a pattern, "**" matches any number of dirs
+func glob(pattern, name string) bool {
+	return globSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// GlobTree reports whether a name or one of its parent dirs matches a pattern
+func globTree(pattern, name string) bool {
+	for name != "." && name != "/" && name != "" {
+		if glob(pattern, name) {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
+
+// HasMeta checks if a path contains glob characters or ends with "..."
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[") || strings.HasSuffix(path, "...")
+}
+
+// CleanGlob returns a slash separated pattern relative to the project path, a trailing "..." is the same as "**"
+func cleanGlob(pattern string) string {
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "/")
+	if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+		pattern = strings.TrimSuffix(pattern, "...") + "**"
+	}
+	return pattern
+}
+
+// GlobBase returns the leading dirs of a pattern without glob characters
+func globBase(pattern string) string {
+	if !hasMeta(pattern) {
+		return pattern
+	}
+	var base []string
+	for _, s := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if hasMeta(s) {
+			break
+		}
+		base = append(base, s)
+	}
+	return filepath.FromSlash(strings.Join(base, "/"))
+}
+
+// Match a list of path segments
+func globSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if globSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -1,11 +1,16 @@
 package realize
 
 import (
+	"bytes"
 	"flag"
 	"gopkg.in/urfave/cli.v2"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestParams(t *testing.T) {
@@ -71,3 +76,117 @@ func TestExt(t *testing.T) {
 	}
 
 }
+
+func TestFields(t *testing.T) {
+	data := map[string][]string{
+		"go build  main.go":                     {"go", "build", "main.go"},
+		`go build -ldflags "-X main.version=1"`: {"go", "build", "-ldflags", "-X main.version=1"},
+		`echo 'a "b"' "c \"d\"" e\ f`:           {"echo", `a "b"`, `c "d"`, "e f"},
+		`echo "" C:\go\bin`:                     {"echo", "", `C:\go\bin`},
+	}
+	for i, v := range data {
+		if result := fields(i); !reflect.DeepEqual(result, v) {
+			t.Error("Unexpected result", i, "expected", v, result)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	data := map[[2]string]bool{
+		{"*.go", "main.go"}:                        true,
+		{"*.go", "a/main.go"}:                      false,
+		{"**/*.go", "main.go"}:                     true,
+		{"**/*.go", "a/b/main.go"}:                 true,
+		{"a/**", "a/b/c"}:                          true,
+		{"a/**/c", "a/c"}:                          true,
+		{"a/**/c", "a/b/d"}:                        false,
+		{"internal/*_gen.go", "internal/a_gen.go"}: true,
+	}
+	for i, v := range data {
+		if glob(i[0], i[1]) != v {
+			t.Error("Unexpected result", i, "expected", v)
+		}
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	data := map[string]string{
+		"/":            "/",
+		"src":          "src",
+		"../**/*.go":   "..",
+		"src/**/*.go":  "src",
+		"**/*.go":      "",
+		"a/b/*_gen.go": filepath.FromSlash("a/b"),
+	}
+	for i, v := range data {
+		if globBase(i) != v {
+			t.Error("Unexpected base", i, "expected", v, globBase(i))
+		}
+	}
+}
+
+func TestGlobTree(t *testing.T) {
+	if !globTree("vendor/**", "vendor/a/b.go") || !globTree("**/testdata", "a/testdata/b.go") {
+		t.Error("Expected a match")
+	}
+	if globTree("**/testdata", "a/b.go") {
+		t.Error("Unexpected match")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a := normalize("go", []byte("package main\n\n// comment\nfunc main() { println(\"a b\") }\n"))
+	b := normalize("go", []byte("package main\nfunc main() {\n\t/* comment */\n\tprintln(\"a b\")\n}"))
+	c := normalize("go", []byte("package main\nfunc main() { println(\"a  b\") }\n"))
+	if !bytes.Equal(a, b) {
+		t.Error("Expected the same content", string(a), string(b))
+	}
+	if bytes.Equal(a, c) {
+		t.Error("Expected a different content", string(a), string(c))
+	}
+	if !bytes.Equal(normalize("css", []byte("a {\n  color: red;\n}")), normalize("css", []byte("a{color:red;}"))) {
+		t.Error("Expected the same content")
+	}
+}
+
+func TestDotenv(t *testing.T) {
+	f, err := ioutil.TempFile("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# comment\nA=1\nexport B = two words # comment\nC=\"x\\ny\"\nD='# literal'\n\n")
+	f.Close()
+	envs, err := dotenv(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1", "B": "two words", "C": "x\ny", "D": "# literal"}
+	if !reflect.DeepEqual(envs, expected) {
+		t.Error("Unexpected env", envs)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	data := map[string]string{
+		"/srv/app":       "'/srv/app'",
+		"/srv/my app":    "'/srv/my app'",
+		"/srv/it's":      `'/srv/it'\''s'`,
+		"/srv/$(rm -rf)": "'/srv/$(rm -rf)'",
+	}
+	for i, v := range data {
+		if q := shellQuote(i); q != v {
+			t.Error("Unexpected quote", i, q)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	if out := truncated("error"); out != "error" {
+		t.Error("Unexpected output", out)
+	}
+	out := truncated(strings.Repeat("é", maxOutput))
+	if len(out) > maxOutput+3 || !strings.HasPrefix(out, "...é") || !utf8.ValidString(out) {
+		t.Error("Unexpected output", len(out), out[:10])
+	}
+}
@@ -2,7 +2,12 @@
 
 package realize
 
-import "strings"
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
 
 // isHidden check if a file or a path is hidden
 func isHidden(path string) bool {
@@ -14,3 +19,32 @@ func isHidden(path string) bool {
 	}
 	return false
 }
+
+// shell returns the args to run a command line through the system shell
+func shell(cmd string) []string {
+	return []string{"sh", "-c", cmd}
+}
+
+// setGroup starts a command in its own process group, so its children can be stopped together
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// startGroup starts a command, its process group is set by setGroup
+func startGroup(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// signalGroup sends a signal to the process group of a command
+func signalGroup(process *os.Process, sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok && syscall.Kill(-process.Pid, s) == nil {
+		return nil
+	}
+	return process.Signal(sig)
+}
+
+// killGroup kills the process group of a command
+func killGroup(process *os.Process) error {
+	syscall.Kill(-process.Pid, syscall.SIGKILL)
+	return process.Kill()
+}
@@ -0,0 +1,29 @@
+// +build !windows
+
+package realize
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillGroup(t *testing.T) {
+	var out bytes.Buffer
+	cmd := exec.Command("sh", "-c", "sleep 5 & wait")
+	cmd.Stdout = &out
+	setGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	killGroup(cmd.Process)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("Children of the command still running")
+	}
+}
@@ -2,7 +2,15 @@
 
 package realize
 
-import "syscall"
+import (
+	"golang.org/x/sys/windows"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+)
 
 // isHidden check if a file or a path is hidden
 func isHidden(path string) bool {
@@ -16,3 +24,99 @@ func isHidden(path string) bool {
 	}
 	return attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0
 }
+
+// shell returns the args to run a command line through the system shell
+func shell(cmd string) []string {
+	return []string{"cmd", "/c", cmd}
+}
+
+// jobs of the started commands, their children are assigned to the job of the command too
+var (
+	jobs   = make(map[*os.Process]windows.Handle)
+	jobsMu sync.Mutex
+)
+
+// jobAccounting is the basic accounting information of a job object
+type jobAccounting struct {
+	TotalUserTime, TotalKernelTime, ThisPeriodTotalUserTime, ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount, TotalProcesses, ActiveProcesses, TotalTerminatedProcesses     uint32
+}
+
+// setGroup starts a command in its own process group, so its children can be stopped together
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// startGroup starts a command and assigns it to a job object, killed as a whole even after the exit of the parents
+// the processes of the job are killed by the close of its handle, at the exit of realize too
+func startGroup(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE|windows.SYNCHRONIZE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		// killed by taskkill
+		return nil
+	}
+	job, err := windows.CreateJobObject(nil, nil)
+	if err == nil {
+		info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE}}
+		if _, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err == nil {
+			err = windows.AssignProcessToJobObject(job, process)
+		}
+		if err != nil {
+			windows.CloseHandle(job)
+		}
+	}
+	if err != nil {
+		windows.CloseHandle(process)
+		return nil
+	}
+	jobsMu.Lock()
+	jobs[cmd.Process] = job
+	jobsMu.Unlock()
+	// the job is released after the exit of the command if none of its children is left
+	go func() {
+		windows.WaitForSingleObject(process, windows.INFINITE)
+		windows.CloseHandle(process)
+		var info jobAccounting
+		if windows.QueryInformationJobObject(job, windows.JobObjectBasicAccountingInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil) == nil && info.ActiveProcesses == 0 {
+			release(cmd.Process)
+		}
+	}()
+	return nil
+}
+
+// release closes the job of a command, its processes left are killed
+func release(process *os.Process) bool {
+	jobsMu.Lock()
+	job, ok := jobs[process]
+	delete(jobs, process)
+	jobsMu.Unlock()
+	if ok {
+		windows.CloseHandle(job)
+	}
+	return ok
+}
+
+// signalGroup sends a signal to a command, signals aren't supported by process groups on windows
+func signalGroup(process *os.Process, sig os.Signal) error {
+	return process.Signal(sig)
+}
+
+// killGroup kills the processes of the job of a command, the process tree by taskkill without a job
+func killGroup(process *os.Process) error {
+	jobsMu.Lock()
+	job, ok := jobs[process]
+	jobsMu.Unlock()
+	if ok {
+		err := windows.TerminateJobObject(job, 1)
+		release(process)
+		return err
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run(); err != nil {
+		process.Kill()
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"github.com/oxequa/realize/realize"
+	"gopkg.in/urfave/cli.v2"
 	"log"
 	"strings"
 	"testing"
@@ -139,3 +140,19 @@ func TestRealize_version(t *testing.T) {
 		t.Error("Version expted", realize.RVersion)
 	}
 }
+
+func TestSubcommand(t *testing.T) {
+	app := &cli.App{Commands: []*cli.Command{{Name: "start", Aliases: []string{"s"}}}}
+	tests := map[string]string{
+		"realize -- go run .":    "",
+		"realize -- start":       "start",
+		"realize -c x -- s":      "s",
+		"realize start -- start": "",
+		"realize --":             "",
+	}
+	for line, name := range tests {
+		if v := subcommand(app, strings.Fields(line)); v != name {
+			t.Error("Unexpected command of", line, v)
+		}
+	}
+}